@@ -0,0 +1,14 @@
+module github.com/segmentio/encoding/build
+
+go 1.23
+
+require github.com/mmcloughlin/avo v0.6.0
+
+require (
+	github.com/segmentio/encoding v0.0.0-00010101000000-000000000000 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/tools v0.30.0 // indirect
+)
+
+replace github.com/segmentio/encoding => ../
@@ -0,0 +1,119 @@
+// Command structural generates the AVX2 kernel of the
+// json/internal/structural package.
+package main
+
+import (
+	. "github.com/mmcloughlin/avo/build"
+	. "github.com/mmcloughlin/avo/operand"
+	. "github.com/mmcloughlin/avo/reg"
+)
+
+// Offsets of the fields of structural.Block.
+const (
+	quotesOffset      = 0
+	backslashesOffset = 8
+	operatorsOffset   = 16
+	controlsOffset    = 24
+	blockSize         = 32
+)
+
+func main() {
+	Package("github.com/segmentio/encoding/json/internal/structural")
+	ConstraintExpr("!purego")
+
+	TEXT("classifyAVX2", NOSPLIT, "func(blocks *Block, src *byte, n int)")
+	Doc(
+		"classifyAVX2 fills the n blocks starting at the address of blocks with the",
+		"bitmasks of the n*64 bytes starting at the address of src.",
+	)
+	Pragma("noescape")
+
+	blocks := Load(Param("blocks"), GP64())
+	src := Load(Param("src"), GP64())
+	n := Load(Param("n"), GP64())
+
+	Comment("Broadcast the constants that the bytes are compared with.")
+	quote := broadcast('"')
+	backslash := broadcast('\\')
+	lower := broadcast(0x20) // maps '[' and ']' to '{' and '}'
+	openBrace := broadcast('{')
+	closeBrace := broadcast('}')
+	colon := broadcast(':')
+	comma := broadcast(',')
+	maxControl := broadcast(0x1f)
+
+	Label("loop")
+	TESTQ(n, n)
+	JZ(LabelRef("done"))
+
+	lo, hi := YMM(), YMM()
+	VMOVDQU(Mem{Base: src}, lo)
+	VMOVDQU(Mem{Base: src, Disp: 32}, hi)
+
+	Comment("Quotes")
+	store(equal(quote, lo), equal(quote, hi), Mem{Base: blocks, Disp: quotesOffset})
+
+	Comment("Backslashes")
+	store(equal(backslash, lo), equal(backslash, hi), Mem{Base: blocks, Disp: backslashesOffset})
+
+	Comment("Operators")
+	operators := func(v VecVirtual) VecVirtual {
+		folded := YMM()
+		VPOR(lower, v, folded)
+		r := equal(openBrace, folded)
+		VPOR(equal(closeBrace, folded), r, r)
+		VPOR(equal(colon, v), r, r)
+		VPOR(equal(comma, v), r, r)
+		return r
+	}
+	store(operators(lo), operators(hi), Mem{Base: blocks, Disp: operatorsOffset})
+
+	Comment("Control characters, which are equal to their minimum with 0x1f")
+	controls := func(v VecVirtual) VecVirtual {
+		r := YMM()
+		VPMINUB(maxControl, v, r)
+		VPCMPEQB(r, v, r)
+		return r
+	}
+	store(controls(lo), controls(hi), Mem{Base: blocks, Disp: controlsOffset})
+
+	ADDQ(U8(64), src)
+	ADDQ(U8(blockSize), blocks)
+	DECQ(n)
+	JMP(LabelRef("loop"))
+
+	Label("done")
+	VZEROUPPER()
+	RET()
+
+	Generate()
+}
+
+// broadcast returns a vector with all its bytes set to c.
+func broadcast(c byte) VecVirtual {
+	r := GP64()
+	MOVL(U32(c), r.As32())
+	x := XMM()
+	VMOVQ(r, x)
+	y := YMM()
+	VPBROADCASTB(x, y)
+	return y
+}
+
+// equal returns a vector with the bytes of v equal to those of c set to 0xff.
+func equal(c, v VecVirtual) VecVirtual {
+	r := YMM()
+	VPCMPEQB(c, v, r)
+	return r
+}
+
+// store writes to m the 64-bit mask made of the most significant bits of the
+// bytes of lo and hi.
+func store(lo, hi VecVirtual, m Mem) {
+	a, b := GP64(), GP64()
+	VPMOVMSKB(lo, a.As32())
+	VPMOVMSKB(hi, b.As32())
+	SHLQ(U8(32), b)
+	ORQ(b, a)
+	MOVQ(a, m)
+}
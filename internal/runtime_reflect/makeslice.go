@@ -0,0 +1,27 @@
+//go:build !purego
+
+package runtime_reflect
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+func MakeSlice(elemType reflect.Type, len, cap int) Slice {
+	return Slice{
+		data: newarray(typeptr(elemType), cap),
+		len:  len,
+		cap:  cap,
+	}
+}
+
+func CopySlice(elemType reflect.Type, dst, src Slice) int {
+	return typedslicecopy(typeptr(elemType), dst, src)
+}
+
+//go:linkname newarray runtime.newarray
+func newarray(t unsafe.Pointer, n int) unsafe.Pointer
+
+//go:linkname typedslicecopy runtime.typedslicecopy
+//go:noescape
+func typedslicecopy(t unsafe.Pointer, dst, src Slice) int
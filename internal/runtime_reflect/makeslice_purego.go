@@ -0,0 +1,24 @@
+//go:build purego
+
+package runtime_reflect
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+func MakeSlice(elemType reflect.Type, len, cap int) Slice {
+	return Slice{
+		data: reflect.MakeSlice(reflect.SliceOf(elemType), cap, cap).UnsafePointer(),
+		len:  len,
+		cap:  cap,
+	}
+}
+
+func CopySlice(elemType reflect.Type, dst, src Slice) int {
+	t := reflect.SliceOf(elemType)
+	return reflect.Copy(
+		reflect.NewAt(t, unsafe.Pointer(&dst)).Elem(),
+		reflect.NewAt(t, unsafe.Pointer(&src)).Elem(),
+	)
+}
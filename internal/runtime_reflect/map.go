@@ -1,40 +1,34 @@
-// Package runtime_reflect exposes internal APIs of the Go runtime.
-//
-// This package is internal so it doesn't become part of the exported APIs that
-// users of this package can take dependencies on. There is a risk that these
-// APIs will be implicitly changed by Go, in which case packages that depend on
-// it will break. We use these APIs to have access to optimziations that aren't
-// possible today via the reflect package. Ideally, the reflect package evolves
-// to expose APIs that are efficient enough that we can drop the need for this
-// package, but until then we will be maintaining bridges to these Go runtime
-// functions and types.
+//go:build !purego
+
 package runtime_reflect
 
-import "unsafe"
+import (
+	"reflect"
+	"unsafe"
+)
 
-func Assign(typ, dst, src unsafe.Pointer) {
-	typedmemmove(typ, dst, src)
+func Assign(t reflect.Type, dst, src unsafe.Pointer) {
+	typedmemmove(typeptr(t), dst, src)
 }
 
-func MapAssign(t, m, k unsafe.Pointer) unsafe.Pointer {
-	return mapassign(t, m, k)
+// MapAssign sets the value pointed to by v for the key pointed to by k in the
+// map m of type t.
+func MapAssign(t reflect.Type, m, k, v unsafe.Pointer) {
+	typedmemmove(typeptr(t.Elem()), mapassign(typeptr(t), m, k), v)
 }
 
-func MakeMap(t unsafe.Pointer, cap int) unsafe.Pointer {
-	return makemap(t, cap)
+func MakeMap(t reflect.Type, cap int) unsafe.Pointer {
+	return makemap(typeptr(t), cap)
 }
 
+// MapIter iterates over the entries of a map.
+//
+// The layout of the iterator depends on the implementation of maps in the Go
+// runtime, see map_swiss.go and map_noswiss.go.
 type MapIter struct{ hiter }
 
-func (it *MapIter) Init(t unsafe.Pointer, m unsafe.Pointer) {
-	mapiterinit(t, m, &it.hiter)
-}
-
-func (it *MapIter) Done() {
-	if it.h != nil {
-		it.key = nil
-		mapiternext(&it.hiter)
-	}
+func (it *MapIter) Init(t reflect.Type, m unsafe.Pointer) {
+	mapiterinit(typeptr(t), m, &it.hiter)
 }
 
 func (it *MapIter) Next() {
@@ -49,28 +43,14 @@ func (it *MapIter) Key() unsafe.Pointer { return it.key }
 
 func (it *MapIter) Value() unsafe.Pointer { return it.value }
 
-// copied from src/runtime/map.go, all pointer types replaced with
-// unsafe.Pointer.
-//
-// Alternatively we could get away with a heap allocation and only
-// defining key and val if we were using reflect.mapiterinit instead,
-// which returns a heap-allocated *hiter.
-type hiter struct {
-	key         unsafe.Pointer // nil when iteration is done
-	value       unsafe.Pointer
-	t           unsafe.Pointer
-	h           unsafe.Pointer
-	buckets     unsafe.Pointer // bucket ptr at hash_iter initialization time
-	bptr        unsafe.Pointer // current bucket
-	overflow    unsafe.Pointer // keeps overflow buckets of hmap.buckets alive
-	oldoverflow unsafe.Pointer // keeps overflow buckets of hmap.oldbuckets alive
-	startBucket uintptr        // bucket iteration started at
-	offset      uint8          // intra-bucket offset to start from during iteration (should be big enough to hold bucketCnt-1)
-	wrapped     bool           // already wrapped around from end of bucket array to beginning
-	B           uint8
-	i           uint8
-	bucket      uintptr
-	checkBucket uintptr
+type iface struct {
+	typ unsafe.Pointer
+	ptr unsafe.Pointer
+}
+
+// typeptr returns the pointer to the runtime type of t.
+func typeptr(t reflect.Type) unsafe.Pointer {
+	return (*iface)(unsafe.Pointer(&t)).ptr
 }
 
 //go:noescape
@@ -0,0 +1,36 @@
+//go:build !purego && !go1.26 && (!go1.24 || !goexperiment.swissmap)
+
+package runtime_reflect
+
+import "unsafe"
+
+func (it *MapIter) Done() {
+	if it.h != nil {
+		it.key = nil
+		mapiternext(&it.hiter)
+	}
+}
+
+// copied from src/runtime/map.go, all pointer types replaced with
+// unsafe.Pointer.
+//
+// Alternatively we could get away with a heap allocation and only
+// defining key and val if we were using reflect.mapiterinit instead,
+// which returns a heap-allocated *hiter.
+type hiter struct {
+	key         unsafe.Pointer // nil when iteration is done
+	value       unsafe.Pointer
+	t           unsafe.Pointer
+	h           unsafe.Pointer
+	buckets     unsafe.Pointer // bucket ptr at hash_iter initialization time
+	bptr        unsafe.Pointer // current bucket
+	overflow    unsafe.Pointer // keeps overflow buckets of hmap.buckets alive
+	oldoverflow unsafe.Pointer // keeps overflow buckets of hmap.oldbuckets alive
+	startBucket uintptr        // bucket iteration started at
+	offset      uint8          // intra-bucket offset to start from during iteration (should be big enough to hold bucketCnt-1)
+	wrapped     bool           // already wrapped around from end of bucket array to beginning
+	B           uint8
+	i           uint8
+	bucket      uintptr
+	checkBucket uintptr
+}
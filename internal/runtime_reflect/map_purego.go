@@ -0,0 +1,62 @@
+//go:build purego
+
+package runtime_reflect
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+func Assign(t reflect.Type, dst, src unsafe.Pointer) {
+	reflect.NewAt(t, dst).Elem().Set(reflect.NewAt(t, src).Elem())
+}
+
+// MapAssign sets the value pointed to by v for the key pointed to by k in the
+// map m of type t.
+func MapAssign(t reflect.Type, m, k, v unsafe.Pointer) {
+	reflect.NewAt(t, unsafe.Pointer(&m)).Elem().SetMapIndex(
+		reflect.NewAt(t.Key(), k).Elem(),
+		reflect.NewAt(t.Elem(), v).Elem(),
+	)
+}
+
+func MakeMap(t reflect.Type, cap int) unsafe.Pointer {
+	return reflect.MakeMapWithSize(t, cap).UnsafePointer()
+}
+
+// MapIter iterates over the entries of a map.
+//
+// The keys and values are copied to variables allocated when the iteration
+// starts, which the pointers returned by Key and Value point to.
+type MapIter struct {
+	iter  *reflect.MapIter
+	key   reflect.Value
+	value reflect.Value
+	next  bool
+}
+
+func (it *MapIter) Init(t reflect.Type, m unsafe.Pointer) {
+	it.iter = reflect.NewAt(t, unsafe.Pointer(&m)).Elem().MapRange()
+	it.key = reflect.New(t.Key()).Elem()
+	it.value = reflect.New(t.Elem()).Elem()
+	it.Next()
+}
+
+func (it *MapIter) Done() {
+	*it = MapIter{}
+}
+
+func (it *MapIter) Next() {
+	if it.next = it.iter.Next(); it.next {
+		it.key.SetIterKey(it.iter)
+		it.value.SetIterValue(it.iter)
+	}
+}
+
+func (it *MapIter) HasNext() bool {
+	return it.next
+}
+
+func (it *MapIter) Key() unsafe.Pointer { return it.key.Addr().UnsafePointer() }
+
+func (it *MapIter) Value() unsafe.Pointer { return it.value.Addr().UnsafePointer() }
@@ -0,0 +1,25 @@
+//go:build !purego && (go1.26 || (go1.24 && goexperiment.swissmap))
+
+package runtime_reflect
+
+import "unsafe"
+
+func (it *MapIter) Done() {
+	it.key = nil
+	it.value = nil
+	it.it = nil
+}
+
+// copied from linknameIter in src/runtime/linkname_swiss.go (moved to
+// src/runtime/linkname_shim.go in Go 1.26), all pointer types replaced with
+// unsafe.Pointer.
+//
+// Since Go 1.24, maps are implemented with swiss tables and the runtime only
+// exposes this layout to the callers of mapiterinit and mapiternext, the state
+// of the iteration lives in a heap-allocated internal/runtime/maps.Iter.
+type hiter struct {
+	key   unsafe.Pointer // nil when iteration is done
+	value unsafe.Pointer
+	t     unsafe.Pointer
+	it    unsafe.Pointer // *maps.Iter
+}
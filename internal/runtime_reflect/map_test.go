@@ -0,0 +1,70 @@
+package runtime_reflect
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"unsafe"
+)
+
+// The sizes cross the thresholds where the runtime grows maps, including the
+// split of swiss tables once they exceed 1024 entries.
+var mapSizes = []int{0, 1, 7, 8, 9, 100, 1024, 1025, 5000}
+
+func TestMapAssignIter(t *testing.T) {
+	for _, n := range mapSizes {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			typ := reflect.TypeOf(map[string]int(nil))
+			m := MakeMap(typ, n)
+
+			for i := 0; i < n; i++ {
+				k := strconv.Itoa(i)
+				MapAssign(typ, m, unsafe.Pointer(&k), unsafe.Pointer(&i))
+			}
+
+			got := *(*map[string]int)(unsafe.Pointer(&m))
+			if len(got) != n {
+				t.Fatalf("wrong map length: want %d, got %d", n, len(got))
+			}
+
+			seen := make(map[string]bool, n)
+			it := MapIter{}
+			defer it.Done()
+
+			for it.Init(typ, m); it.HasNext(); it.Next() {
+				k := *(*string)(it.Key())
+				v := *(*int)(it.Value())
+
+				if k != strconv.Itoa(v) {
+					t.Fatalf("wrong entry: %q => %d", k, v)
+				}
+				if seen[k] {
+					t.Fatalf("key seen twice: %q", k)
+				}
+				seen[k] = true
+			}
+
+			if len(seen) != n {
+				t.Fatalf("wrong number of entries: want %d, got %d", n, len(seen))
+			}
+		})
+	}
+}
+
+func TestMapIterDone(t *testing.T) {
+	m := map[int]int{1: 1, 2: 2, 3: 3}
+	p := *(*unsafe.Pointer)(unsafe.Pointer(&m))
+
+	it := MapIter{}
+	it.Init(reflect.TypeOf(m), p)
+
+	if !it.HasNext() {
+		t.Fatal("iterator has no entries")
+	}
+
+	it.Done()
+
+	if it.HasNext() {
+		t.Fatal("iterator has entries after calling Done")
+	}
+}
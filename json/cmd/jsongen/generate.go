@@ -0,0 +1,1347 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/encoding/json"
+)
+
+// class is the representation of field types that the generated code
+// specializes.
+type class int
+
+const (
+	other class = iota
+	boolean
+	str
+	signed
+	unsigned
+	float
+	// The field has options that the generated code does not specialize, it
+	// is encoded and decoded by the json package as the only field of a
+	// wrapper struct with the same options.
+	wrapped
+)
+
+type field struct {
+	name      string   // selector of the Go field in the struct value
+	key       string   // name of the JSON object member
+	aliases   []string // alternate names accepted when decoding
+	typ       types.Type
+	class     class
+	tag       bool // the key comes from the struct tag
+	omitempty bool
+	stringify bool
+	required  bool
+	defaults  bool   // the field has a default value
+	options   string // options of the wrapper struct of wrapped fields
+	pointers  []pointer
+}
+
+// pointer is an embedded struct pointer on the path to a field, the field is
+// omitted when the pointer is nil, and the pointer is allocated when decoding.
+type pointer struct {
+	name       string // selector of the pointer in the struct value
+	elem       types.Type
+	unexported bool
+}
+
+// generate returns the source of the methods and of the tests generated for
+// the named types of pkg.
+func generate(pkg *types.Package, names []string) (code, test []byte, err error) {
+	g := &generator{
+		pkg:     pkg,
+		imports: make(map[string]bool),
+		prefix:  "jsongen" + names[0],
+		helpers: make(map[string]bool),
+	}
+	t := &generator{
+		pkg:     pkg,
+		imports: make(map[string]bool),
+		prefix:  "jsongen" + names[0],
+		helpers: make(map[string]bool),
+	}
+
+	for _, name := range names {
+		obj, _ := pkg.Scope().Lookup(name).(*types.TypeName)
+		if obj == nil {
+			return nil, nil, fmt.Errorf("type %s not found in package %s", name, pkg.Path())
+		}
+		named, _ := obj.Type().(*types.Named)
+		if named == nil || named.TypeParams().Len() != 0 {
+			return nil, nil, fmt.Errorf("%s is not a non-generic named type", name)
+		}
+		st, _ := named.Underlying().(*types.Struct)
+		if st == nil {
+			return nil, nil, fmt.Errorf("%s is not a struct type", name)
+		}
+
+		fields, err := structFields(pkg, name, named, st)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		g.appendJSON(name, fields)
+		g.parseJSON(name, fields, hasValidateMethod(named))
+		t.parityTest(name, fields, hasValidateMethod(named))
+	}
+
+	return g.source(), t.source(), nil
+}
+
+// structFields returns the list of fields of st which are encoded, following
+// the rules of the json package.
+func structFields(pkg *types.Package, name string, named *types.Named, st *types.Struct) ([]field, error) {
+	fields, err := appendStructFields(nil, pkg, name, st, "", nil, []types.Type{named})
+	if err != nil {
+		return nil, err
+	}
+
+	// The json package encodes all the fields declared with the same name,
+	// and which of them a value is decoded into depends on the order of the
+	// keys that it saw before. Embedded fields with the same name are
+	// ambiguous and ignored, so duplicates only come from the fields declared
+	// by the struct itself.
+	names := make(map[string]string)
+	for _, f := range fields {
+		if prev, dup := names[f.key]; dup {
+			return nil, fmt.Errorf("%s: fields %s and %s have the same name %q", name, prev, f.name, f.key)
+		}
+		names[f.key] = f.name
+	}
+	return fields, nil
+}
+
+// appendStructFields mirrors the function of the same name of the json
+// package, the fields of embedded structs are promoted to the fields of st
+// unless they are ambiguous. The selectors of the fields are prefixed with
+// path, and chain lists the struct types which embed st.
+func appendStructFields(fields []field, pkg *types.Package, name string, st *types.Struct, path string, pointers []pointer, chain []types.Type) ([]field, error) {
+	entries := make([][]field, st.NumFields())
+	embedded := make([]bool, st.NumFields())
+	names := make(map[string]bool)
+
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		key := f.Name()
+		tag := false
+		omitempty := false
+		stringify := false
+		required := false
+		format := ""
+		defval := ""
+		var aliases []string
+
+		if !f.Exported() && !f.Embedded() {
+			continue
+		}
+
+		if parts := splitTagOptions(reflect.StructTag(st.Tag(i)).Get("json")); len(parts) != 0 {
+			if len(parts[0]) != 0 {
+				key, tag = parts[0], true
+			}
+			if key == "-" && len(parts) == 1 {
+				continue
+			}
+			if !isValidTag(key) {
+				key = f.Name()
+			}
+
+		options:
+			for j, opt := range parts[1:] {
+				switch {
+				case opt == "omitempty":
+					omitempty = true
+				case opt == "string":
+					stringify = true
+				case opt == "required":
+					required = true
+				case strings.HasPrefix(opt, "format:"):
+					format = opt
+				case strings.HasPrefix(opt, "alias:"):
+					if alias := unquoteTagOption(opt[len("alias:"):]); isValidTag(alias) && !slices.Contains(aliases, alias) {
+						aliases = append(aliases, alias)
+					}
+				case strings.HasPrefix(opt, "default="):
+					defval = strings.Join(parts[1+j:], ",")
+					break options
+				case strings.HasPrefix(opt, "discriminator:"):
+					return nil, fmt.Errorf("%s.%s: tag option %q is not supported", name, path+f.Name(), opt)
+				}
+			}
+		}
+
+		if f.Embedded() && !tag {
+			typ := types.Unalias(f.Type())
+			ptr := false
+			if p, ok := typ.(*types.Pointer); ok {
+				typ, ptr = types.Unalias(p.Elem()), true
+			}
+			if sub, ok := typ.Underlying().(*types.Struct); ok {
+				sel := path + f.Name()
+				if !f.Exported() && f.Pkg() != pkg {
+					return nil, fmt.Errorf("%s.%s: embedded struct of another package is not accessible", name, sel)
+				}
+				// Methods of embedded types are promoted, the json package
+				// would use them to encode the embedding type.
+				if hasMarshalMethods(typ) {
+					return nil, fmt.Errorf("%s.%s: embedded type %s has marshaling methods", name, sel, typ)
+				}
+				for _, t := range chain {
+					if types.Identical(t, typ) {
+						return nil, fmt.Errorf("%s.%s: recursive embedding of %s", name, sel, typ)
+					}
+				}
+
+				subpointers := pointers
+				if ptr {
+					subpointers = append(slices.Clip(pointers), pointer{name: sel, elem: typ, unexported: !f.Exported()})
+				}
+				subfields, err := appendStructFields(nil, pkg, name, sub, sel+".", subpointers, append(slices.Clip(chain), typ))
+				if err != nil {
+					return nil, err
+				}
+				entries[i], embedded[i] = subfields, true
+				continue
+			}
+			if !f.Exported() {
+				continue
+			}
+		}
+
+		c := classify(pkg, f.Type())
+		if stringify && c == other {
+			// The string option only applies to basic types and pointers to
+			// them, and is ignored for other types.
+			typ := types.Unalias(f.Type())
+			if p, ok := typ.Underlying().(*types.Pointer); ok {
+				typ = p.Elem()
+			}
+			if b, ok := typ.Underlying().(*types.Basic); ok && b.Info()&(types.IsBoolean|types.IsNumeric|types.IsString) != 0 {
+				c = wrapped
+			} else {
+				stringify = false
+			}
+		}
+
+		var options []string
+		if format != "" || defval != "" {
+			c = wrapped
+		}
+		if c == wrapped {
+			if stringify {
+				options = append(options, "string")
+			}
+			if format != "" {
+				options = append(options, format)
+			}
+			if defval != "" {
+				options = append(options, defval)
+			}
+		}
+
+		entries[i] = []field{{
+			name:      path + f.Name(),
+			key:       key,
+			aliases:   aliases,
+			typ:       f.Type(),
+			class:     c,
+			tag:       tag,
+			omitempty: omitempty,
+			stringify: stringify,
+			required:  required,
+			defaults:  defval != "",
+			options:   strings.Join(options, ","),
+			pointers:  pointers,
+		}}
+		names[key] = true
+	}
+
+	// Only unambiguous embedded fields are encoded, and they never override
+	// the fields of st.
+	ambiguousNames := make(map[string]int)
+	ambiguousTags := make(map[string]int)
+	for key := range names {
+		ambiguousNames[key]++
+		ambiguousTags[key]++
+	}
+	for i, subfields := range entries {
+		for _, f := range subfields {
+			if embedded[i] {
+				ambiguousNames[f.key]++
+				if f.tag {
+					ambiguousTags[f.key]++
+				}
+			}
+		}
+	}
+
+	for i, subfields := range entries {
+		for _, f := range subfields {
+			if embedded[i] {
+				if ambiguousNames[f.key] > 1 && (!f.tag || ambiguousTags[f.key] != 1) {
+					continue
+				}
+				// Tags only dominate one level above the field.
+				f.tag = false
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	return fields, nil
+}
+
+// classify returns the class of t, which is other unless t is a basic type
+// or a type of pkg with a basic underlying type and no custom marshaling
+// methods.
+func classify(pkg *types.Package, t types.Type) class {
+	switch t := types.Unalias(t).(type) {
+	case *types.Basic:
+		return basicClass(t)
+	case *types.Named:
+		if t.Obj().Pkg() != pkg || hasMarshalMethods(t) {
+			return other
+		}
+		if b, ok := t.Underlying().(*types.Basic); ok {
+			return basicClass(b)
+		}
+	}
+	return other
+}
+
+func basicClass(t *types.Basic) class {
+	switch t.Kind() {
+	case types.Bool:
+		return boolean
+	case types.String:
+		return str
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		return signed
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return unsigned
+	case types.Float32, types.Float64:
+		return float
+	default:
+		return other
+	}
+}
+
+func hasMarshalMethods(t types.Type) bool {
+	mset := types.NewMethodSet(types.NewPointer(t))
+	for _, name := range []string{
+		"MarshalJSON",
+		"MarshalText",
+		"AppendJSON",
+		"UnmarshalJSON",
+		"UnmarshalText",
+		"UnmarshalJSONFrom",
+	} {
+		if mset.Lookup(nil, name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidateMethod reports whether t implements json.Validator, in which case
+// the generated ParseJSON method validates the decoded values.
+func hasValidateMethod(t types.Type) bool {
+	sel := types.NewMethodSet(types.NewPointer(t)).Lookup(nil, "Validate")
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Universe.Lookup("error").Type())
+}
+
+// nonEmptyExpr returns the expression testing whether the value of f is not
+// empty, or an empty string if values of the field type are never empty.
+func nonEmptyExpr(f field) string {
+	v := "v." + f.name
+
+	switch t := f.typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return v
+		case t.Info()&types.IsString != 0:
+			return v + ` != ""`
+		case t.Info()&types.IsNumeric != 0:
+			return v + " != 0"
+		}
+	case *types.Slice, *types.Map:
+		return "len(" + v + ") != 0"
+	case *types.Pointer, *types.Interface, *types.Chan, *types.Signature:
+		return v + " != nil"
+	case *types.Array:
+		if t.Len() == 0 {
+			return "false"
+		}
+	}
+
+	return ""
+}
+
+type generator struct {
+	pkg     *types.Package
+	buf     bytes.Buffer
+	imports map[string]bool
+
+	// Helper functions are emitted once per file, their names have a prefix
+	// so files generated for different types of a package don't conflict.
+	prefix     string
+	helpers    map[string]bool
+	helpersBuf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+func (g *generator) source() []byte {
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	// Standard library imports go first, separated from third-party imports.
+	sort.Slice(paths, func(i, j int) bool {
+		if a, b := strings.Contains(paths[i], "."), strings.Contains(paths[j], "."); a != b {
+			return b
+		}
+		return paths[i] < paths[j]
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by jsongen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg.Name())
+	for i, path := range paths {
+		if i != 0 && strings.Contains(path, ".") && !strings.Contains(paths[i-1], ".") {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	b.WriteString(")\n")
+	b.Write(g.buf.Bytes())
+	b.Write(g.helpersBuf.Bytes())
+	return b.Bytes()
+}
+
+func (g *generator) appendJSON(name string, fields []field) {
+	g.use("github.com/segmentio/encoding/json")
+
+	type position int
+	const (
+		first    position = iota // no field was written before
+		notFirst                 // a field was always written before
+		unknown                  // whether a field was written is only known at runtime
+	)
+
+	// Compute the position of each field relative to the fields written
+	// before it, which determines how the separating comma is written.
+	positions := make([]position, len(fields))
+	conditions := make([]string, len(fields))
+	lastUnknown := -1
+	pos := first
+	for i, f := range fields {
+		positions[i] = pos
+		conditions[i] = condition(f)
+		switch {
+		case conditions[i] == "false":
+			// Values of the field are always empty and omitted.
+		case conditions[i] == "":
+			pos = notFirst
+		case pos == first:
+			pos = unknown
+		}
+		if positions[i] == unknown {
+			lastUnknown = i
+		}
+	}
+
+	needErr := false
+	needMark := false
+	needHTML := false
+	for _, f := range fields {
+		needErr = needErr || f.class == other || f.class == float || f.class == wrapped
+		needMark = needMark || (f.stringify && f.class == str) || f.class == wrapped
+		needHTML = needHTML || (keyFragment(f.key, 0) != keyFragment(f.key, json.EscapeHTML) && !nonASCII(f.key))
+	}
+
+	g.printf("\n// AppendJSON appends the JSON representation of v to b, it implements\n")
+	g.printf("// json.MarshalerTo.\n")
+	g.printf("func (v %s) AppendJSON(b []byte, flags json.AppendFlags) ([]byte, error) {\n", name)
+	if needErr {
+		g.printf("start := len(b)\nvar err error\n")
+	}
+	if needMark {
+		g.printf("var mark int\n")
+	}
+	if needHTML {
+		g.printf("html := (flags & json.EscapeHTML) != 0\n")
+	}
+	if lastUnknown >= 0 {
+		g.printf("more := false\n")
+	}
+	g.printf("b = append(b, '{')\n")
+
+	for i, f := range fields {
+		cond := conditions[i]
+		if cond == "false" {
+			continue
+		}
+		if cond != "" {
+			g.printf("if %s {\n", cond)
+		}
+
+		comma := positions[i] == notFirst
+		if positions[i] == unknown {
+			g.printf("if more {\nb = append(b, ',')\n}\n")
+		}
+		if nonASCII(f.key) {
+			// The escaping of the key depends on multiple flags, it is done
+			// when encoding values rather than precomputed.
+			if comma {
+				g.printf("b = append(b, ',')\n")
+			}
+			g.printf("b = append(json.AppendEscape(b, %s, flags), ':')\n", strconv.Quote(f.key))
+		} else if k, h := keyFragment(f.key, 0), keyFragment(f.key, json.EscapeHTML); k != h {
+			g.printf("if html {\n")
+			g.appendKey(h, comma)
+			g.printf("} else {\n")
+			g.appendKey(k, comma)
+			g.printf("}\n")
+		} else {
+			g.appendKey(k, comma)
+		}
+
+		g.appendValue(f)
+
+		if i < lastUnknown && positions[i] != notFirst {
+			g.printf("more = true\n")
+		}
+		if cond != "" {
+			g.printf("}\n")
+		}
+	}
+
+	g.printf("return append(b, '}'), nil\n}\n")
+}
+
+// condition returns the expression testing whether f is encoded, which is
+// empty if the field is always encoded. Fields of embedded struct pointers are
+// omitted when the pointers are nil.
+func condition(f field) string {
+	conds := pointerConditions(f.pointers)
+	if f.omitempty {
+		switch cond := nonEmptyExpr(f); cond {
+		case "false":
+			return cond
+		case "":
+		default:
+			conds = append(conds, cond)
+		}
+	}
+	return strings.Join(conds, " && ")
+}
+
+func pointerConditions(pointers []pointer) []string {
+	conds := make([]string, 0, len(pointers)+1)
+	for _, p := range pointers {
+		conds = append(conds, "v."+p.name+" != nil")
+	}
+	return conds
+}
+
+// nonASCII returns true if key has characters which are escaped when the
+// json.EscapeNonASCII flag is set.
+func nonASCII(key string) bool {
+	return keyFragment(key, 0) != keyFragment(key, json.EscapeNonASCII)
+}
+
+func keyFragment(key string, flags json.AppendFlags) string {
+	return string(json.AppendEscape(nil, key, flags)) + ":"
+}
+
+func (g *generator) appendKey(key string, comma bool) {
+	if comma {
+		key = "," + key
+	}
+	g.printf("b = append(b, %s...)\n", strconv.Quote(key))
+}
+
+func (g *generator) appendValue(f field) {
+	v := "v." + f.name
+	quote := f.stringify && (f.class == boolean || f.class == signed || f.class == unsigned)
+
+	if quote {
+		g.printf("b = append(b, '\"')\n")
+	}
+
+	switch f.class {
+	case boolean:
+		g.use("strconv")
+		g.printf("b = strconv.AppendBool(b, bool(%s))\n", v)
+	case str:
+		if f.stringify {
+			g.printf("mark = len(b)\n")
+		}
+		g.printf("b = json.AppendEscape(b, string(%s), flags)\n", v)
+		if f.stringify {
+			// The string option encodes the value a second time as a string.
+			g.printf("b = %s(b, mark)\n", g.quoteHelper())
+		}
+	case signed:
+		g.use("strconv")
+		g.printf("b = strconv.AppendInt(b, int64(%s), 10)\n", v)
+	case unsigned:
+		g.use("strconv")
+		g.printf("b = strconv.AppendUint(b, uint64(%s), 10)\n", v)
+	case float:
+		g.use("math")
+		g.use("strconv")
+		bits, abs := 64, "abs"
+		if b, _ := f.typ.Underlying().(*types.Basic); b.Kind() == types.Float32 {
+			bits, abs = 32, "float32(abs)"
+		}
+		open, close := "", ""
+		if f.stringify {
+			open, close = "b = append(b, '\"')\n", "b = append(b, '\"')\n"
+		}
+		// Finite values are formatted like the json package does, as if by
+		// the ES6 number to string conversion. Non-finite values are not
+		// quoted by the string option.
+		g.printf(`if f := float64(%[1]s); math.IsNaN(f) || math.IsInf(f, 0) {
+			x := %[1]s
+			if b, err = json.Append(b, &x, flags); err != nil {
+				return b[:start], err
+			}
+		} else {
+			%[4]sabs, format := math.Abs(f), byte('f')
+			if abs != 0 && (%[2]s < 1e-6 || %[2]s >= 1e21) {
+				format = 'e'
+			}
+			b = strconv.AppendFloat(b, f, format, -1, %[3]d)
+			if n := len(b); format == 'e' && n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+				b[n-2] = b[n-1]
+				b = b[:n-1]
+			}
+			%[5]s}
+`, v, abs, bits, open, close)
+	case wrapped:
+		// The value is encoded as the only member of an object, which is
+		// removed from the output.
+		g.printf(`mark = len(b)
+		if b, err = json.Append(b, &%s{%s}, flags); err != nil {
+			return b[:start], err
+		}
+		b = %s(b, mark)
+`, g.wrapperType(f.typ, f.options), v, g.unwrapHelper())
+	default:
+		// The field is copied so only the copy escapes to the heap, instead
+		// of the receiver.
+		g.printf("{\nx := %s\nif b, err = json.Append(b, &x, flags); err != nil {\nreturn b[:start], err\n}\n}\n", v)
+	}
+
+	if quote {
+		g.printf("b = append(b, '\"')\n")
+	}
+}
+
+// wrapperType returns the type of the struct that the json package encodes
+// and decodes wrapped fields with, its only field has the given options.
+func (g *generator) wrapperType(typ types.Type, options string) string {
+	tag := "json:" + strconv.Quote(","+options)
+	if strconv.CanBackquote(tag) {
+		tag = "`" + tag + "`"
+	} else {
+		tag = strconv.Quote(tag)
+	}
+	return fmt.Sprintf("struct {\nF %s %s\n}", g.typeString(typ), tag)
+}
+
+func (g *generator) parseJSON(name string, fields []field, validate bool) {
+	g.use("bytes")
+	g.use("fmt")
+	g.use("reflect")
+	g.use("strings")
+	g.use("github.com/segmentio/encoding/json")
+
+	g.printf("\n// ParseJSON decodes the JSON value at the beginning of b into v, and returns\n")
+	g.printf("// the remaining bytes. It behaves like json.Parse.\n")
+	g.printf("func (v *%s) ParseJSON(b []byte, flags json.ParseFlags) ([]byte, error) {\n", name)
+	tracked := slices.ContainsFunc(fields, func(f field) bool { return f.required || f.defaults })
+	if tracked {
+		// Fields which are required or have default values are tracked to
+		// know which ones were absent from the object.
+		g.printf("var seen [%d]bool\n", len(fields))
+	}
+	g.printf(`input := b
+	// invalid lets the json package report the error of malformed input, or
+	// skip the object when the input is well-formed.
+	invalid := func() ([]byte, error) {
+		r, err := json.Parse(input, &struct{}{}, flags&^json.DisallowUnknownFields)
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			e.Type = reflect.TypeOf(v).Elem()
+		}
+		return r, err
+	}
+
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if bytes.HasPrefix(b, []byte("null")) {
+		return bytes.TrimLeft(b[4:], " \t\r\n"), nil
+	}
+	if len(b) == 0 || b[0] != '{' {
+		return invalid()
+	}
+
+	b = b[1:]
+	for i := 0; ; i++ {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) != 0 && b[0] == '}' {
+			%[3]sreturn bytes.TrimLeft(b[1:], " \t\r\n"), nil
+		}
+		if i != 0 {
+			if len(b) == 0 || b[0] != ',' {
+				return invalid()
+			}
+			b = bytes.TrimLeft(b[1:], " \t\r\n")
+		}
+		if len(b) == 0 || b[0] != '"' {
+			return invalid()
+		}
+
+		key, n := %[1]s(b)
+		var err error
+		if n > 0 {
+			b = b[n:]
+		} else {
+			var s string
+			if b, err = %[2]s(b, &s, flags); err != nil {
+				return invalid()
+			}
+			key = []byte(s)
+		}
+
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 || b[0] != ':' {
+			return invalid()
+		}
+		b = bytes.TrimLeft(b[1:], " \t\r\n")
+
+		f := -1
+		switch string(key) {
+`, g.stringHelper(), g.parseHelper(), g.absentCode(fields)+validateCode(validate))
+
+	// Aliases never take precedence over field names, and aliases declared by
+	// multiple fields are ambiguous and ignored.
+	names := make(map[string]bool)
+	aliases := make(map[string]int)
+	for _, f := range fields {
+		names[f.key] = true
+		for _, alias := range f.aliases {
+			aliases[alias]++
+		}
+	}
+	valid := make([][]string, len(fields))
+	for i, f := range fields {
+		for _, alias := range f.aliases {
+			if !names[alias] && aliases[alias] == 1 {
+				valid[i] = append(valid[i], alias)
+			}
+		}
+	}
+
+	for i, f := range fields {
+		g.printf("case %q:\nf = %d\n", f.key, i)
+	}
+	for i := range fields {
+		for _, alias := range valid[i] {
+			g.printf("case %q:\nf = %d\n", alias, i)
+		}
+	}
+	g.printf("}\n")
+
+	// When multiple fields have the same case-insensitive name, the first
+	// field wins, and names win over aliases.
+	lower := make(map[string]bool)
+	matchLower := func(key string, i int) {
+		if k := strings.ToLower(key); !lower[k] {
+			lower[k] = true
+			g.printf("case %q:\nf = %d\n", k, i)
+		}
+	}
+	g.printf("if f < 0 && (flags&json.DontMatchCaseInsensitiveStructFields) == 0 {\n")
+	g.printf("switch strings.ToLower(string(key)) {\n")
+	for i, f := range fields {
+		matchLower(f.key, i)
+	}
+	for i := range fields {
+		for _, alias := range valid[i] {
+			matchLower(alias, i)
+		}
+	}
+	g.printf("}\n}\n\n")
+
+	if tracked {
+		g.printf("if f >= 0 {\nseen[f] = true\n}\n")
+	}
+	g.printf("switch f {\n")
+	for i, f := range fields {
+		g.printf("case %d:\n", i)
+		g.parseValue(f)
+	}
+	g.printf(`default:
+		if (flags & json.DisallowUnknownFields) != 0 {
+			return b, fmt.Errorf("json: unknown field %%q", key)
+		}
+		var raw json.RawMessage
+		b, err = %s(b, &raw, flags|json.DontCopyRawMessage)
+	}
+
+	if err != nil {
+		// Syntax errors take precedence over type errors, which are
+		// reported after skipping the object.
+		r, e := invalid()
+		if e != nil {
+			return r, e
+		}
+		switch e := err.(type) {
+		case *json.UnmarshalTypeError:
+			e.Struct = reflect.TypeOf(v).Elem().String() + e.Struct
+			e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+		case *json.MissingFieldError:
+			e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+		case *json.ValidationError:
+			e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+		}
+		return r, err
+	}
+	}
+}
+`, g.parseHelper())
+}
+
+// absentCode returns the code applying the default values of the fields which
+// were absent from the object, and reporting the missing required fields.
+func (g *generator) absentCode(fields []field) string {
+	var b strings.Builder
+	const fail = "return bytes.TrimLeft(b[1:], \" \\t\\r\\n\"), err\n"
+
+	for i, f := range fields {
+		if !f.defaults {
+			continue
+		}
+		// The json package applies the default value to a wrapper holding the
+		// current value, which maps may be merged into.
+		fmt.Fprintf(&b, "if !seen[%d] && (flags&json.MergeObjects) == 0 {\nvar err error\n", i)
+		b.WriteString(g.allocPointers(f, fail))
+		fmt.Fprintf(&b, `x := %s{v.%s}
+			if _, err = json.Parse([]byte("{}"), &x, flags); err != nil {
+				%s}
+			v.%s = x.F
+		}
+		`, g.wrapperType(f.typ, f.options), f.name, fail, f.name)
+	}
+
+	var missing strings.Builder
+	for i, f := range fields {
+		if f.required {
+			fmt.Fprintf(&missing, "if !seen[%d] {\nmissing = append(missing, %q)\n}\n", i, f.key)
+		}
+	}
+	if missing.Len() != 0 {
+		fmt.Fprintf(&b, `var missing []string
+		%sif missing != nil {
+			return bytes.TrimLeft(b[1:], " \t\r\n"), &json.MissingFieldError{Type: reflect.TypeOf(v).Elem(), Fields: missing}
+		}
+		`, missing.String())
+	}
+
+	return b.String()
+}
+
+// allocPointers returns the code allocating the embedded struct pointers on the
+// path to f before it is decoded, like the json package does. The pointers to
+// unexported types cannot be set, the code then sets err and executes fail.
+func (g *generator) allocPointers(f field, fail string) string {
+	var b strings.Builder
+	for _, p := range f.pointers {
+		fmt.Fprintf(&b, "if v.%s == nil {\n", p.name)
+		if p.unexported {
+			fmt.Fprintf(&b, "err = fmt.Errorf(\"json: cannot set embedded pointer to unexported struct: %%s\", reflect.TypeFor[%s]())\n%s", g.typeString(p.elem), fail)
+		} else {
+			fmt.Fprintf(&b, "v.%s = new(%s)\n", p.name, g.typeString(p.elem))
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func validateCode(validate bool) string {
+	if !validate {
+		return ""
+	}
+	return `if err := v.Validate(); err != nil {
+		return bytes.TrimLeft(b[1:], " \t\r\n"), &json.ValidationError{Type: reflect.TypeOf(v).Elem(), Err: err}
+	}
+	`
+}
+
+// parseValue generates the code decoding the value of f from b. Values which
+// are not in the simplest form of their type are delegated to the json
+// package, which also reports errors.
+func (g *generator) parseValue(f field) {
+	v := "v." + f.name
+	typ := g.typeString(f.typ)
+	parse := fmt.Sprintf("b, err = %s(b, &%s, flags)\n", g.parseHelper(), v)
+
+	g.printf("%s", g.allocPointers(f, "break\n"))
+
+	// Wrapped fields are decoded by the json package into a struct with a
+	// single field which has the same options.
+	options := f.options
+	if f.class != wrapped {
+		options = "string"
+	}
+	fallback := func() string {
+		return fmt.Sprintf(`x := %s{%s}
+		var raw json.RawMessage
+		if b, err = %s(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+			if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+				%s = x.F
+			} else {
+				%s(err)
+			}
+		}
+`, g.wrapperType(f.typ, options), v, g.parseHelper(), v, g.unwrapErrorHelper())
+	}
+
+	switch {
+	case f.class == wrapped:
+		g.printf("%s", fallback())
+
+	case f.stringify:
+		// The semantics of the string option are intricate, the value is
+		// decoded by the json package like wrapped fields, unless it is a
+		// plain boolean or integer.
+		switch f.class {
+		case boolean:
+			g.printf(`switch {
+			case bytes.HasPrefix(b, []byte("\"true\"")):
+				%s, b = true, b[6:]
+			case bytes.HasPrefix(b, []byte("\"false\"")):
+				%s, b = false, b[7:]
+			default:
+				%s}
+`, v, v, fallback())
+
+		case signed, unsigned:
+			g.printf(`switch s, n := %s(b); {
+			case n > 0:
+				if x, ok := %s(s); ok {
+					%s, b = %s(x), b[n:]
+					break
+				}
+				fallthrough
+			default:
+				%s}
+`, g.stringHelper(), g.parseInteger(f), v, typ, fallback())
+
+		default:
+			g.printf("%s", fallback())
+		}
+
+	case f.class == boolean:
+		g.printf(`switch {
+		case bytes.HasPrefix(b, []byte("true")):
+			%s, b = true, b[4:]
+		case bytes.HasPrefix(b, []byte("false")):
+			%s, b = false, b[5:]
+		default:
+			%s}
+`, v, v, parse)
+
+	case f.class == str:
+		g.printf(`if s, n := %s(b); n > 0 {
+			%s, b = %s(s), b[n:]
+		} else {
+			%s}
+`, g.stringHelper(), v, typ, parse)
+
+	case f.class == signed, f.class == unsigned:
+		g.printf(`n := 0
+		for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+			n++
+		}
+		if x, ok := %s(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+			%s, b = %s(x), b[n:]
+		} else {
+			%s}
+`, g.parseInteger(f), v, typ, parse)
+
+	default:
+		g.printf("%s", parse)
+	}
+}
+
+// parseHelper returns the name of the function decoding values with the json
+// package, it is generated on first use.
+func (g *generator) parseHelper() string {
+	name := g.prefix + "Parse"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s decodes the value at the beginning of b into x, like json.Parse, but
+// bounds the input so the json package does not scan beyond the value.
+func %[1]s(b []byte, x any, flags json.ParseFlags) ([]byte, error) {
+	n, depth := len(b), 0
+scan:
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				n = min(i+1, len(b))
+				break scan
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth--; depth <= 0 {
+				n = i + max(depth+1, 0)
+				break scan
+			}
+		case ',', ':', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				n = i
+				break scan
+			}
+		}
+	}
+	r, err := json.Parse(b[:n], x, flags)
+	return b[n-len(r):], err
+}
+`, name)
+	return name
+}
+
+// stringHelper returns the name of the function parsing strings which need
+// no unescaping, it is generated on first use.
+func (g *generator) stringHelper() string {
+	name := g.prefix + "String"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s returns the content of the JSON string at the beginning of b and its
+// length, or a zero length if the string is not made of printable ASCII
+// characters only, or has escape sequences.
+func %[1]s(b []byte) ([]byte, int) {
+	if len(b) == 0 || b[0] != '"' {
+		return nil, 0
+	}
+	for i := 1; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			return b[1:i], i + 1
+		case c == '\\', c < 0x20, c > 0x7e:
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+`, name)
+	return name
+}
+
+// quoteHelper returns the name of the function encoding the JSON value at the
+// end of a buffer as a string, it is generated on first use.
+func (g *generator) quoteHelper() string {
+	name := g.prefix + "Quote"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s encodes the JSON value b[mark:] as a JSON string, in place. The value
+// was encoded with the same flags, so its quotes and backslashes are the only
+// characters that need to be escaped.
+func %[1]s(b []byte, mark int) []byte {
+	n := 0
+	for _, c := range b[mark:] {
+		if c == '"' || c == '\\' {
+			n++
+		}
+	}
+	i := len(b)
+	b = append(b, make([]byte, n+2)...)
+	j := len(b) - 1
+	b[j] = '"'
+	for i > mark {
+		i--
+		j--
+		b[j] = b[i]
+		if c := b[i]; c == '"' || c == '\\' {
+			j--
+			b[j] = '\\'
+		}
+	}
+	b[mark] = '"'
+	return b
+}
+`, name)
+	return name
+}
+
+// unwrapHelper returns the name of the function removing the object wrapping
+// the values of wrapped fields, it is generated on first use.
+func (g *generator) unwrapHelper() string {
+	name := g.prefix + "Unwrap"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s replaces the object {"F":value} at b[mark:] with its value.
+func %[1]s(b []byte, mark int) []byte {
+	const prefix = len(`+"`"+`{"F":`+"`"+`)
+	n := copy(b[mark:], b[mark+prefix:len(b)-1])
+	return b[:mark+n]
+}
+`, name)
+	return name
+}
+
+// unwrapErrorHelper returns the name of the function removing the field of the
+// wrapper struct from the errors of wrapped fields, it is generated on first
+// use.
+func (g *generator) unwrapErrorHelper() string {
+	name := g.prefix + "UnwrapError"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s removes the field of the wrapper struct from the path of err, the
+// caller prepends the key of the wrapped field.
+func %[1]s(err error) {
+	unwrap := func(field string) string {
+		return strings.TrimPrefix(strings.TrimPrefix(field, "F"), ".")
+	}
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		e.Struct, e.Field = "", unwrap(e.Field)
+	case *json.MissingFieldError:
+		e.Field = unwrap(e.Field)
+	case *json.ValidationError:
+		e.Field = unwrap(e.Field)
+	}
+}
+`, name)
+	return name
+}
+
+// parseInteger returns the name of the function parsing integers of the type
+// of f, it is generated on first use.
+func (g *generator) parseInteger(f field) string {
+	b, _ := f.typ.Underlying().(*types.Basic)
+	name := g.prefix + "Parse" + strings.ToUpper(b.Name()[:1]) + b.Name()[1:]
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+	g.use("strconv")
+
+	// The bit size is inferred from the type name, int and uint have a zero
+	// bit size.
+	bits, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(b.Name(), "u"), "int"))
+	fn, res := "ParseInt", "int64"
+	if f.class == unsigned {
+		fn, res = "ParseUint", "uint64"
+	}
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s parses b as a %[2]s, it reports false if b is not in the simplest
+// form of the integer, or the value overflows.
+func %[1]s(b []byte) (%[3]s, bool) {
+	i := 0
+	if len(b) != 0 && b[0] == '-' {
+		i = 1
+	}
+	if len(b) == i || (b[i] == '0' && len(b) != i+1) {
+		return 0, false
+	}
+	x, err := strconv.%[4]s(string(b), 10, %[5]d)
+	return x, err == nil
+}
+`, name, b.Name(), res, fn, bits)
+	return name
+}
+
+// typeString returns the representation of t in the generated code, and
+// imports the packages that it references.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		if pkg == g.pkg {
+			return ""
+		}
+		g.use(pkg.Path())
+		return pkg.Name()
+	})
+}
+
+func (g *generator) parityTest(name string, fields []field, validate bool) {
+	g.use("bytes")
+	g.use("math/rand")
+	g.use("reflect")
+	g.use("testing")
+	g.use("testing/quick")
+	g.use("github.com/segmentio/encoding/json")
+
+	g.printf(`
+// jsongenReflect%[1]s has the fields of %[1]s but none of its methods, its values
+// are encoded and decoded by the reflective codecs of the json package.%[2]s
+type jsongenReflect%[1]s %[1]s
+
+func TestJSONGen%[1]s(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 100 {
+		var v %[1]s
+
+		// Leave the first value empty, and fill the exported fields of the
+		// others with random values.
+		if i != 0 {
+`, name, validateComment(validate))
+	// Embedded struct pointers are randomly allocated, and the fields are only
+	// filled when the pointers on their path are not nil.
+	allocated := make(map[string]bool)
+	for _, f := range fields {
+		for i, p := range f.pointers {
+			if !allocated[p.name] {
+				allocated[p.name] = true
+				cond := strings.Join(append(pointerConditions(f.pointers[:i]), "r.Intn(2) == 0"), " && ")
+				g.printf("if %s {\nv.%s = new(%s)\n}\n", cond, p.name, g.typeString(p.elem))
+			}
+		}
+	}
+	for _, f := range fields {
+		set := fmt.Sprintf("if x, ok := %[1]s(reflect.TypeOf(&v.%[2]s).Elem(), r); ok {\nreflect.ValueOf(&v.%[2]s).Elem().Set(x)\n}\n", g.quickHelper(), f.name)
+		if len(f.pointers) != 0 {
+			set = fmt.Sprintf("if %s {\n%s}\n", strings.Join(pointerConditions(f.pointers), " && "), set)
+		}
+		g.printf("%s", set)
+	}
+	g.printf(`}
+
+		// Map keys are sorted for the output to be deterministic.
+		for _, flags := range []json.AppendFlags{json.SortMapKeys, json.SortMapKeys | json.EscapeHTML, json.SortMapKeys | json.EscapeHTML | json.EscapeNonASCII} {
+			want, wantErr := json.Append(nil, (*jsongenReflect%[1]s)(&v), flags)
+			got, gotErr := v.AppendJSON(nil, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %%v\nwant: %%v\ngot:  %%v", flags, wantErr, gotErr)
+			}
+			if !bytes.Equal(want, got) {
+				t.Fatalf("output mismatch with flags %%v\nwant: %%s\ngot:  %%s", flags, want, got)
+			}
+		}
+
+		b, err := json.Marshal((*jsongenReflect%[1]s)(&v))
+		if err != nil {
+			continue
+		}
+
+		for _, flags := range []json.ParseFlags{0, json.DontMatchCaseInsensitiveStructFields} {
+			var want jsongenReflect%[1]s
+			var got %[1]s
+
+			_, wantErr := json.Parse(b, &want, flags)
+			%[2]s_, gotErr := got.ParseJSON(b, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %%v\nwant: %%v\ngot:  %%v", flags, wantErr, gotErr)
+			}
+			if !reflect.DeepEqual(%[1]s(want), got) {
+				t.Fatalf("values mismatch with flags %%v\nwant: %%#v\ngot:  %%#v", flags, want, got)
+			}
+		}
+
+		// Truncated inputs must be rejected the same way.
+		for n := 0; n < len(b); n += 1 + len(b)/32 {
+			var want jsongenReflect%[1]s
+			var got %[1]s
+
+			_, wantErr := json.Parse(b[:n], &want, 0)
+			%[2]s_, gotErr := got.ParseJSON(b[:n], 0)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch on %%q\nwant: %%v\ngot:  %%v", b[:n], wantErr, gotErr)
+			}
+		}
+	}
+}
+`, name, validateTestCode(name, validate))
+}
+
+// quickHelper returns the name of the function generating random values in
+// tests, it is generated on first use.
+func (g *generator) quickHelper() string {
+	name := g.prefix + "Value"
+	if g.helpers[name] {
+		return name
+	}
+	g.helpers[name] = true
+
+	fmt.Fprintf(&g.helpersBuf, `
+// %[1]s returns a random value of type t, like quick.Value, or false if the
+// type has unexported fields that quick.Value cannot set.
+func %[1]s(t reflect.Type, r *rand.Rand) (v reflect.Value, ok bool) {
+	defer func() {
+		if recover() != nil {
+			v, ok = reflect.Value{}, false
+		}
+	}()
+	return quick.Value(t, r)
+}
+`, name)
+	return name
+}
+
+func validateComment(validate bool) string {
+	if !validate {
+		return ""
+	}
+	return "\n// The Validate method is called explicitly after decoding values."
+}
+
+func validateTestCode(name string, validate bool) string {
+	if !validate {
+		return ""
+	}
+	return fmt.Sprintf("if wantErr == nil {\nwantErr = (*%s)(&want).Validate()\n}\n", name)
+}
@@ -0,0 +1,1105 @@
+// Code generated by jsongen; DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+)
+
+// AppendJSON appends the JSON representation of v to b, it implements
+// json.MarshalerTo.
+func (v Event) AppendJSON(b []byte, flags json.AppendFlags) ([]byte, error) {
+	start := len(b)
+	var err error
+	var mark int
+	html := (flags & json.EscapeHTML) != 0
+	b = append(b, '{')
+	b = append(b, "\"id\":"...)
+	b = strconv.AppendUint(b, uint64(v.ID), 10)
+	b = append(b, ",\"name\":"...)
+	b = json.AppendEscape(b, string(v.Name), flags)
+	if v.Level != 0 {
+		b = append(b, ",\"level\":"...)
+		b = strconv.AppendInt(b, int64(v.Level), 10)
+	}
+	if v.Enabled {
+		b = append(b, ",\"enabled\":"...)
+		b = strconv.AppendBool(b, bool(v.Enabled))
+	}
+	b = append(b, ",\"score\":"...)
+	if f := float64(v.Score); math.IsNaN(f) || math.IsInf(f, 0) {
+		x := v.Score
+		if b, err = json.Append(b, &x, flags); err != nil {
+			return b[:start], err
+		}
+	} else {
+		abs, format := math.Abs(f), byte('f')
+		if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		b = strconv.AppendFloat(b, f, format, -1, 64)
+		if n := len(b); format == 'e' && n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	b = append(b, ",\"count\":"...)
+	b = append(b, '"')
+	b = strconv.AppendInt(b, int64(v.Count), 10)
+	b = append(b, '"')
+	if v.Ratio != 0 {
+		b = append(b, ",\"ratio\":"...)
+		if f := float64(v.Ratio); math.IsNaN(f) || math.IsInf(f, 0) {
+			x := v.Ratio
+			if b, err = json.Append(b, &x, flags); err != nil {
+				return b[:start], err
+			}
+		} else {
+			b = append(b, '"')
+			abs, format := math.Abs(f), byte('f')
+			if abs != 0 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+				format = 'e'
+			}
+			b = strconv.AppendFloat(b, f, format, -1, 32)
+			if n := len(b); format == 'e' && n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+				b[n-2] = b[n-1]
+				b = b[:n-1]
+			}
+			b = append(b, '"')
+		}
+	}
+	b = append(b, ",\"label\":"...)
+	mark = len(b)
+	b = json.AppendEscape(b, string(v.Label), flags)
+	b = jsongenEventQuote(b, mark)
+	if len(v.Tags) != 0 {
+		b = append(b, ",\"tags\":"...)
+		{
+			x := v.Tags
+			if b, err = json.Append(b, &x, flags); err != nil {
+				return b[:start], err
+			}
+		}
+	}
+	b = append(b, ",\"attrs\":"...)
+	{
+		x := v.Attrs
+		if b, err = json.Append(b, &x, flags); err != nil {
+			return b[:start], err
+		}
+	}
+	if v.Parent != nil {
+		b = append(b, ",\"parent\":"...)
+		{
+			x := v.Parent
+			if b, err = json.Append(b, &x, flags); err != nil {
+				return b[:start], err
+			}
+		}
+	}
+	if html {
+		b = append(b, ",\"\\u003chtml\\u003e\":"...)
+	} else {
+		b = append(b, ",\"<html>\":"...)
+	}
+	b = json.AppendEscape(b, string(v.HTML), flags)
+	b = append(b, ",\"-\":"...)
+	b = json.AppendEscape(b, string(v.Dash), flags)
+	b = append(b, ",\"Untagged\":"...)
+	b = strconv.AppendInt(b, int64(v.Untagged), 10)
+	if len(v.Metadata) != 0 {
+		b = append(b, ",\"Metadata\":"...)
+		{
+			x := v.Metadata
+			if b, err = json.Append(b, &x, flags); err != nil {
+				return b[:start], err
+			}
+		}
+	}
+	if v.Region != "" {
+		b = append(b, ',')
+		b = append(json.AppendEscape(b, "région", flags), ':')
+		b = json.AppendEscape(b, string(v.Region), flags)
+	}
+	return append(b, '}'), nil
+}
+
+// ParseJSON decodes the JSON value at the beginning of b into v, and returns
+// the remaining bytes. It behaves like json.Parse.
+func (v *Event) ParseJSON(b []byte, flags json.ParseFlags) ([]byte, error) {
+	input := b
+	// invalid lets the json package report the error of malformed input, or
+	// skip the object when the input is well-formed.
+	invalid := func() ([]byte, error) {
+		r, err := json.Parse(input, &struct{}{}, flags&^json.DisallowUnknownFields)
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			e.Type = reflect.TypeOf(v).Elem()
+		}
+		return r, err
+	}
+
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if bytes.HasPrefix(b, []byte("null")) {
+		return bytes.TrimLeft(b[4:], " \t\r\n"), nil
+	}
+	if len(b) == 0 || b[0] != '{' {
+		return invalid()
+	}
+
+	b = b[1:]
+	for i := 0; ; i++ {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) != 0 && b[0] == '}' {
+			return bytes.TrimLeft(b[1:], " \t\r\n"), nil
+		}
+		if i != 0 {
+			if len(b) == 0 || b[0] != ',' {
+				return invalid()
+			}
+			b = bytes.TrimLeft(b[1:], " \t\r\n")
+		}
+		if len(b) == 0 || b[0] != '"' {
+			return invalid()
+		}
+
+		key, n := jsongenEventString(b)
+		var err error
+		if n > 0 {
+			b = b[n:]
+		} else {
+			var s string
+			if b, err = jsongenEventParse(b, &s, flags); err != nil {
+				return invalid()
+			}
+			key = []byte(s)
+		}
+
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 || b[0] != ':' {
+			return invalid()
+		}
+		b = bytes.TrimLeft(b[1:], " \t\r\n")
+
+		f := -1
+		switch string(key) {
+		case "id":
+			f = 0
+		case "name":
+			f = 1
+		case "level":
+			f = 2
+		case "enabled":
+			f = 3
+		case "score":
+			f = 4
+		case "count":
+			f = 5
+		case "ratio":
+			f = 6
+		case "label":
+			f = 7
+		case "tags":
+			f = 8
+		case "attrs":
+			f = 9
+		case "parent":
+			f = 10
+		case "<html>":
+			f = 11
+		case "-":
+			f = 12
+		case "Untagged":
+			f = 13
+		case "Metadata":
+			f = 14
+		case "région":
+			f = 15
+		}
+		if f < 0 && (flags&json.DontMatchCaseInsensitiveStructFields) == 0 {
+			switch strings.ToLower(string(key)) {
+			case "id":
+				f = 0
+			case "name":
+				f = 1
+			case "level":
+				f = 2
+			case "enabled":
+				f = 3
+			case "score":
+				f = 4
+			case "count":
+				f = 5
+			case "ratio":
+				f = 6
+			case "label":
+				f = 7
+			case "tags":
+				f = 8
+			case "attrs":
+				f = 9
+			case "parent":
+				f = 10
+			case "<html>":
+				f = 11
+			case "-":
+				f = 12
+			case "untagged":
+				f = 13
+			case "metadata":
+				f = 14
+			case "région":
+				f = 15
+			}
+		}
+
+		switch f {
+		case 0:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseUint64(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.ID, b = uint64(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.ID, flags)
+			}
+		case 1:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Name, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Name, flags)
+			}
+		case 2:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseInt8(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.Level, b = Level(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Level, flags)
+			}
+		case 3:
+			switch {
+			case bytes.HasPrefix(b, []byte("true")):
+				v.Enabled, b = true, b[4:]
+			case bytes.HasPrefix(b, []byte("false")):
+				v.Enabled, b = false, b[5:]
+			default:
+				b, err = jsongenEventParse(b, &v.Enabled, flags)
+			}
+		case 4:
+			b, err = jsongenEventParse(b, &v.Score, flags)
+		case 5:
+			switch s, n := jsongenEventString(b); {
+			case n > 0:
+				if x, ok := jsongenEventParseInt(s); ok {
+					v.Count, b = int(x), b[n:]
+					break
+				}
+				fallthrough
+			default:
+				x := struct {
+					F int `json:",string"`
+				}{v.Count}
+				var raw json.RawMessage
+				if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+					if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+						v.Count = x.F
+					} else {
+						jsongenEventUnwrapError(err)
+					}
+				}
+			}
+		case 6:
+			x := struct {
+				F float32 `json:",string"`
+			}{v.Ratio}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Ratio = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 7:
+			x := struct {
+				F string `json:",string"`
+			}{v.Label}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Label = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 8:
+			b, err = jsongenEventParse(b, &v.Tags, flags)
+		case 9:
+			b, err = jsongenEventParse(b, &v.Attrs, flags)
+		case 10:
+			b, err = jsongenEventParse(b, &v.Parent, flags)
+		case 11:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.HTML, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.HTML, flags)
+			}
+		case 12:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Dash, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Dash, flags)
+			}
+		case 13:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseInt32(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.Untagged, b = int32(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Untagged, flags)
+			}
+		case 14:
+			b, err = jsongenEventParse(b, &v.Metadata, flags)
+		case 15:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Region, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Region, flags)
+			}
+		default:
+			if (flags & json.DisallowUnknownFields) != 0 {
+				return b, fmt.Errorf("json: unknown field %q", key)
+			}
+			var raw json.RawMessage
+			b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage)
+		}
+
+		if err != nil {
+			// Syntax errors take precedence over type errors, which are
+			// reported after skipping the object.
+			r, e := invalid()
+			if e != nil {
+				return r, e
+			}
+			switch e := err.(type) {
+			case *json.UnmarshalTypeError:
+				e.Struct = reflect.TypeOf(v).Elem().String() + e.Struct
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.MissingFieldError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.ValidationError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			}
+			return r, err
+		}
+	}
+}
+
+// AppendJSON appends the JSON representation of v to b, it implements
+// json.MarshalerTo.
+func (v Point) AppendJSON(b []byte, flags json.AppendFlags) ([]byte, error) {
+	more := false
+	b = append(b, '{')
+	if v.X != 0 {
+		b = append(b, "\"X\":"...)
+		b = strconv.AppendInt(b, int64(v.X), 10)
+		more = true
+	}
+	if v.Y != 0 {
+		if more {
+			b = append(b, ',')
+		}
+		b = append(b, "\"Y\":"...)
+		b = strconv.AppendInt(b, int64(v.Y), 10)
+		more = true
+	}
+	if more {
+		b = append(b, ',')
+	}
+	b = append(b, "\"Label\":"...)
+	b = json.AppendEscape(b, string(v.Label), flags)
+	return append(b, '}'), nil
+}
+
+// ParseJSON decodes the JSON value at the beginning of b into v, and returns
+// the remaining bytes. It behaves like json.Parse.
+func (v *Point) ParseJSON(b []byte, flags json.ParseFlags) ([]byte, error) {
+	input := b
+	// invalid lets the json package report the error of malformed input, or
+	// skip the object when the input is well-formed.
+	invalid := func() ([]byte, error) {
+		r, err := json.Parse(input, &struct{}{}, flags&^json.DisallowUnknownFields)
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			e.Type = reflect.TypeOf(v).Elem()
+		}
+		return r, err
+	}
+
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if bytes.HasPrefix(b, []byte("null")) {
+		return bytes.TrimLeft(b[4:], " \t\r\n"), nil
+	}
+	if len(b) == 0 || b[0] != '{' {
+		return invalid()
+	}
+
+	b = b[1:]
+	for i := 0; ; i++ {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) != 0 && b[0] == '}' {
+			if err := v.Validate(); err != nil {
+				return bytes.TrimLeft(b[1:], " \t\r\n"), &json.ValidationError{Type: reflect.TypeOf(v).Elem(), Err: err}
+			}
+			return bytes.TrimLeft(b[1:], " \t\r\n"), nil
+		}
+		if i != 0 {
+			if len(b) == 0 || b[0] != ',' {
+				return invalid()
+			}
+			b = bytes.TrimLeft(b[1:], " \t\r\n")
+		}
+		if len(b) == 0 || b[0] != '"' {
+			return invalid()
+		}
+
+		key, n := jsongenEventString(b)
+		var err error
+		if n > 0 {
+			b = b[n:]
+		} else {
+			var s string
+			if b, err = jsongenEventParse(b, &s, flags); err != nil {
+				return invalid()
+			}
+			key = []byte(s)
+		}
+
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 || b[0] != ':' {
+			return invalid()
+		}
+		b = bytes.TrimLeft(b[1:], " \t\r\n")
+
+		f := -1
+		switch string(key) {
+		case "X":
+			f = 0
+		case "Y":
+			f = 1
+		case "Label":
+			f = 2
+		}
+		if f < 0 && (flags&json.DontMatchCaseInsensitiveStructFields) == 0 {
+			switch strings.ToLower(string(key)) {
+			case "x":
+				f = 0
+			case "y":
+				f = 1
+			case "label":
+				f = 2
+			}
+		}
+
+		switch f {
+		case 0:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseInt32(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.X, b = int32(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.X, flags)
+			}
+		case 1:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseInt32(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.Y, b = int32(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Y, flags)
+			}
+		case 2:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Label, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Label, flags)
+			}
+		default:
+			if (flags & json.DisallowUnknownFields) != 0 {
+				return b, fmt.Errorf("json: unknown field %q", key)
+			}
+			var raw json.RawMessage
+			b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage)
+		}
+
+		if err != nil {
+			// Syntax errors take precedence over type errors, which are
+			// reported after skipping the object.
+			r, e := invalid()
+			if e != nil {
+				return r, e
+			}
+			switch e := err.(type) {
+			case *json.UnmarshalTypeError:
+				e.Struct = reflect.TypeOf(v).Elem().String() + e.Struct
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.MissingFieldError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.ValidationError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			}
+			return r, err
+		}
+	}
+}
+
+// AppendJSON appends the JSON representation of v to b, it implements
+// json.MarshalerTo.
+func (v Record) AppendJSON(b []byte, flags json.AppendFlags) ([]byte, error) {
+	start := len(b)
+	var err error
+	var mark int
+	b = append(b, '{')
+	b = append(b, "\"id\":"...)
+	b = strconv.AppendUint(b, uint64(v.Base.ID), 10)
+	if v.Meta != nil && v.Meta.Owner != "" {
+		b = append(b, ",\"Owner\":"...)
+		b = json.AppendEscape(b, string(v.Meta.Owner), flags)
+	}
+	if v.audit != nil && v.audit.By != "" {
+		b = append(b, ",\"by\":"...)
+		b = json.AppendEscape(b, string(v.audit.By), flags)
+	}
+	b = append(b, ",\"kind\":"...)
+	b = json.AppendEscape(b, string(v.Kind), flags)
+	b = append(b, ",\"priority\":"...)
+	mark = len(b)
+	if b, err = json.Append(b, &struct {
+		F int `json:",default=3"`
+	}{v.Priority}, flags); err != nil {
+		return b[:start], err
+	}
+	b = jsongenEventUnwrap(b, mark)
+	if len(v.Labels) != 0 {
+		b = append(b, ",\"labels\":"...)
+		mark = len(b)
+		if b, err = json.Append(b, &struct {
+			F []string `json:",default=[\"new\"]"`
+		}{v.Labels}, flags); err != nil {
+			return b[:start], err
+		}
+		b = jsongenEventUnwrap(b, mark)
+	}
+	if v.Timeout != 0 {
+		b = append(b, ",\"timeout\":"...)
+		mark = len(b)
+		if b, err = json.Append(b, &struct {
+			F time.Duration `json:",format:milli"`
+		}{v.Timeout}, flags); err != nil {
+			return b[:start], err
+		}
+		b = jsongenEventUnwrap(b, mark)
+	}
+	b = append(b, ",\"created\":"...)
+	mark = len(b)
+	if b, err = json.Append(b, &struct {
+		F time.Time `json:",format:'Jan-2,2006'"`
+	}{v.Created}, flags); err != nil {
+		return b[:start], err
+	}
+	b = jsongenEventUnwrap(b, mark)
+	if len(v.Data) != 0 {
+		b = append(b, ",\"data\":"...)
+		mark = len(b)
+		if b, err = json.Append(b, &struct {
+			F []byte `json:",format:hex"`
+		}{v.Data}, flags); err != nil {
+			return b[:start], err
+		}
+		b = jsongenEventUnwrap(b, mark)
+	}
+	if v.Ratio != nil {
+		b = append(b, ",\"ratio\":"...)
+		mark = len(b)
+		if b, err = json.Append(b, &struct {
+			F *float64 `json:",string"`
+		}{v.Ratio}, flags); err != nil {
+			return b[:start], err
+		}
+		b = jsongenEventUnwrap(b, mark)
+	}
+	if v.Note != "" {
+		b = append(b, ",\"note\":"...)
+		mark = len(b)
+		b = json.AppendEscape(b, string(v.Note), flags)
+		b = jsongenEventQuote(b, mark)
+	}
+	return append(b, '}'), nil
+}
+
+// ParseJSON decodes the JSON value at the beginning of b into v, and returns
+// the remaining bytes. It behaves like json.Parse.
+func (v *Record) ParseJSON(b []byte, flags json.ParseFlags) ([]byte, error) {
+	var seen [11]bool
+	input := b
+	// invalid lets the json package report the error of malformed input, or
+	// skip the object when the input is well-formed.
+	invalid := func() ([]byte, error) {
+		r, err := json.Parse(input, &struct{}{}, flags&^json.DisallowUnknownFields)
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			e.Type = reflect.TypeOf(v).Elem()
+		}
+		return r, err
+	}
+
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if bytes.HasPrefix(b, []byte("null")) {
+		return bytes.TrimLeft(b[4:], " \t\r\n"), nil
+	}
+	if len(b) == 0 || b[0] != '{' {
+		return invalid()
+	}
+
+	b = b[1:]
+	for i := 0; ; i++ {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) != 0 && b[0] == '}' {
+			if !seen[4] && (flags&json.MergeObjects) == 0 {
+				var err error
+				x := struct {
+					F int `json:",default=3"`
+				}{v.Priority}
+				if _, err = json.Parse([]byte("{}"), &x, flags); err != nil {
+					return bytes.TrimLeft(b[1:], " \t\r\n"), err
+				}
+				v.Priority = x.F
+			}
+			if !seen[5] && (flags&json.MergeObjects) == 0 {
+				var err error
+				x := struct {
+					F []string `json:",default=[\"new\"]"`
+				}{v.Labels}
+				if _, err = json.Parse([]byte("{}"), &x, flags); err != nil {
+					return bytes.TrimLeft(b[1:], " \t\r\n"), err
+				}
+				v.Labels = x.F
+			}
+			var missing []string
+			if !seen[3] {
+				missing = append(missing, "kind")
+			}
+			if missing != nil {
+				return bytes.TrimLeft(b[1:], " \t\r\n"), &json.MissingFieldError{Type: reflect.TypeOf(v).Elem(), Fields: missing}
+			}
+			return bytes.TrimLeft(b[1:], " \t\r\n"), nil
+		}
+		if i != 0 {
+			if len(b) == 0 || b[0] != ',' {
+				return invalid()
+			}
+			b = bytes.TrimLeft(b[1:], " \t\r\n")
+		}
+		if len(b) == 0 || b[0] != '"' {
+			return invalid()
+		}
+
+		key, n := jsongenEventString(b)
+		var err error
+		if n > 0 {
+			b = b[n:]
+		} else {
+			var s string
+			if b, err = jsongenEventParse(b, &s, flags); err != nil {
+				return invalid()
+			}
+			key = []byte(s)
+		}
+
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 || b[0] != ':' {
+			return invalid()
+		}
+		b = bytes.TrimLeft(b[1:], " \t\r\n")
+
+		f := -1
+		switch string(key) {
+		case "id":
+			f = 0
+		case "Owner":
+			f = 1
+		case "by":
+			f = 2
+		case "kind":
+			f = 3
+		case "priority":
+			f = 4
+		case "labels":
+			f = 5
+		case "timeout":
+			f = 6
+		case "created":
+			f = 7
+		case "data":
+			f = 8
+		case "ratio":
+			f = 9
+		case "note":
+			f = 10
+		case "type":
+			f = 3
+		}
+		if f < 0 && (flags&json.DontMatchCaseInsensitiveStructFields) == 0 {
+			switch strings.ToLower(string(key)) {
+			case "id":
+				f = 0
+			case "owner":
+				f = 1
+			case "by":
+				f = 2
+			case "kind":
+				f = 3
+			case "priority":
+				f = 4
+			case "labels":
+				f = 5
+			case "timeout":
+				f = 6
+			case "created":
+				f = 7
+			case "data":
+				f = 8
+			case "ratio":
+				f = 9
+			case "note":
+				f = 10
+			case "type":
+				f = 3
+			}
+		}
+
+		if f >= 0 {
+			seen[f] = true
+		}
+		switch f {
+		case 0:
+			n := 0
+			for n < len(b) && (b[n] == '-' || '0' <= b[n] && b[n] <= '9') {
+				n++
+			}
+			if x, ok := jsongenEventParseUint64(b[:n]); ok && (n == len(b) || b[n] != '.' && b[n] != 'e' && b[n] != 'E') {
+				v.Base.ID, b = uint64(x), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Base.ID, flags)
+			}
+		case 1:
+			if v.Meta == nil {
+				v.Meta = new(Meta)
+			}
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Meta.Owner, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Meta.Owner, flags)
+			}
+		case 2:
+			if v.audit == nil {
+				err = fmt.Errorf("json: cannot set embedded pointer to unexported struct: %s", reflect.TypeFor[audit]())
+				break
+			}
+			if s, n := jsongenEventString(b); n > 0 {
+				v.audit.By, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.audit.By, flags)
+			}
+		case 3:
+			if s, n := jsongenEventString(b); n > 0 {
+				v.Kind, b = string(s), b[n:]
+			} else {
+				b, err = jsongenEventParse(b, &v.Kind, flags)
+			}
+		case 4:
+			x := struct {
+				F int `json:",default=3"`
+			}{v.Priority}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Priority = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 5:
+			x := struct {
+				F []string `json:",default=[\"new\"]"`
+			}{v.Labels}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Labels = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 6:
+			x := struct {
+				F time.Duration `json:",format:milli"`
+			}{v.Timeout}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Timeout = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 7:
+			x := struct {
+				F time.Time `json:",format:'Jan-2,2006'"`
+			}{v.Created}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Created = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 8:
+			x := struct {
+				F []byte `json:",format:hex"`
+			}{v.Data}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Data = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 9:
+			x := struct {
+				F *float64 `json:",string"`
+			}{v.Ratio}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Ratio = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		case 10:
+			x := struct {
+				F string `json:",string"`
+			}{v.Note}
+			var raw json.RawMessage
+			if b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage); err == nil {
+				if _, err = json.Parse(append(append([]byte("{\"F\":"), raw...), '}'), &x, flags); err == nil {
+					v.Note = x.F
+				} else {
+					jsongenEventUnwrapError(err)
+				}
+			}
+		default:
+			if (flags & json.DisallowUnknownFields) != 0 {
+				return b, fmt.Errorf("json: unknown field %q", key)
+			}
+			var raw json.RawMessage
+			b, err = jsongenEventParse(b, &raw, flags|json.DontCopyRawMessage)
+		}
+
+		if err != nil {
+			// Syntax errors take precedence over type errors, which are
+			// reported after skipping the object.
+			r, e := invalid()
+			if e != nil {
+				return r, e
+			}
+			switch e := err.(type) {
+			case *json.UnmarshalTypeError:
+				e.Struct = reflect.TypeOf(v).Elem().String() + e.Struct
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.MissingFieldError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			case *json.ValidationError:
+				e.Field = strings.TrimSuffix(string(key)+"."+e.Field, ".")
+			}
+			return r, err
+		}
+	}
+}
+
+// jsongenEventQuote encodes the JSON value b[mark:] as a JSON string, in place. The value
+// was encoded with the same flags, so its quotes and backslashes are the only
+// characters that need to be escaped.
+func jsongenEventQuote(b []byte, mark int) []byte {
+	n := 0
+	for _, c := range b[mark:] {
+		if c == '"' || c == '\\' {
+			n++
+		}
+	}
+	i := len(b)
+	b = append(b, make([]byte, n+2)...)
+	j := len(b) - 1
+	b[j] = '"'
+	for i > mark {
+		i--
+		j--
+		b[j] = b[i]
+		if c := b[i]; c == '"' || c == '\\' {
+			j--
+			b[j] = '\\'
+		}
+	}
+	b[mark] = '"'
+	return b
+}
+
+// jsongenEventString returns the content of the JSON string at the beginning of b and its
+// length, or a zero length if the string is not made of printable ASCII
+// characters only, or has escape sequences.
+func jsongenEventString(b []byte) ([]byte, int) {
+	if len(b) == 0 || b[0] != '"' {
+		return nil, 0
+	}
+	for i := 1; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			return b[1:i], i + 1
+		case c == '\\', c < 0x20, c > 0x7e:
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+
+// jsongenEventParse decodes the value at the beginning of b into x, like json.Parse, but
+// bounds the input so the json package does not scan beyond the value.
+func jsongenEventParse(b []byte, x any, flags json.ParseFlags) ([]byte, error) {
+	n, depth := len(b), 0
+scan:
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				n = min(i+1, len(b))
+				break scan
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth--; depth <= 0 {
+				n = i + max(depth+1, 0)
+				break scan
+			}
+		case ',', ':', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				n = i
+				break scan
+			}
+		}
+	}
+	r, err := json.Parse(b[:n], x, flags)
+	return b[n-len(r):], err
+}
+
+// jsongenEventParseUint64 parses b as a uint64, it reports false if b is not in the simplest
+// form of the integer, or the value overflows.
+func jsongenEventParseUint64(b []byte) (uint64, bool) {
+	i := 0
+	if len(b) != 0 && b[0] == '-' {
+		i = 1
+	}
+	if len(b) == i || (b[i] == '0' && len(b) != i+1) {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(string(b), 10, 64)
+	return x, err == nil
+}
+
+// jsongenEventParseInt8 parses b as a int8, it reports false if b is not in the simplest
+// form of the integer, or the value overflows.
+func jsongenEventParseInt8(b []byte) (int64, bool) {
+	i := 0
+	if len(b) != 0 && b[0] == '-' {
+		i = 1
+	}
+	if len(b) == i || (b[i] == '0' && len(b) != i+1) {
+		return 0, false
+	}
+	x, err := strconv.ParseInt(string(b), 10, 8)
+	return x, err == nil
+}
+
+// jsongenEventParseInt parses b as a int, it reports false if b is not in the simplest
+// form of the integer, or the value overflows.
+func jsongenEventParseInt(b []byte) (int64, bool) {
+	i := 0
+	if len(b) != 0 && b[0] == '-' {
+		i = 1
+	}
+	if len(b) == i || (b[i] == '0' && len(b) != i+1) {
+		return 0, false
+	}
+	x, err := strconv.ParseInt(string(b), 10, 0)
+	return x, err == nil
+}
+
+// jsongenEventUnwrapError removes the field of the wrapper struct from the path of err, the
+// caller prepends the key of the wrapped field.
+func jsongenEventUnwrapError(err error) {
+	unwrap := func(field string) string {
+		return strings.TrimPrefix(strings.TrimPrefix(field, "F"), ".")
+	}
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		e.Struct, e.Field = "", unwrap(e.Field)
+	case *json.MissingFieldError:
+		e.Field = unwrap(e.Field)
+	case *json.ValidationError:
+		e.Field = unwrap(e.Field)
+	}
+}
+
+// jsongenEventParseInt32 parses b as a int32, it reports false if b is not in the simplest
+// form of the integer, or the value overflows.
+func jsongenEventParseInt32(b []byte) (int64, bool) {
+	i := 0
+	if len(b) != 0 && b[0] == '-' {
+		i = 1
+	}
+	if len(b) == i || (b[i] == '0' && len(b) != i+1) {
+		return 0, false
+	}
+	x, err := strconv.ParseInt(string(b), 10, 32)
+	return x, err == nil
+}
+
+// jsongenEventUnwrap replaces the object {"F":value} at b[mark:] with its value.
+func jsongenEventUnwrap(b []byte, mark int) []byte {
+	const prefix = len(`{"F":`)
+	n := copy(b[mark:], b[mark+prefix:len(b)-1])
+	return b[:mark+n]
+}
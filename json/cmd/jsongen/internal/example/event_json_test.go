@@ -0,0 +1,320 @@
+// Code generated by jsongen; DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/segmentio/encoding/json"
+)
+
+// jsongenReflectEvent has the fields of Event but none of its methods, its values
+// are encoded and decoded by the reflective codecs of the json package.
+type jsongenReflectEvent Event
+
+func TestJSONGenEvent(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 100 {
+		var v Event
+
+		// Leave the first value empty, and fill the exported fields of the
+		// others with random values.
+		if i != 0 {
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.ID).Elem(), r); ok {
+				reflect.ValueOf(&v.ID).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Name).Elem(), r); ok {
+				reflect.ValueOf(&v.Name).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Level).Elem(), r); ok {
+				reflect.ValueOf(&v.Level).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Enabled).Elem(), r); ok {
+				reflect.ValueOf(&v.Enabled).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Score).Elem(), r); ok {
+				reflect.ValueOf(&v.Score).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Count).Elem(), r); ok {
+				reflect.ValueOf(&v.Count).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Ratio).Elem(), r); ok {
+				reflect.ValueOf(&v.Ratio).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Label).Elem(), r); ok {
+				reflect.ValueOf(&v.Label).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Tags).Elem(), r); ok {
+				reflect.ValueOf(&v.Tags).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Attrs).Elem(), r); ok {
+				reflect.ValueOf(&v.Attrs).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Parent).Elem(), r); ok {
+				reflect.ValueOf(&v.Parent).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.HTML).Elem(), r); ok {
+				reflect.ValueOf(&v.HTML).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Dash).Elem(), r); ok {
+				reflect.ValueOf(&v.Dash).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Untagged).Elem(), r); ok {
+				reflect.ValueOf(&v.Untagged).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Metadata).Elem(), r); ok {
+				reflect.ValueOf(&v.Metadata).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Region).Elem(), r); ok {
+				reflect.ValueOf(&v.Region).Elem().Set(x)
+			}
+		}
+
+		// Map keys are sorted for the output to be deterministic.
+		for _, flags := range []json.AppendFlags{json.SortMapKeys, json.SortMapKeys | json.EscapeHTML, json.SortMapKeys | json.EscapeHTML | json.EscapeNonASCII} {
+			want, wantErr := json.Append(nil, (*jsongenReflectEvent)(&v), flags)
+			got, gotErr := v.AppendJSON(nil, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !bytes.Equal(want, got) {
+				t.Fatalf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, got)
+			}
+		}
+
+		b, err := json.Marshal((*jsongenReflectEvent)(&v))
+		if err != nil {
+			continue
+		}
+
+		for _, flags := range []json.ParseFlags{0, json.DontMatchCaseInsensitiveStructFields} {
+			var want jsongenReflectEvent
+			var got Event
+
+			_, wantErr := json.Parse(b, &want, flags)
+			_, gotErr := got.ParseJSON(b, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !reflect.DeepEqual(Event(want), got) {
+				t.Fatalf("values mismatch with flags %v\nwant: %#v\ngot:  %#v", flags, want, got)
+			}
+		}
+
+		// Truncated inputs must be rejected the same way.
+		for n := 0; n < len(b); n += 1 + len(b)/32 {
+			var want jsongenReflectEvent
+			var got Event
+
+			_, wantErr := json.Parse(b[:n], &want, 0)
+			_, gotErr := got.ParseJSON(b[:n], 0)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch on %q\nwant: %v\ngot:  %v", b[:n], wantErr, gotErr)
+			}
+		}
+	}
+}
+
+// jsongenReflectPoint has the fields of Point but none of its methods, its values
+// are encoded and decoded by the reflective codecs of the json package.
+// The Validate method is called explicitly after decoding values.
+type jsongenReflectPoint Point
+
+func TestJSONGenPoint(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 100 {
+		var v Point
+
+		// Leave the first value empty, and fill the exported fields of the
+		// others with random values.
+		if i != 0 {
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.X).Elem(), r); ok {
+				reflect.ValueOf(&v.X).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Y).Elem(), r); ok {
+				reflect.ValueOf(&v.Y).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Label).Elem(), r); ok {
+				reflect.ValueOf(&v.Label).Elem().Set(x)
+			}
+		}
+
+		// Map keys are sorted for the output to be deterministic.
+		for _, flags := range []json.AppendFlags{json.SortMapKeys, json.SortMapKeys | json.EscapeHTML, json.SortMapKeys | json.EscapeHTML | json.EscapeNonASCII} {
+			want, wantErr := json.Append(nil, (*jsongenReflectPoint)(&v), flags)
+			got, gotErr := v.AppendJSON(nil, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !bytes.Equal(want, got) {
+				t.Fatalf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, got)
+			}
+		}
+
+		b, err := json.Marshal((*jsongenReflectPoint)(&v))
+		if err != nil {
+			continue
+		}
+
+		for _, flags := range []json.ParseFlags{0, json.DontMatchCaseInsensitiveStructFields} {
+			var want jsongenReflectPoint
+			var got Point
+
+			_, wantErr := json.Parse(b, &want, flags)
+			if wantErr == nil {
+				wantErr = (*Point)(&want).Validate()
+			}
+			_, gotErr := got.ParseJSON(b, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !reflect.DeepEqual(Point(want), got) {
+				t.Fatalf("values mismatch with flags %v\nwant: %#v\ngot:  %#v", flags, want, got)
+			}
+		}
+
+		// Truncated inputs must be rejected the same way.
+		for n := 0; n < len(b); n += 1 + len(b)/32 {
+			var want jsongenReflectPoint
+			var got Point
+
+			_, wantErr := json.Parse(b[:n], &want, 0)
+			if wantErr == nil {
+				wantErr = (*Point)(&want).Validate()
+			}
+			_, gotErr := got.ParseJSON(b[:n], 0)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch on %q\nwant: %v\ngot:  %v", b[:n], wantErr, gotErr)
+			}
+		}
+	}
+}
+
+// jsongenReflectRecord has the fields of Record but none of its methods, its values
+// are encoded and decoded by the reflective codecs of the json package.
+type jsongenReflectRecord Record
+
+func TestJSONGenRecord(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 100 {
+		var v Record
+
+		// Leave the first value empty, and fill the exported fields of the
+		// others with random values.
+		if i != 0 {
+			if r.Intn(2) == 0 {
+				v.Meta = new(Meta)
+			}
+			if r.Intn(2) == 0 {
+				v.audit = new(audit)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Base.ID).Elem(), r); ok {
+				reflect.ValueOf(&v.Base.ID).Elem().Set(x)
+			}
+			if v.Meta != nil {
+				if x, ok := jsongenEventValue(reflect.TypeOf(&v.Meta.Owner).Elem(), r); ok {
+					reflect.ValueOf(&v.Meta.Owner).Elem().Set(x)
+				}
+			}
+			if v.audit != nil {
+				if x, ok := jsongenEventValue(reflect.TypeOf(&v.audit.By).Elem(), r); ok {
+					reflect.ValueOf(&v.audit.By).Elem().Set(x)
+				}
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Kind).Elem(), r); ok {
+				reflect.ValueOf(&v.Kind).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Priority).Elem(), r); ok {
+				reflect.ValueOf(&v.Priority).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Labels).Elem(), r); ok {
+				reflect.ValueOf(&v.Labels).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Timeout).Elem(), r); ok {
+				reflect.ValueOf(&v.Timeout).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Created).Elem(), r); ok {
+				reflect.ValueOf(&v.Created).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Data).Elem(), r); ok {
+				reflect.ValueOf(&v.Data).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Ratio).Elem(), r); ok {
+				reflect.ValueOf(&v.Ratio).Elem().Set(x)
+			}
+			if x, ok := jsongenEventValue(reflect.TypeOf(&v.Note).Elem(), r); ok {
+				reflect.ValueOf(&v.Note).Elem().Set(x)
+			}
+		}
+
+		// Map keys are sorted for the output to be deterministic.
+		for _, flags := range []json.AppendFlags{json.SortMapKeys, json.SortMapKeys | json.EscapeHTML, json.SortMapKeys | json.EscapeHTML | json.EscapeNonASCII} {
+			want, wantErr := json.Append(nil, (*jsongenReflectRecord)(&v), flags)
+			got, gotErr := v.AppendJSON(nil, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !bytes.Equal(want, got) {
+				t.Fatalf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, got)
+			}
+		}
+
+		b, err := json.Marshal((*jsongenReflectRecord)(&v))
+		if err != nil {
+			continue
+		}
+
+		for _, flags := range []json.ParseFlags{0, json.DontMatchCaseInsensitiveStructFields} {
+			var want jsongenReflectRecord
+			var got Record
+
+			_, wantErr := json.Parse(b, &want, flags)
+			_, gotErr := got.ParseJSON(b, flags)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch with flags %v\nwant: %v\ngot:  %v", flags, wantErr, gotErr)
+			}
+			if !reflect.DeepEqual(Record(want), got) {
+				t.Fatalf("values mismatch with flags %v\nwant: %#v\ngot:  %#v", flags, want, got)
+			}
+		}
+
+		// Truncated inputs must be rejected the same way.
+		for n := 0; n < len(b); n += 1 + len(b)/32 {
+			var want jsongenReflectRecord
+			var got Record
+
+			_, wantErr := json.Parse(b[:n], &want, 0)
+			_, gotErr := got.ParseJSON(b[:n], 0)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("errors mismatch on %q\nwant: %v\ngot:  %v", b[:n], wantErr, gotErr)
+			}
+		}
+	}
+}
+
+// jsongenEventValue returns a random value of type t, like quick.Value, or false if the
+// type has unexported fields that quick.Value cannot set.
+func jsongenEventValue(t reflect.Type, r *rand.Rand) (v reflect.Value, ok bool) {
+	defer func() {
+		if recover() != nil {
+			v, ok = reflect.Value{}, false
+		}
+	}()
+	return quick.Value(t, r)
+}
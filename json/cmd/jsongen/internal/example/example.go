@@ -0,0 +1,81 @@
+// Package example contains types with methods generated by jsongen, it is used
+// to test the command.
+package example
+
+import (
+	"errors"
+	"time"
+)
+
+//go:generate go run github.com/segmentio/encoding/json/cmd/jsongen -type Event,Point,Record
+
+// Level is a named type with a basic underlying type, its values are encoded
+// by the generated code.
+type Level int8
+
+type Event struct {
+	ID       uint64         `json:"id"`
+	Name     string         `json:"name"`
+	Level    Level          `json:"level,omitempty"`
+	Enabled  bool           `json:"enabled,omitempty"`
+	Score    float64        `json:"score"`
+	Count    int            `json:"count,string"`
+	Ratio    float32        `json:"ratio,string,omitempty"`
+	Label    string         `json:"label,string"`
+	Tags     []string       `json:"tags,omitempty"`
+	Attrs    map[string]int `json:"attrs"`
+	Parent   *Point         `json:"parent,omitempty"`
+	HTML     string         `json:"<html>"`
+	Ignored  string         `json:"-"`
+	Dash     string         `json:"-,"`
+	Untagged int32
+	Metadata map[string]string `json:",omitempty"`
+	Region   string            `json:"région,omitempty"`
+	private  int
+}
+
+type Point struct {
+	X, Y  int32 `json:",omitempty"`
+	Label string
+}
+
+// Validate rejects points with both coordinates negative, it is called after
+// decoding values of the type.
+func (p Point) Validate() error {
+	if p.X < 0 && p.Y < 0 {
+		return errors.New("coordinates must not both be negative")
+	}
+	return nil
+}
+
+// Record has embedded structs, whose fields are promoted to the objects that
+// values of Record are encoded as, and fields with options which the generated
+// code delegates to the json package.
+type Record struct {
+	Base
+	*Meta
+	*audit
+	Kind     string        `json:"kind,required,alias:type"`
+	Priority int           `json:"priority,default=3"`
+	Labels   []string      `json:"labels,omitempty,default=[\"new\"]"`
+	Timeout  time.Duration `json:"timeout,format:milli,omitempty"`
+	Created  time.Time     `json:"created,format:'Jan-2,2006'"`
+	Data     []byte        `json:"data,format:hex,omitempty"`
+	Ratio    *float64      `json:"ratio,string,omitempty"`
+	Note     string        `json:"note,string,omitempty"`
+}
+
+type Base struct {
+	ID   uint64 `json:"id"`
+	Name string // ambiguous with Meta.Name, neither is encoded
+}
+
+type Meta struct {
+	Name  string
+	Owner string `json:"Owner,omitempty"`
+}
+
+type audit struct {
+	Owner string // Meta.Owner is tagged and takes precedence
+	By    string `json:"by,omitempty"`
+}
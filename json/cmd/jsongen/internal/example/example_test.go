@@ -0,0 +1,257 @@
+package example
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+)
+
+func TestParseJSONErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		flags json.ParseFlags
+	}{
+		{input: `{"id":"1"}`},
+		{input: `{"parent":{"X":true}}`},
+		{input: `{"parent":{"X":-1,"Y":-1}}`},
+		{input: `{"level":1000}`},
+		{input: `{"count":"1.5"}`},
+		{input: `{"count":1}`},
+		{input: `{"enabled":1}`},
+		{input: `{"tags":{}}`},
+		{input: `{"ID":1,"NAME":"A","Parent":{"x":1}}`},
+		{input: `{"ID":1}`, flags: json.DontMatchCaseInsensitiveStructFields},
+		{input: `{"unknown":1}`, flags: json.DisallowUnknownFields},
+		{input: `{"id":1,}`},
+		{input: `{"id" 1}`},
+		{input: `[]`},
+		{input: `"event"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var want jsongenReflectEvent
+			var got Event
+
+			wantRest, wantErr := json.Parse([]byte(test.input), &want, test.flags)
+			gotRest, gotErr := got.ParseJSON([]byte(test.input), test.flags)
+
+			if !reflect.DeepEqual(normalizeError(wantErr), normalizeError(gotErr)) {
+				t.Errorf("errors mismatch\nwant: %#v\ngot:  %#v", wantErr, gotErr)
+			}
+			if string(wantRest) != string(gotRest) {
+				t.Errorf("remaining bytes mismatch\nwant: %q\ngot:  %q", wantRest, gotRest)
+			}
+			if !reflect.DeepEqual(Event(want), got) {
+				t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", want, got)
+			}
+		})
+	}
+}
+
+// normalizeError erases the differences between errors of the generated and
+// reflective decoders: the name of the types, and the values which may include
+// more of the input in errors of the reflective decoder.
+func normalizeError(err error) error {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		c := *e
+		c.Value = ""
+		c.Struct = strings.ReplaceAll(c.Struct, "jsongenReflect", "")
+		c.Type = normalizeType(c.Type)
+		return &c
+	case *json.MissingFieldError:
+		c := *e
+		c.Type = normalizeType(c.Type)
+		return &c
+	}
+	return err
+}
+
+func normalizeType(t reflect.Type) reflect.Type {
+	switch t {
+	case reflect.TypeOf(jsongenReflectEvent{}):
+		return reflect.TypeOf(Event{})
+	case reflect.TypeOf(jsongenReflectRecord{}):
+		return reflect.TypeOf(Record{})
+	}
+	return t
+}
+
+func TestAppendJSONMarshal(t *testing.T) {
+	ratio := 0.25
+	tests := []struct {
+		name   string
+		value  any
+		expect any
+	}{
+		{
+			name:   "string option escapes",
+			value:  &Event{Label: `a "quoted" \ <b>été</b>`, Name: "\u2028"},
+			expect: &jsongenReflectEvent{Label: `a "quoted" \ <b>été</b>`, Name: "\u2028"},
+		},
+		{
+			name:   "nil embedded pointers",
+			value:  &Record{Base: Base{ID: 1, Name: "base"}, Kind: "k"},
+			expect: &jsongenReflectRecord{Base: Base{ID: 1, Name: "base"}, Kind: "k"},
+		},
+		{
+			name:   "empty embedded pointers",
+			value:  &Record{Meta: &Meta{}, audit: &audit{}},
+			expect: &jsongenReflectRecord{Meta: &Meta{}, audit: &audit{}},
+		},
+		{
+			name: "embedded pointers and options",
+			value: &Record{
+				Meta:    &Meta{Name: "meta", Owner: "meta"},
+				audit:   &audit{Owner: "audit", By: "<audit>"},
+				Timeout: 1500 * time.Millisecond,
+				Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				Data:    []byte("data"),
+				Ratio:   &ratio,
+				Note:    `"note"`,
+			},
+			expect: &jsongenReflectRecord{
+				Meta:    &Meta{Name: "meta", Owner: "meta"},
+				audit:   &audit{Owner: "audit", By: "<audit>"},
+				Timeout: 1500 * time.Millisecond,
+				Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				Data:    []byte("data"),
+				Ratio:   &ratio,
+				Note:    `"note"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, flags := range []json.AppendFlags{0, json.EscapeHTML, json.EscapeNonASCII} {
+				want, err := json.Append(nil, test.expect, flags)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := json.Append(nil, test.value, flags)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(want) != string(got) {
+					t.Errorf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("non-finite floats", func(t *testing.T) {
+		v := Event{Score: math.Inf(1), Ratio: float32(math.NaN())}
+
+		for _, flags := range []json.AppendFlags{json.NonFiniteFloatsAsNull, json.NonFiniteFloatsAsString} {
+			want, err := json.Append(nil, (*jsongenReflectEvent)(&v), flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := v.AppendJSON(nil, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(want) != string(got) {
+				t.Errorf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, got)
+			}
+		}
+	})
+}
+
+func TestParseJSONRecord(t *testing.T) {
+	tests := []struct {
+		input string
+		flags json.ParseFlags
+		init  Record
+	}{
+		{input: `{"kind":"k"}`},
+		{input: `{"type":"k","priority":1,"labels":["a"]}`},
+		{input: `{"TYPE":"k","owner":"o","Name":"n"}`},
+		{input: `{"id":1}`},
+		{input: `{"kind":"k","by":"me"}`},
+		{input: `{"kind":"k","by":"me"}`, init: Record{audit: &audit{}}},
+		{input: `{"kind":"k","timeout":250,"created":"Mar-1,2024","data":"6869"}`},
+		{input: `{"kind":"k","created":"2024-03-01"}`},
+		{input: `{"kind":"k","ratio":"0.5","note":"\"n\""}`},
+		{input: `{"kind":"k","ratio":0.5}`},
+		{input: `{"kind":"k"}`, flags: json.MergeObjects, init: Record{Priority: 7}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			want := jsongenReflectRecord(test.init)
+			got := test.init
+
+			wantRest, wantErr := json.Parse([]byte(test.input), &want, test.flags)
+			gotRest, gotErr := got.ParseJSON([]byte(test.input), test.flags)
+
+			if !reflect.DeepEqual(normalizeError(wantErr), normalizeError(gotErr)) {
+				t.Errorf("errors mismatch\nwant: %v\ngot:  %v", wantErr, gotErr)
+			}
+			if string(wantRest) != string(gotRest) {
+				t.Errorf("remaining bytes mismatch\nwant: %q\ngot:  %q", wantRest, gotRest)
+			}
+			if !reflect.DeepEqual(Record(want), got) {
+				t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", want, got)
+			}
+		})
+	}
+}
+
+var benchmarkEvent = Event{
+	ID:       42,
+	Name:     "hello",
+	Level:    3,
+	Enabled:  true,
+	Score:    0.5,
+	Count:    10,
+	Label:    "label",
+	Tags:     []string{"a", "b"},
+	Attrs:    map[string]int{"x": 1},
+	Parent:   &Point{X: 1, Y: 2, Label: "parent"},
+	HTML:     "<p>",
+	Untagged: -1,
+}
+
+var benchmarkPoint = Point{X: 1234, Y: -5678, Label: "hello world"}
+
+func BenchmarkAppendJSON(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+
+	b.Run("Event", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf, _ = benchmarkEvent.AppendJSON(buf[:0], json.EscapeHTML)
+		}
+	})
+
+	b.Run("Point", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf, _ = benchmarkPoint.AppendJSON(buf[:0], json.EscapeHTML)
+		}
+	})
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	event, _ := json.Marshal(&benchmarkEvent)
+	point, _ := json.Marshal(&benchmarkPoint)
+
+	b.Run("Event", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var v Event
+			v.ParseJSON(event, 0)
+		}
+	})
+
+	b.Run("Point", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var v Point
+			v.ParseJSON(point, 0)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// Command jsongen generates specialized JSON encoding and decoding methods for
+// named struct types, which avoid the dynamic dispatch of the codecs of the
+// github.com/segmentio/encoding/json package.
+//
+// Usage:
+//
+//	jsongen -type T[,U...] [-output file] [dir]
+//
+// For each type, the command generates the methods:
+//
+//	func (v T) AppendJSON(b []byte, flags json.AppendFlags) ([]byte, error)
+//	func (v *T) ParseJSON(b []byte, flags json.ParseFlags) ([]byte, error)
+//
+// AppendJSON implements json.MarshalerTo, which the json package prefers over
+// its reflective codecs. ParseJSON behaves like json.Parse, and must be called
+// directly: json.Unmarshaler does not carry the parse flags, so the command
+// does not generate an UnmarshalJSON method.
+//
+// The struct tags are interpreted with the same semantics as the json package.
+// Fields are encoded as if the value was addressable (e.g. when encoding a
+// pointer to the value), and fields of types other than booleans, strings, and
+// numbers are delegated to the json package. The fields of embedded structs are
+// promoted with the same rules for ambiguous names, and fields with the format:
+// or default= tag options are encoded and decoded by the json package, as the
+// only field of a struct with the same options. The command fails on fields
+// with the discriminator: option, on fields of the struct which have the same
+// name, and on embedded types with marshaling methods.
+//
+// The command also generates a test file asserting that the generated methods
+// produce the same results as the reflective codecs of the json package.
+//
+// It is typically invoked with a go:generate directive:
+//
+//	//go:generate go run github.com/segmentio/encoding/json/cmd/jsongen -type Event
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of type names, required")
+	output := flag.String("output", "", "output file name, defaults to <type>_json.go")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jsongen -type T[,U...] [-output file] [dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+
+	names := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = strings.ToLower(names[0]) + "_json.go"
+	}
+	if !filepath.IsAbs(*output) {
+		*output = filepath.Join(dir, *output)
+	}
+
+	if err := run(dir, *output, names); err != nil {
+		fmt.Fprintf(os.Stderr, "jsongen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, output string, names []string) error {
+	pkg, err := loadPackage(dir, filepath.Base(output))
+	if err != nil {
+		return err
+	}
+
+	code, test, err := generate(pkg, names)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSource(output, code); err != nil {
+		return err
+	}
+	return writeSource(strings.TrimSuffix(output, ".go")+"_test.go", test)
+}
+
+// loadPackage parses and type-checks the package in dir, ignoring test files
+// and the output file of a previous invocation.
+func loadPackage(dir, output string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+
+	for _, name := range bp.GoFiles {
+		if name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	// Type errors are ignored, the code of the package may reference the
+	// methods of a previous output, which is excluded.
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+	if pkg == nil {
+		return nil, errors.New("cannot type-check package in " + dir)
+	}
+	return pkg, nil
+}
+
+func writeSource(path string, src []byte) error {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("formatting generated code of %s: %w", path, err)
+	}
+	return os.WriteFile(path, formatted, 0o644)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate asserts that the generated files of the example package are up
+// to date, the tests of the example package then validate the generated code.
+func TestGenerate(t *testing.T) {
+	const dir = "internal/example"
+	tmp := t.TempDir()
+
+	if err := run(dir, filepath.Join(tmp, "event_json.go"), []string{"Event", "Point", "Record"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"event_json.go", "event_json_test.go"} {
+		want, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(tmp, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Errorf("%s is out of date, run go generate in %s", name, dir)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{
+			src: "type T struct { A int `json:\"a\"`; B int `json:\"a\"` }",
+			err: `T: fields A and B have the same name "a"`,
+		},
+		{
+			src: "type T struct { *T }",
+			err: "T.T: recursive embedding of p.T",
+		},
+		{
+			src: "type T struct { U }; type U struct { *T }",
+			err: "T.U.T: recursive embedding of p.T",
+		},
+		{
+			src: "type T struct { U }; type U struct{ A int }; func (U) MarshalJSON() ([]byte, error) { return nil, nil }",
+			err: "T.U: embedded type p.U has marshaling methods",
+		},
+		{
+			src: "type T struct { E fmt.Stringer `json:\"e,discriminator:type\"` }",
+			err: `T.E: tag option "discriminator:type" is not supported`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", "package p\nimport \"fmt\"\nvar _ fmt.Stringer\n"+test.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+			pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := generate(pkg, []string{"T"}); err == nil || err.Error() != test.err {
+				t.Errorf("error mismatch\nwant: %s\ngot:  %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestGenerateAmbiguousFields(t *testing.T) {
+	const src = `package p
+
+type T struct {
+	A
+	*B
+	C int ` + "`json:\"C\"`" + `
+}
+
+type A struct {
+	X int // ambiguous with B.X
+	Y int ` + "`json:\"Y\"`" + ` // dominates B.Y
+	C int // hidden by T.C
+}
+
+type B struct {
+	X int
+	Y int
+	Z int
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := pkg.Scope().Lookup("T")
+	fields, err := structFields(pkg, "T", obj.Type().(*types.Named), obj.Type().Underlying().(*types.Struct))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range fields {
+		got = append(got, f.name+":"+f.key)
+	}
+	if want := "A.Y:Y B.Z:Z C:C"; strings.Join(got, " ") != want {
+		t.Errorf("fields mismatch\nwant: %s\ngot:  %s", want, strings.Join(got, " "))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The functions in this file mirror the parsing of struct tags done by the
+// json package, the generated code must honor the exact same semantics.
+
+func splitTagOptions(tag string) []string {
+	parts := make([]string, 0, 4)
+	quote := byte(0)
+	i := 0
+
+	for j := 0; j < len(tag); j++ {
+		switch c := tag[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == ',':
+			parts = append(parts, tag[i:j])
+			i = j + 1
+		case (c == '\'' || c == '"') && j > 0 && tag[j-1] == ':' && len(parts) != 0:
+			quote = c
+		}
+	}
+
+	return append(parts, tag[i:])
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		default:
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func unquoteTagOption(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
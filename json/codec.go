@@ -7,15 +7,19 @@ import (
 	"maps"
 	"math/big"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unicode"
 	"unsafe"
 
+	"github.com/segmentio/asm/base64"
 	"github.com/segmentio/asm/keyset"
+	"github.com/segmentio/encoding/ascii"
 )
 
 const (
@@ -39,10 +43,19 @@ type encoder struct {
 	// goroutine runs out of stack space.
 	ptrDepth uint32
 	ptrSeen  map[unsafe.Pointer]struct{}
+	// stream is set when the value is encoded by an Encoder which can write
+	// the output while the value is being encoded, see flush.
+	stream *Encoder
 }
 
 type decoder struct {
 	flags ParseFlags
+	// Table used to intern strings when the InternStrings flag is set, the
+	// shared table is used when nil.
+	strings *StringTable
+	// Set when decoding the value of an interface registered with
+	// RegisterDiscriminator, see decodeDiscriminated.
+	discriminated *discriminated
 }
 
 type (
@@ -63,6 +76,14 @@ type (
 // lookup time for simple types like bool, int, etc..
 var cache atomic.Pointer[map[unsafe.Pointer]codec]
 
+// cacheMutex serializes the updates of the cache, and cacheGeneration is
+// incremented when the cache is discarded (see RegisterDiscriminator), so
+// codecs constructed before the cache was discarded are not stored in it.
+var (
+	cacheMutex      sync.Mutex
+	cacheGeneration atomic.Uint64
+)
+
 func cacheLoad() map[unsafe.Pointer]codec {
 	p := cache.Load()
 	if p == nil {
@@ -72,7 +93,15 @@ func cacheLoad() map[unsafe.Pointer]codec {
 	return *p
 }
 
-func cacheStore(typ reflect.Type, cod codec, oldCodecs map[unsafe.Pointer]codec) {
+func cacheStore(typ reflect.Type, cod codec, generation uint64) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if cacheGeneration.Load() != generation {
+		return
+	}
+
+	oldCodecs := cacheLoad()
 	newCodecs := make(map[unsafe.Pointer]codec, len(oldCodecs)+1)
 	maps.Copy(newCodecs, oldCodecs)
 	newCodecs[typeid(typ)] = cod
@@ -80,18 +109,39 @@ func cacheStore(typ reflect.Type, cod codec, oldCodecs map[unsafe.Pointer]codec)
 	cache.Store(&newCodecs)
 }
 
+// cacheReset discards all the codecs of the cache.
+func cacheReset() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	cacheGeneration.Add(1)
+	cache.Store(nil)
+}
+
 func typeid(t reflect.Type) unsafe.Pointer {
 	return (*iface)(unsafe.Pointer(&t)).ptr
 }
 
-func constructCachedCodec(t reflect.Type, cache map[unsafe.Pointer]codec) codec {
+func constructCachedCodec(t reflect.Type) codec {
+	generation := cacheGeneration.Load()
 	c := constructCodec(t, map[reflect.Type]*structType{}, t.Kind() == reflect.Ptr)
 
 	if inlined(t) {
 		c.encode = constructInlineValueEncodeFunc(c.encode)
 	}
 
-	cacheStore(t, c, cache)
+	cacheStore(t, c, generation)
+	return c
+}
+
+// codecOf returns the cached codec of t, constructing it if needed.
+func codecOf(t reflect.Type) codec {
+	c, found := cacheLoad()[typeid(t)]
+
+	if !found {
+		c = constructCachedCodec(t)
+	}
+
 	return c
 }
 
@@ -118,6 +168,15 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 	case rawMessageType:
 		c = codec{encode: encoder.encodeRawMessage, decode: decoder.decodeRawMessage}
 
+	case orderedObjectType:
+		c = codec{encode: encoder.encodeOrderedObject, decode: decoder.decodeOrderedObject}
+
+	case bigFloatType:
+		c = codec{encode: encoder.encodeBigFloat, decode: decoder.decodeBigFloat}
+
+	case bigRatType:
+		c = codec{encode: encoder.encodeBigRat, decode: decoder.decodeBigRat}
+
 	case numberPtrType:
 		c = constructPointerCodec(numberPtrType, nil)
 
@@ -129,6 +188,12 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 
 	case rawMessagePtrType:
 		c = constructPointerCodec(rawMessagePtrType, nil)
+
+	case bigFloatPtrType:
+		c = constructPointerCodec(bigFloatPtrType, nil)
+
+	case bigRatPtrType:
+		c = constructPointerCodec(bigRatPtrType, nil)
 	}
 
 	if c.encode != nil {
@@ -182,7 +247,11 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 		c = codec{encode: encoder.encodeString, decode: decoder.decodeString}
 
 	case reflect.Interface:
-		c = constructInterfaceCodec(t)
+		if disc := lookupDiscriminator(t); disc != nil {
+			c = constructDiscriminatorCodec(disc)
+		} else {
+			c = constructInterfaceCodec(t)
+		}
 
 	case reflect.Array:
 		c = constructArrayCodec(t, seen, canAddr)
@@ -199,6 +268,9 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 	case reflect.Ptr:
 		c = constructPointerCodec(t, seen)
 
+	case reflect.Func:
+		c = constructSeqCodec(t, seen)
+
 	default:
 		c = constructUnsupportedTypeCodec(t)
 	}
@@ -207,6 +279,8 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 
 	if canAddr {
 		switch {
+		case p.Implements(jsonMarshalerToType):
+			c.encode = constructJSONMarshalerToEncodeFunc(t, true)
 		case p.Implements(jsonMarshalerType):
 			c.encode = constructJSONMarshalerEncodeFunc(t, true)
 		case p.Implements(textMarshalerType):
@@ -215,6 +289,8 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 	}
 
 	switch {
+	case t.Implements(jsonMarshalerToType):
+		c.encode = constructJSONMarshalerToEncodeFunc(t, false)
 	case t.Implements(jsonMarshalerType):
 		c.encode = constructJSONMarshalerEncodeFunc(t, false)
 	case t.Implements(textMarshalerType):
@@ -222,12 +298,18 @@ func constructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr b
 	}
 
 	switch {
+	case p.Implements(jsonUnmarshalerFromType):
+		c.decode = constructJSONUnmarshalerFromDecodeFunc(t, true)
 	case p.Implements(jsonUnmarshalerType):
 		c.decode = constructJSONUnmarshalerDecodeFunc(t, true)
 	case p.Implements(textUnmarshalerType):
 		c.decode = constructTextUnmarshalerDecodeFunc(t, true)
 	}
 
+	if t.Kind() == reflect.Struct && p.Implements(validatorType) {
+		c.decode = constructValidatorDecodeFunc(t, c.decode)
+	}
+
 	return
 }
 
@@ -251,6 +333,18 @@ func constructStringDecodeFunc(decode decodeFunc) decodeFunc {
 	}
 }
 
+func constructStringFloatEncodeFunc(t reflect.Type, encode encodeFunc) encodeFunc {
+	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return e.encodeFloatToString(b, p, t, encode)
+	}
+}
+
+func constructStringFloatDecodeFunc(decode decodeFunc) decodeFunc {
+	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return d.decodeFloatFromString(b, p, decode)
+	}
+}
+
 func constructStringToIntDecodeFunc(t reflect.Type, decode decodeFunc) decodeFunc {
 	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
 		return d.decodeFromStringToInt(b, p, t, decode)
@@ -293,10 +387,14 @@ func constructSliceCodec(t reflect.Type, seen map[reflect.Type]*structType) code
 		c := codec{}
 
 		switch {
+		case e.Implements(jsonMarshalerToType):
+			c.encode = constructJSONMarshalerToEncodeFunc(e, false)
 		case e.Implements(jsonMarshalerType):
 			c.encode = constructJSONMarshalerEncodeFunc(e, false)
 		case e.Implements(textMarshalerType):
 			c.encode = constructTextMarshalerEncodeFunc(e, false)
+		case p.Implements(jsonMarshalerToType):
+			c.encode = constructJSONMarshalerToEncodeFunc(e, true)
 		case p.Implements(jsonMarshalerType):
 			c.encode = constructJSONMarshalerEncodeFunc(e, true)
 		case p.Implements(textMarshalerType):
@@ -304,10 +402,14 @@ func constructSliceCodec(t reflect.Type, seen map[reflect.Type]*structType) code
 		}
 
 		switch {
+		case e.Implements(jsonUnmarshalerFromType):
+			c.decode = constructJSONUnmarshalerFromDecodeFunc(e, false)
 		case e.Implements(jsonUnmarshalerType):
 			c.decode = constructJSONUnmarshalerDecodeFunc(e, false)
 		case e.Implements(textUnmarshalerType):
 			c.decode = constructTextUnmarshalerDecodeFunc(e, false)
+		case p.Implements(jsonUnmarshalerFromType):
+			c.decode = constructJSONUnmarshalerFromDecodeFunc(e, true)
 		case p.Implements(jsonUnmarshalerType):
 			c.decode = constructJSONUnmarshalerDecodeFunc(e, true)
 		case p.Implements(textUnmarshalerType):
@@ -349,7 +451,6 @@ func constructSliceDecodeFunc(size uintptr, t reflect.Type, decode decodeFunc) d
 }
 
 func constructMapCodec(t reflect.Type, seen map[reflect.Type]*structType) codec {
-	var sortKeys sortFunc
 	k := t.Key()
 	v := t.Elem()
 
@@ -386,9 +487,27 @@ func constructMapCodec(t reflect.Type, seen map[reflect.Type]*structType) codec
 		}
 	}
 
-	kc := codec{}
+	kc, sortKeys, ok := constructMapKeyCodec(k, seen)
+	if !ok {
+		return constructUnsupportedTypeCodec(t)
+	}
+
 	vc := constructCodec(v, seen, false)
 
+	if inlined(v) {
+		vc.encode = constructInlineValueEncodeFunc(vc.encode)
+	}
+
+	return codec{
+		encode: constructMapEncodeFunc(t, kc.encode, vc.encode, sortKeys),
+		decode: constructMapDecodeFunc(t, kc.decode, vc.decode),
+	}
+}
+
+// constructMapKeyCodec returns the codec of map keys of type k, which are
+// encoded as JSON strings, and the function sorting them. The boolean is false
+// if values of type k cannot be used as object keys.
+func constructMapKeyCodec(k reflect.Type, seen map[reflect.Type]*structType) (kc codec, sortKeys sortFunc, ok bool) {
 	if k.Implements(textMarshalerType) || reflect.PointerTo(k).Implements(textUnmarshalerType) {
 		kc.encode = constructTextMarshalerEncodeFunc(k, false)
 		kc.decode = constructTextUnmarshalerDecodeFunc(k, true)
@@ -436,18 +555,11 @@ func constructMapCodec(t reflect.Type, seen map[reflect.Type]*structType) codec
 			}
 
 		default:
-			return constructUnsupportedTypeCodec(t)
+			return kc, nil, false
 		}
 	}
 
-	if inlined(v) {
-		vc.encode = constructInlineValueEncodeFunc(vc.encode)
-	}
-
-	return codec{
-		encode: constructMapEncodeFunc(t, kc.encode, vc.encode, sortKeys),
-		decode: constructMapDecodeFunc(t, kc.decode, vc.decode),
-	}
+	return kc, sortKeys, true
 }
 
 func constructMapEncodeFunc(t reflect.Type, encodeKey, encodeValue encodeFunc, sortKeys sortFunc) encodeFunc {
@@ -466,6 +578,68 @@ func constructMapDecodeFunc(t reflect.Type, decodeKey, decodeValue decodeFunc) d
 	}
 }
 
+// constructSeqCodec constructs the codec of iterator functions with the same
+// signature as iter.Seq[V] or iter.Seq2[K, V], which are encoded as JSON arrays
+// of the values and JSON objects of the key/value pairs that they yield,
+// respectively. Iterators cannot be decoded.
+func constructSeqCodec(t reflect.Type, seen map[reflect.Type]*structType) codec {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.IsVariadic() {
+		return constructUnsupportedTypeCodec(t)
+	}
+
+	y := t.In(0)
+	if y.Kind() != reflect.Func || y.NumOut() != 1 || y.Out(0) != boolType || y.IsVariadic() {
+		return constructUnsupportedTypeCodec(t)
+	}
+
+	c := constructUnsupportedTypeCodec(t)
+
+	switch y.NumIn() {
+	case 1:
+		v := y.In(0)
+		vc := constructCodec(v, seen, false)
+
+		if inlined(v) {
+			vc.encode = constructInlineValueEncodeFunc(vc.encode)
+		}
+
+		c.encode = constructSeqEncodeFunc(t, vc.encode)
+
+	case 2:
+		k, v := y.In(0), y.In(1)
+		kc, _, ok := constructMapKeyCodec(k, seen)
+		if !ok {
+			return c
+		}
+
+		vc := constructCodec(v, seen, false)
+
+		if inlined(k) {
+			kc.encode = constructInlineValueEncodeFunc(kc.encode)
+		}
+
+		if inlined(v) {
+			vc.encode = constructInlineValueEncodeFunc(vc.encode)
+		}
+
+		c.encode = constructSeq2EncodeFunc(t, kc.encode, vc.encode)
+	}
+
+	return c
+}
+
+func constructSeqEncodeFunc(t reflect.Type, encode encodeFunc) encodeFunc {
+	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return e.encodeSeq(b, p, t, encode)
+	}
+}
+
+func constructSeq2EncodeFunc(t reflect.Type, encodeKey, encodeValue encodeFunc) encodeFunc {
+	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return e.encodeSeq2(b, p, t, encodeKey, encodeValue)
+	}
+}
+
 func constructStructCodec(t reflect.Type, seen map[reflect.Type]*structType, canAddr bool) codec {
 	st := constructStructType(t, seen, canAddr)
 	return codec{
@@ -492,6 +666,20 @@ func constructStructType(t reflect.Type, seen map[reflect.Type]*structType, canA
 
 		for i := range st.fields {
 			f := &st.fields[i]
+			f.pos = i
+			// Until the decoder learns the actual order of keys in the input,
+			// assume that they appear in the same order as the struct fields.
+			f.next = uint32(i + 1)
+			if f.required || f.defaults != nil {
+				st.tracked = true
+			}
+			if f.envelope != "" {
+				st.envelopes = append(st.envelopes, envelopeField{
+					key:  f.envelope,
+					pos:  i,
+					disc: lookupDiscriminator(f.typ),
+				})
+			}
 			s := strings.ToLower(f.name)
 			st.fieldsIndex[f.name] = f
 			// When there is ambiguity because multiple fields have the same
@@ -501,16 +689,50 @@ func constructStructType(t reflect.Type, seen map[reflect.Type]*structType, canA
 			}
 		}
 
+		// Aliases are added after the field names so they never take
+		// precedence over them, and an alias declared by more than one field
+		// is ambiguous and ignored, like conflicting embedded field names.
+		aliases := make(map[string]int)
+		for i := range st.fields {
+			for _, alias := range st.fields[i].aliases {
+				aliases[alias]++
+			}
+		}
+
+		hasAliases := false
+		for i := range st.fields {
+			f := &st.fields[i]
+			for _, alias := range f.aliases {
+				if _, exists := st.fieldsIndex[alias]; exists || aliases[alias] > 1 {
+					continue
+				}
+				st.fieldsIndex[alias] = f
+				s := strings.ToLower(alias)
+				if _, exists := st.ficaseIndex[s]; !exists {
+					st.ficaseIndex[s] = f
+				}
+				hasAliases = true
+			}
+		}
+
 		// At a certain point the linear scan provided by keyset is less
-		// efficient than a map. The 32 was chosen based on benchmarks in the
-		// segmentio/asm repo run with an Intel Kaby Lake processor and go1.17.
-		if len(st.fields) <= 32 {
+		// efficient than a hash table. The 32 was chosen based on benchmarks
+		// in the segmentio/asm repo run with an Intel Kaby Lake processor and
+		// go1.17.
+		//
+		// The keyset only holds the field names, so the hash table is also
+		// used when some fields have aliases.
+		if len(st.fields) <= 32 && !hasAliases {
 			keys := make([][]byte, len(st.fields))
 			for i, f := range st.fields {
 				keys[i] = []byte(f.name)
 			}
 			st.keyset = keyset.New(keys)
+		} else {
+			st.fieldsTable = makeFieldTable(st.fieldsIndex)
 		}
+
+		st.ficaseTable = makeFieldTable(st.ficaseIndex)
 	}
 
 	return st
@@ -541,6 +763,16 @@ func constructEmbeddedStructPointerEncodeFunc(t reflect.Type, unexported bool, o
 	}
 }
 
+// constructEmbeddedStructPointerEmptyFunc returns the function testing whether
+// a field of a struct embedded by pointer is empty, which is the case when the
+// pointer is nil.
+func constructEmbeddedStructPointerEmptyFunc(offset uintptr, empty emptyFunc) emptyFunc {
+	return func(p unsafe.Pointer) bool {
+		p = *(*unsafe.Pointer)(p)
+		return p == nil || empty(unsafe.Pointer(uintptr(p)+offset))
+	}
+}
+
 func constructEmbeddedStructPointerDecodeFunc(t reflect.Type, unexported bool, offset uintptr, decode decodeFunc) decodeFunc {
 	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
 		return d.decodeEmbeddedStructPointer(b, p, t, unexported, offset, decode)
@@ -569,6 +801,12 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 			tag        = false
 			omitempty  = false
 			stringify  = false
+			required   = false
+			defval     = ""
+			hasDefault = false
+			format     = ""
+			envelope   = ""
+			aliases    []string
 			unexported = len(f.PkgPath) != 0
 		)
 
@@ -576,7 +814,7 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 			continue
 		}
 
-		if parts := strings.Split(f.Tag.Get("json"), ","); len(parts) != 0 {
+		if parts := splitTagOptions(f.Tag.Get("json")); len(parts) != 0 {
 			if len(parts[0]) != 0 {
 				name, tag = parts[0], true
 			}
@@ -589,12 +827,32 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 				name = f.Name
 			}
 
-			for _, tag := range parts[1:] {
-				switch tag {
-				case "omitempty":
+		options:
+			for j, tag := range parts[1:] {
+				switch {
+				case tag == "omitempty":
 					omitempty = true
-				case "string":
+				case tag == "string":
 					stringify = true
+				case tag == "required":
+					required = true
+				case strings.HasPrefix(tag, "format:"):
+					format = unquoteTagOption(tag[len("format:"):])
+				case strings.HasPrefix(tag, "discriminator:"):
+					envelope = unquoteTagOption(tag[len("discriminator:"):])
+				case strings.HasPrefix(tag, "alias:"):
+					// Aliases are alternate keys accepted when decoding, the
+					// option may be repeated to declare more than one.
+					if alias := unquoteTagOption(tag[len("alias:"):]); isValidTag(alias) && !slices.Contains(aliases, alias) {
+						aliases = append(aliases, alias)
+					}
+				case strings.HasPrefix(tag, "default="):
+					// The default value may itself contain commas (e.g. when it
+					// is an array or an object), so it always extends to the
+					// end of the tag.
+					defval = strings.Join(parts[1+j:], ",")[len("default="):]
+					hasDefault = true
+					break options
 				}
 			}
 		}
@@ -633,7 +891,20 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 			}
 		}
 
-		codec := constructCodec(f.Type, seen, canAddr)
+		var codec codec
+		switch {
+		case envelope != "":
+			if disc := lookupDiscriminator(f.Type); disc != nil {
+				codec = constructEnvelopeCodec(disc)
+			} else {
+				codec = constructErrorCodec(fmt.Errorf("json: discriminator option of field %s of %s requires an interface registered with RegisterDiscriminator but the field has type %s", f.Name, t, f.Type))
+				envelope = ""
+			}
+		case format != "":
+			codec = constructFormatCodec(t, f, format)
+		default:
+			codec = constructCodec(f.Type, seen, canAddr)
+		}
 
 		if stringify {
 			// https://golang.org/pkg/encoding/json/#Marshal
@@ -663,22 +934,37 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 				reflect.Uint64:
 				codec.encode = constructStringEncodeFunc(codec.encode)
 				codec.decode = constructStringToIntDecodeFunc(typ, codec.decode)
+			case reflect.Float32,
+				reflect.Float64:
+				codec.encode = constructStringFloatEncodeFunc(f.Type, codec.encode)
+				codec.decode = constructStringFloatDecodeFunc(codec.decode)
 			case reflect.Bool,
-				reflect.Float32,
-				reflect.Float64,
 				reflect.String:
 				codec.encode = constructStringEncodeFunc(codec.encode)
 				codec.decode = constructStringDecodeFunc(codec.decode)
 			}
 		}
 
+		var defaults decodeFunc
+		if hasDefault {
+			var err error
+			if defaults, err = constructDefaultDecodeFunc(t, f, codec.decode, defval); err != nil {
+				codec = constructErrorCodec(err)
+				defaults = codec.decode
+			}
+		}
+
 		fields = append(fields, structField{
 			codec:     codec,
+			defaults:  defaults,
 			offset:    offset + f.Offset,
 			empty:     emptyFuncOf(f.Type),
 			tag:       tag,
 			omitempty: omitempty,
+			required:  required,
+			envelope:  envelope,
 			name:      name,
+			aliases:   aliases,
 			index:     i << 32,
 			typ:       f.Type,
 			zero:      reflect.Zero(f.Type),
@@ -687,6 +973,25 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 		names[name] = struct{}{}
 	}
 
+	// The sibling keys of envelope fields are injected right before them
+	// unless the struct has fields for the keys.
+	for _, f := range fields {
+		if _, exists := names[f.envelope]; f.envelope == "" || exists {
+			continue
+		}
+		fields = append(fields, structField{
+			codec:  constructEnvelopeKeyCodec(lookupDiscriminator(f.typ)),
+			offset: f.offset,
+			empty:  f.empty,
+			tag:    true,
+			name:   f.envelope,
+			index:  f.index - 1,
+			typ:    f.typ,
+			zero:   f.zero,
+		})
+		names[f.envelope] = struct{}{}
+	}
+
 	// Only unambiguous embedded fields must be serialized.
 	ambiguousNames := make(map[string]int)
 	ambiguousTags := make(map[string]int)
@@ -713,7 +1018,11 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 		}
 
 		if embfield.pointer {
+			if subfield.defaults != nil {
+				subfield.defaults = constructEmbeddedStructPointerDecodeFunc(embfield.subtype.typ, embfield.unexported, subfield.offset, subfield.defaults)
+			}
 			subfield.codec = constructEmbeddedStructPointerCodec(embfield.subtype.typ, embfield.unexported, subfield.offset, subfield.codec)
+			subfield.empty = constructEmbeddedStructPointerEmptyFunc(subfield.offset, subfield.empty)
 			subfield.offset = embfield.offset
 		} else {
 			subfield.offset += embfield.offset
@@ -733,12 +1042,326 @@ func appendStructFields(fields []structField, t reflect.Type, offset uintptr, se
 		name := fields[i].name
 		fields[i].json = encodeKeyFragment(name, 0)
 		fields[i].html = encodeKeyFragment(name, EscapeHTML)
+		fields[i].ascii = fields[i].json
+		fields[i].asciiHTML = fields[i].html
+		if !ascii.ValidString(name) {
+			fields[i].ascii = encodeKeyFragment(name, EscapeNonASCII)
+			fields[i].asciiHTML = encodeKeyFragment(name, EscapeHTML|EscapeNonASCII)
+		}
 	}
 
 	sort.Slice(fields, func(i, j int) bool { return fields[i].index < fields[j].index })
 	return fields
 }
 
+// splitTagOptions splits the value of a json struct tag on commas.
+//
+// Option values may be quoted with single or double quotes when they contain
+// commas, for example `json:"date,format:'Jan-2,2006'"`; a quote is only
+// interpreted as such when it directly follows the ':' of an option.
+func splitTagOptions(tag string) []string {
+	parts := make([]string, 0, 4)
+	quote := byte(0)
+	i := 0
+
+	for j := 0; j < len(tag); j++ {
+		switch c := tag[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == ',':
+			parts = append(parts, tag[i:j])
+			i = j + 1
+		case (c == '\'' || c == '"') && j > 0 && tag[j-1] == ':' && len(parts) != 0:
+			quote = c
+		}
+	}
+
+	return append(parts, tag[i:])
+}
+
+// unquoteTagOption removes the quotes around the value of a struct tag option.
+func unquoteTagOption(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// constructFormatCodec returns the codec of the struct field f which was
+// declared with a "format:" option.
+//
+// The format applies to time.Time, time.Duration, and byte slice values (or
+// pointers to them), when it is not supported by the field type the returned
+// codec fails to encode and decode the field.
+func constructFormatCodec(t reflect.Type, f reflect.StructField, format string) codec {
+	typ := f.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	var c codec
+	switch {
+	case typ == timeType:
+		c = constructTimeFormatCodec(format)
+	case typ == durationType:
+		c = constructDurationFormatCodec(format)
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		c = constructBytesFormatCodec(typ, format)
+	}
+
+	if c.encode == nil {
+		return constructErrorCodec(fmt.Errorf("json: format %q is not supported for field %s of %s with type %s", format, f.Name, t, f.Type))
+	}
+
+	if typ != f.Type {
+		c = codec{
+			encode: constructPointerEncodeFunc(typ, c.encode),
+			decode: constructPointerDecodeFunc(typ, c.decode),
+		}
+	}
+
+	return c
+}
+
+// Scale of the units that time values may be represented with, expressed in
+// nanoseconds.
+const (
+	nanosecondUnit  = 1
+	microsecondUnit = 1e3
+	millisecondUnit = 1e6
+	secondUnit      = 1e9
+)
+
+func constructTimeFormatCodec(format string) codec {
+	switch format {
+	case "unix":
+		return constructUnixTimeCodec(secondUnit)
+	case "unixmilli":
+		return constructUnixTimeCodec(millisecondUnit)
+	case "unixmicro":
+		return constructUnixTimeCodec(microsecondUnit)
+	case "unixnano":
+		return constructUnixTimeCodec(nanosecondUnit)
+	case "RFC3339Nano":
+		return codec{encode: encoder.encodeTime, decode: decoder.decodeTime}
+	case "RFC3339":
+		// The iso8601 parser accepts both the RFC3339 and RFC3339Nano formats,
+		// which is the same behavior as time.Parse for these layouts.
+		return codec{encode: constructTimeLayoutEncodeFunc(time.RFC3339), decode: decoder.decodeTime}
+	}
+
+	layout, ok := timeLayouts[format]
+	if !ok {
+		if !isTimeLayout(format) {
+			return codec{}
+		}
+		layout = format
+	}
+
+	return codec{
+		encode: constructTimeLayoutEncodeFunc(layout),
+		decode: constructTimeLayoutDecodeFunc(layout),
+	}
+}
+
+// timeLayouts maps the names of the layout constants of the time package to
+// their values, so they can be referenced by name in format options.
+var timeLayouts = map[string]string{
+	"ANSIC":      time.ANSIC,
+	"UnixDate":   time.UnixDate,
+	"RubyDate":   time.RubyDate,
+	"RFC822":     time.RFC822,
+	"RFC822Z":    time.RFC822Z,
+	"RFC850":     time.RFC850,
+	"RFC1123":    time.RFC1123,
+	"RFC1123Z":   time.RFC1123Z,
+	"Kitchen":    time.Kitchen,
+	"Stamp":      time.Stamp,
+	"StampMilli": time.StampMilli,
+	"StampMicro": time.StampMicro,
+	"StampNano":  time.StampNano,
+	"DateTime":   time.DateTime,
+	"DateOnly":   time.DateOnly,
+	"TimeOnly":   time.TimeOnly,
+}
+
+// isTimeLayout returns true if format can be used as a time layout. Formats
+// made of letters and digits starting with a letter, like "unixmili", are
+// names of unknown formats rather than layouts, and so are formats which have
+// no layout elements.
+func isTimeLayout(format string) bool {
+	if format == "" {
+		return false
+	}
+	if c := format[0]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		identifier := true
+		for _, c := range []byte(format) {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+				identifier = false
+				break
+			}
+		}
+		if identifier {
+			return false
+		}
+	}
+	return time.Time{}.Format(format) != format
+}
+
+func constructUnixTimeCodec(unit int64) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeUnixTime(b, p, unit)
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return d.decodeUnixTime(b, p, unit)
+		},
+	}
+}
+
+func constructTimeLayoutEncodeFunc(layout string) encodeFunc {
+	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return e.encodeTimeLayout(b, p, layout)
+	}
+}
+
+func constructTimeLayoutDecodeFunc(layout string) decodeFunc {
+	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return d.decodeTimeLayout(b, p, layout)
+	}
+}
+
+func constructDurationFormatCodec(format string) codec {
+	var unit int64
+	switch format {
+	case "units":
+		return codec{encode: encoder.encodeDuration, decode: decoder.decodeDuration}
+	case "sec":
+		unit = secondUnit
+	case "milli":
+		unit = millisecondUnit
+	case "micro":
+		unit = microsecondUnit
+	case "nanos":
+		unit = nanosecondUnit
+	default:
+		return codec{}
+	}
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeDurationUnit(b, p, unit)
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return d.decodeDurationUnit(b, p, unit)
+		},
+	}
+}
+
+func constructBytesFormatCodec(t reflect.Type, format string) codec {
+	switch format {
+	case "base64":
+		return codec{encode: encoder.encodeBytes, decode: decoder.decodeBytes}
+	case "base64url":
+		return constructBase64Codec(base64.RawURLEncoding)
+	case "base64raw":
+		return constructBase64Codec(base64.RawStdEncoding)
+	case "hex":
+		return codec{encode: encoder.encodeBytesHex, decode: decoder.decodeBytesHex}
+	case "array":
+		return codec{
+			encode: constructSliceEncodeFunc(1, t, encoder.encodeUint8),
+			decode: constructSliceDecodeFunc(1, t, decoder.decodeUint8),
+		}
+	default:
+		return codec{}
+	}
+}
+
+func constructBase64Codec(enc *base64.Encoding) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeBytesBase64(b, p, enc)
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return d.decodeBytesBase64(b, p, enc)
+		},
+	}
+}
+
+// constructDefaultDecodeFunc returns a decode function which ignores its input
+// and assigns the default value declared with the "default=" option of the
+// struct field f.
+//
+// The default value is parsed once, when the codec is constructed, and an
+// error is returned if it is invalid. Values that do not hold references to
+// mutable memory are copied on each use, while the others are decoded again so
+// the decoded Go values never share slices, maps, or pointers.
+func constructDefaultDecodeFunc(t reflect.Type, f reflect.StructField, decode decodeFunc, value string) (decodeFunc, error) {
+	parse := func(b []byte) (reflect.Value, error) {
+		v := reflect.New(f.Type)
+		r, err := decode(decoder{flags: internalParseFlags(b)}, b, unsafe.Pointer(v.Pointer()))
+		if err == nil && len(skipSpaces(r)) != 0 {
+			err = syntaxError(r, "unexpected trailing tokens after default value")
+		}
+		return v, err
+	}
+
+	b := []byte(value)
+	v, err := parse(b)
+	if err != nil {
+		// Allows writing string-like defaults (strings, durations, times...)
+		// without having to escape the quotes in the struct tag.
+		q := AppendEscape(nil, value, 0)
+		if qv, qerr := parse(q); qerr == nil {
+			b, v, err = q, qv, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("json: invalid default value for field %s of %s in `default=%s`: %w", f.Name, t, value, err)
+	}
+
+	if hasReferences(f.Type) {
+		return func(d decoder, _ []byte, p unsafe.Pointer) ([]byte, error) {
+			return decode(d, b, p)
+		}, nil
+	}
+
+	v = v.Elem()
+	return func(_ decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		reflect.NewAt(f.Type, p).Elem().Set(v)
+		return b, nil
+	}, nil
+}
+
+// hasReferences returns true if values of type t may point to mutable memory.
+func hasReferences(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uintptr, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.Complex64, reflect.Complex128,
+		reflect.String:
+		return false
+
+	case reflect.Array:
+		return hasReferences(t.Elem())
+
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if hasReferences(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+
+	default:
+		return true
+	}
+}
+
 func encodeKeyFragment(s string, flags AppendFlags) string {
 	b := make([]byte, 1, len(s)+4)
 	b[0] = ','
@@ -788,6 +1411,57 @@ func constructMaybeEmptyInterfaceDecoderFunc(t reflect.Type) decodeFunc {
 	}
 }
 
+func constructDiscriminatorCodec(disc *discriminator) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeDiscriminated(b, p, disc)
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return d.decodeDiscriminated(b, p, disc)
+		},
+	}
+}
+
+// constructEnvelopeCodec returns the codec of a struct field tagged with the
+// "discriminator:" option, the discriminator is held by a sibling key which is
+// encoded by the codec returned by constructEnvelopeKeyCodec.
+func constructEnvelopeCodec(disc *discriminator) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeEnvelopePayload(b, p, disc)
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return d.decodeDiscriminated(b, p, disc)
+		},
+	}
+}
+
+// constructEnvelopeKeyCodec returns the codec of the sibling key injected in
+// structs which have no field for the key of an envelope field, p is the
+// address of the envelope field.
+func constructEnvelopeKeyCodec(disc *discriminator) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return e.encodeEnvelopeKey(b, p, disc)
+		},
+		decode: decoder.decodeEnvelopeKey,
+	}
+}
+
+// constructErrorCodec returns a codec which fails to encode and decode values
+// with err, it is used for struct fields whose tag options do not apply to
+// their type so the error is reported by Marshal and Unmarshal.
+func constructErrorCodec(err error) codec {
+	return codec{
+		encode: func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return b, err
+		},
+		decode: func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+			return b, err
+		},
+	}
+}
+
 func constructUnsupportedTypeCodec(t reflect.Type) codec {
 	return codec{
 		encode: constructUnsupportedTypeEncodeFunc(t),
@@ -807,6 +1481,12 @@ func constructUnsupportedTypeDecodeFunc(t reflect.Type) decodeFunc {
 	}
 }
 
+func constructValidatorDecodeFunc(t reflect.Type, decode decodeFunc) decodeFunc {
+	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return d.decodeValidator(b, p, t, decode)
+	}
+}
+
 func constructJSONMarshalerEncodeFunc(t reflect.Type, pointer bool) encodeFunc {
 	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
 		return e.encodeJSONMarshaler(b, p, t, pointer)
@@ -819,6 +1499,18 @@ func constructJSONUnmarshalerDecodeFunc(t reflect.Type, pointer bool) decodeFunc
 	}
 }
 
+func constructJSONMarshalerToEncodeFunc(t reflect.Type, pointer bool) encodeFunc {
+	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return e.encodeJSONMarshalerTo(b, p, t, pointer)
+	}
+}
+
+func constructJSONUnmarshalerFromDecodeFunc(t reflect.Type, pointer bool) decodeFunc {
+	return func(d decoder, b []byte, p unsafe.Pointer) ([]byte, error) {
+		return d.decodeJSONUnmarshalerFrom(b, p, t, pointer)
+	}
+}
+
 func constructTextMarshalerEncodeFunc(t reflect.Type, pointer bool) encodeFunc {
 	return func(e encoder, b []byte, p unsafe.Pointer) ([]byte, error) {
 		return e.encodeTextMarshaler(b, p, t, pointer)
@@ -863,12 +1555,16 @@ func align(align, size uintptr) uintptr {
 	return size
 }
 
+// inlined returns true if values of type t are stored directly in interfaces
+// and reflect.Value, instead of being referenced by a pointer. This is the case
+// of pointer-shaped types, including arrays and structs with a single element
+// of such type.
 func inlined(t reflect.Type) bool {
 	switch t.Kind() {
-	case reflect.Ptr:
-		return true
-	case reflect.Map:
+	case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.UnsafePointer:
 		return true
+	case reflect.Array:
+		return t.Len() == 1 && inlined(t.Elem())
 	case reflect.Struct:
 		return t.NumField() == 1 && inlined(t.Field(0).Type)
 	default:
@@ -968,22 +1664,45 @@ type structType struct {
 	fields      []structField
 	fieldsIndex map[string]*structField
 	ficaseIndex map[string]*structField
+	fieldsTable fieldTable
+	ficaseTable fieldTable
 	keyset      []byte
 	typ         reflect.Type
+	// first is the position of the field that the decoder expects to see
+	// first in objects, it is updated atomically as the decoder learns the
+	// order of keys in the input (see also structField.next).
+	first uint32
+	// tracked is true when some of the fields are required or have default
+	// values, in which case the decoder must track which keys were seen.
+	tracked bool
+	// envelopes lists the fields tagged with the "discriminator:" option.
+	envelopes []envelopeField
 }
 
 type structField struct {
 	codec     codec
+	defaults  decodeFunc
 	offset    uintptr
 	empty     emptyFunc
 	tag       bool
 	omitempty bool
+	required  bool
+	envelope  string
 	json      string
 	html      string
+	// Key fragments with non-ASCII characters escaped, which are the same
+	// as json and html when the name is made of ASCII characters only.
+	ascii     string
+	asciiHTML string
 	name      string
+	aliases   []string
 	typ       reflect.Type
 	zero      reflect.Value
 	index     int
+	pos       int
+	// next is the position of the field that the decoder expects to see after
+	// this one, it is read and written atomically.
+	next uint32
 }
 
 func unmarshalTypeError(b []byte, t reflect.Type) error {
@@ -1084,6 +1803,8 @@ var (
 	float64Type = reflect.TypeOf(float64(0))
 
 	bigIntType     = reflect.TypeOf(new(big.Int))
+	bigFloatType   = reflect.TypeOf(big.Float{})
+	bigRatType     = reflect.TypeOf(big.Rat{})
 	numberType     = reflect.TypeOf(json.Number(""))
 	stringType     = reflect.TypeOf("")
 	stringsType    = reflect.TypeOf([]string(nil))
@@ -1096,6 +1817,8 @@ var (
 	durationPtrType   = reflect.PointerTo(durationType)
 	timePtrType       = reflect.PointerTo(timeType)
 	rawMessagePtrType = reflect.PointerTo(rawMessageType)
+	bigFloatPtrType   = reflect.PointerTo(bigFloatType)
+	bigRatPtrType     = reflect.PointerTo(bigRatType)
 
 	sliceInterfaceType       = reflect.TypeOf(([]any)(nil))
 	sliceStringType          = reflect.TypeOf(([]any)(nil))
@@ -1104,6 +1827,7 @@ var (
 	mapStringStringType      = reflect.TypeOf((map[string]string)(nil))
 	mapStringStringSliceType = reflect.TypeOf((map[string][]string)(nil))
 	mapStringBoolType        = reflect.TypeOf((map[string]bool)(nil))
+	orderedObjectType        = reflect.TypeOf(OrderedObject(nil))
 
 	interfaceType       = reflect.TypeOf((*any)(nil)).Elem()
 	jsonMarshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
@@ -1111,7 +1835,12 @@ var (
 	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 
-	bigIntDecoder = constructJSONUnmarshalerDecodeFunc(bigIntType, false)
+	jsonMarshalerToType     = reflect.TypeOf((*MarshalerTo)(nil)).Elem()
+	jsonUnmarshalerFromType = reflect.TypeOf((*UnmarshalerFrom)(nil)).Elem()
+	validatorType           = reflect.TypeOf((*Validator)(nil)).Elem()
+
+	bigIntDecoder   = constructJSONUnmarshalerDecodeFunc(bigIntType, false)
+	bigFloatDecoder = constructPointerDecodeFunc(bigFloatType, decoder.decodeBigFloat)
 )
 
 // =============================================================================
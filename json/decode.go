@@ -3,12 +3,15 @@ package json
 import (
 	"bytes"
 	"encoding"
+	hexenc "encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -229,6 +232,15 @@ func (d decoder) decodeFloat32(b []byte, p unsafe.Pointer) ([]byte, error) {
 		return b[4:], nil
 	}
 
+	if len(b) != 0 && b[0] == '"' && (d.flags&AllowNonFiniteFloats) != 0 {
+		f, r, err := d.parseNonFiniteFloat(b, float32Type)
+		if err != nil {
+			return r, err
+		}
+		*(*float32)(p) = float32(f)
+		return r, nil
+	}
+
 	v, r, _, err := d.parseNumber(b)
 	if err != nil {
 		return d.inputError(b, float32Type)
@@ -248,6 +260,15 @@ func (d decoder) decodeFloat64(b []byte, p unsafe.Pointer) ([]byte, error) {
 		return b[4:], nil
 	}
 
+	if len(b) != 0 && b[0] == '"' && (d.flags&AllowNonFiniteFloats) != 0 {
+		f, r, err := d.parseNonFiniteFloat(b, float64Type)
+		if err != nil {
+			return r, err
+		}
+		*(*float64)(p) = float64(f)
+		return r, nil
+	}
+
 	v, r, _, err := d.parseNumber(b)
 	if err != nil {
 		return d.inputError(b, float64Type)
@@ -262,6 +283,112 @@ func (d decoder) decodeFloat64(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return r, nil
 }
 
+// parseNonFiniteFloat parses one of the "NaN", "Infinity", or "-Infinity"
+// strings from b.
+func (d decoder) parseNonFiniteFloat(b []byte, t reflect.Type) (float64, []byte, error) {
+	s, r, _, err := d.parseString(b)
+	if err != nil {
+		return 0, r, err
+	}
+	switch string(s) {
+	case `"NaN"`:
+		return math.NaN(), r, nil
+	case `"Infinity"`:
+		return math.Inf(+1), r, nil
+	case `"-Infinity"`:
+		return math.Inf(-1), r, nil
+	default:
+		return 0, r, unmarshalTypeError(s, t)
+	}
+}
+
+// decodeBigFloat decodes a JSON number into a big.Float with a precision large
+// enough to represent all its digits. Strings containing numbers are also
+// accepted, which is how the values were encoded by their MarshalText method.
+func (d decoder) decodeBigFloat(b []byte, p unsafe.Pointer) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+
+	x := (*big.Float)(p)
+
+	v, r, err := d.parseBigNumber(b, bigFloatType)
+	if err != nil {
+		return r, err
+	}
+
+	if (d.flags & AllowNonFiniteFloats) != 0 {
+		switch string(v) {
+		case "Infinity":
+			x.SetInf(false)
+			return r, nil
+		case "-Infinity":
+			x.SetInf(true)
+			return r, nil
+		}
+	}
+
+	x.SetPrec(bigFloatPrec(v))
+	if _, ok := x.SetString(*(*string)(unsafe.Pointer(&v))); !ok {
+		return d.inputError(b, bigFloatType)
+	}
+
+	return r, nil
+}
+
+// decodeBigRat decodes a JSON number into a big.Rat, which represents it
+// exactly. Strings are also accepted, which is how the values were encoded by
+// their MarshalText method, and may contain fractions like "1/3".
+func (d decoder) decodeBigRat(b []byte, p unsafe.Pointer) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+
+	v, r, err := d.parseBigNumber(b, bigRatType)
+	if err != nil {
+		return r, err
+	}
+
+	if _, ok := (*big.Rat)(p).SetString(*(*string)(unsafe.Pointer(&v))); !ok {
+		return d.inputError(b, bigRatType)
+	}
+
+	return r, nil
+}
+
+// parseBigNumber returns the JSON number at the beginning of b, or the content
+// of the string if b starts with one.
+func (d decoder) parseBigNumber(b []byte, t reflect.Type) (v, r []byte, err error) {
+	if len(b) != 0 && b[0] == '"' {
+		if v, r, _, err = d.parseStringUnquote(b, nil); err != nil {
+			r, err = d.inputError(b, t)
+		}
+		return
+	}
+
+	if v, r, _, err = d.parseNumber(b); err != nil {
+		r, err = d.inputError(b, t)
+	}
+	return
+}
+
+// bigFloatPrec returns the precision of big.Float values parsed from the
+// decimal number v, which is large enough to distinguish it from all other
+// numbers with as many significant digits so the value is formatted back to
+// the same number.
+func bigFloatPrec(v []byte) uint {
+	digits := 0
+	for _, c := range v {
+		if c == 'e' || c == 'E' {
+			break
+		}
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return max(64, uint(math.Ceil(float64(digits)*math.Log2(10)))+1)
+}
+
 func (d decoder) decodeNumber(b []byte, p unsafe.Pointer) ([]byte, error) {
 	if hasNullPrefix(b) {
 		return b[4:], nil
@@ -294,7 +421,9 @@ func (d decoder) decodeString(b []byte, p unsafe.Pointer) ([]byte, error) {
 		return r, err
 	}
 
-	if new || (d.flags&DontCopyString) != 0 {
+	if t := d.stringTable(); t != nil && len(s) <= t.maxLen {
+		*(*string)(p) = t.Intern(s)
+	} else if new || (d.flags&DontCopyString) != 0 {
 		*(*string)(p) = *(*string)(unsafe.Pointer(&s))
 	} else {
 		*(*string)(p) = string(s)
@@ -324,6 +453,21 @@ func (d decoder) decodeFromString(b []byte, p unsafe.Pointer, decode decodeFunc)
 	return b, nil
 }
 
+// decodeFloatFromString is like decodeFromString, but also accepts the "NaN",
+// "Infinity", and "-Infinity" strings when the AllowNonFiniteFloats flag is
+// set, which is how encodeFloatToString writes non-finite values.
+func (d decoder) decodeFloatFromString(b []byte, p unsafe.Pointer, decode decodeFunc) ([]byte, error) {
+	if len(b) != 0 && b[0] == '"' && (d.flags&AllowNonFiniteFloats) != 0 {
+		if s, _, _, err := d.parseString(b); err == nil {
+			switch string(s) {
+			case `"NaN"`, `"Infinity"`, `"-Infinity"`:
+				return decode(d, b, p)
+			}
+		}
+	}
+	return d.decodeFromString(b, p, decode)
+}
+
 func (d decoder) decodeFromStringToInt(b []byte, p unsafe.Pointer, t reflect.Type, decode decodeFunc) ([]byte, error) {
 	if hasNullPrefix(b) {
 		return decode(d, b, p)
@@ -424,7 +568,7 @@ func (d decoder) decodeBytes(b []byte, p unsafe.Pointer) ([]byte, error) {
 		return d.inputError(b, bytesType)
 	}
 
-	dst := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
+	dst := d.makeBytes(p, base64.StdEncoding.DecodedLen(len(src)))
 
 	n, err := base64.StdEncoding.Decode(dst, src)
 	if err != nil {
@@ -435,6 +579,79 @@ func (d decoder) decodeBytes(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return r, nil
 }
 
+// makeBytes returns a byte slice of length n to decode the value pointed to by
+// p into, which reuses the capacity of the existing value when the
+// ReuseContainers flag is set.
+func (d decoder) makeBytes(p unsafe.Pointer, n int) []byte {
+	if b := *(*[]byte)(p); cap(b) >= n && (d.flags&ReuseContainers) != 0 {
+		return b[:n]
+	}
+	return make([]byte, n)
+}
+
+// clearMaps reports whether the existing entries of maps must be deleted before
+// decoding objects into them, which is the case when the ReuseContainers flag
+// is set, unless objects are merged.
+func (d decoder) clearMaps() bool {
+	return (d.flags & (ReuseContainers | MergeObjects)) == ReuseContainers
+}
+
+// decodeBytesBase64 decodes a base64 string using the alphabet of enc, which
+// must be an encoding without padding. To be lenient with producers, padding
+// characters are accepted (and ignored) at the end of the input.
+func (d decoder) decodeBytesBase64(b []byte, p unsafe.Pointer, enc *base64.Encoding) ([]byte, error) {
+	if hasNullPrefix(b) {
+		*(*[]byte)(p) = nil
+		return b[4:], nil
+	}
+
+	if len(b) < 2 || b[0] != '"' {
+		return d.inputError(b, bytesType)
+	}
+
+	src, r, _, err := d.parseStringUnquote(b, nil)
+	if err != nil {
+		return d.inputError(b, bytesType)
+	}
+	src = bytes.TrimRight(src, "=")
+
+	dst := d.makeBytes(p, enc.DecodedLen(len(src)))
+
+	n, err := enc.Decode(dst, src)
+	if err != nil {
+		return r, err
+	}
+
+	*(*[]byte)(p) = dst[:n]
+	return r, nil
+}
+
+func (d decoder) decodeBytesHex(b []byte, p unsafe.Pointer) ([]byte, error) {
+	if hasNullPrefix(b) {
+		*(*[]byte)(p) = nil
+		return b[4:], nil
+	}
+
+	if len(b) < 2 || b[0] != '"' {
+		return d.inputError(b, bytesType)
+	}
+
+	src, r, _, err := d.parseStringUnquote(b, nil)
+	if err != nil {
+		return d.inputError(b, bytesType)
+	}
+
+	dst := d.makeBytes(p, hexenc.DecodedLen(len(src)))
+
+	n, err := hexenc.Decode(dst, src)
+	if err != nil {
+		return r, err
+	}
+
+	*(*[]byte)(p) = dst[:n]
+	return r, nil
+}
+
 func (d decoder) decodeDuration(b []byte, p unsafe.Pointer) ([]byte, error) {
 	if hasNullPrefix(b) {
 		return b[4:], nil
@@ -503,6 +720,130 @@ func (d decoder) decodeTime(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return b[i+1:], nil
 }
 
+func (d decoder) decodeTimeLayout(b []byte, p unsafe.Pointer, layout string) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+
+	s, r, _, err := d.parseStringUnquote(b, nil)
+	if err != nil {
+		return d.inputError(b, timeType)
+	}
+
+	v, err := time.Parse(layout, *(*string)(unsafe.Pointer(&s)))
+	if err != nil {
+		return d.inputError(b, timeType)
+	}
+
+	*(*time.Time)(p) = v
+	return r, nil
+}
+
+func (d decoder) decodeUnixTime(b []byte, p unsafe.Pointer, unit int64) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+
+	whole, frac, r, err := d.parseUnits(b, unit, timeType)
+	if err != nil {
+		return r, err
+	}
+
+	per := secondUnit / unit
+	*(*time.Time)(p) = time.Unix(whole/per, (whole%per)*unit+frac)
+	return r, nil
+}
+
+func (d decoder) decodeDurationUnit(b []byte, p unsafe.Pointer, unit int64) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+
+	whole, frac, r, err := d.parseUnits(b, unit, durationType)
+	if err != nil {
+		return r, err
+	}
+
+	if whole > math.MaxInt64/unit || whole < math.MinInt64/unit {
+		return r, unmarshalOverflow(b[:len(b)-len(r)], durationType)
+	}
+
+	// The fractional part has the same sign as the integral part, so adding
+	// it may also overflow.
+	n := whole * unit
+	if (frac > 0 && n > math.MaxInt64-frac) || (frac < 0 && n < math.MinInt64-frac) {
+		return r, unmarshalOverflow(b[:len(b)-len(r)], durationType)
+	}
+
+	*(*time.Duration)(p) = time.Duration(n + frac)
+	return r, nil
+}
+
+// parseUnits parses a JSON number (or a string containing a number) which
+// represents a quantity of the given unit, expressed in nanoseconds. The
+// function returns the integral part of the quantity, and its fractional
+// part converted to nanoseconds (with the same sign).
+//
+// Fractional digits below the nanosecond are truncated.
+func (d decoder) parseUnits(b []byte, unit int64, t reflect.Type) (whole, frac int64, r []byte, err error) {
+	var v []byte
+	var k Kind
+
+	if len(b) != 0 && b[0] == '"' {
+		var s []byte
+		if s, r, _, err = d.parseStringUnquote(b, nil); err != nil {
+			r, err = d.inputError(b, t)
+			return
+		}
+		if v, s, k, err = d.parseNumber(s); err != nil || len(s) != 0 {
+			r, err = d.inputError(b, t)
+			return
+		}
+	} else if v, r, k, err = d.parseNumber(b); err != nil {
+		r, err = d.inputError(b, t)
+		return
+	}
+
+	if k != Float {
+		whole, _, err = d.parseInt(v, t)
+		return
+	}
+
+	i := bytes.IndexAny(v, "eE")
+	if i >= 0 {
+		// Numbers in exponent notation are rare enough that we accept the
+		// precision loss of going through a floating point representation.
+		f, perr := strconv.ParseFloat(*(*string)(unsafe.Pointer(&v)), 64)
+		if perr != nil || math.Abs(f) >= math.MaxInt64 {
+			err = unmarshalOverflow(v, t)
+			return
+		}
+		w, fr := math.Modf(f)
+		whole, frac = int64(w), int64(fr*float64(unit))
+		return
+	}
+
+	i = bytes.IndexByte(v, '.')
+	if whole, _, err = d.parseInt(v[:i], t); err != nil {
+		return
+	}
+
+	// Accumulate as many fractional digits as the unit can represent in
+	// nanoseconds, e.g. 6 for milliseconds.
+	scale := unit
+	for _, c := range v[i+1:] {
+		if scale /= 10; scale == 0 {
+			break
+		}
+		frac += int64(c-'0') * scale
+	}
+
+	if v[0] == '-' {
+		frac = -frac
+	}
+	return
+}
+
 func (d decoder) decodeArray(b []byte, p unsafe.Pointer, n int, size uintptr, t reflect.Type, decode decodeFunc) ([]byte, error) {
 	if hasNullPrefix(b) {
 		return b[4:], nil
@@ -533,9 +874,14 @@ func (d decoder) decodeArray(b []byte, p unsafe.Pointer, n int, size uintptr, t
 
 		b, err = decode(d, b, unsafe.Pointer(uintptr(p)+(uintptr(i)*size)))
 		if err != nil {
-			if e, ok := err.(*UnmarshalTypeError); ok {
+			switch e := err.(type) {
+			case *UnmarshalTypeError:
 				e.Struct = t.String() + e.Struct
 				e.Field = d.prependField(strconv.Itoa(i), e.Field)
+			case *MissingFieldError:
+				e.Field = d.prependField(strconv.Itoa(i), e.Field)
+			case *ValidationError:
+				e.Field = d.prependField(strconv.Itoa(i), e.Field)
 			}
 			return b, err
 		}
@@ -591,20 +937,29 @@ func (d decoder) decodeSlice(b []byte, p unsafe.Pointer, size uintptr, t reflect
 	b = b[1:]
 
 	s := (*slice)(p)
-	s.len = 0
+	keep := 0
+
+	switch {
+	case d.flags.has(AppendSlices):
+	case d.flags.has(MergeSlices):
+		keep, s.len = s.len, 0
+	default:
+		s.len = 0
+	}
 
 	var err error
-	for {
+	for i := 0; ; i++ {
 		b = skipSpaces(b)
 
 		if len(b) != 0 && b[0] == ']' {
 			if s.data == nil {
 				s.data = unsafe.Pointer(&empty)
 			}
+			s.len = max(s.len, keep)
 			return b[1:], nil
 		}
 
-		if s.len != 0 {
+		if i != 0 {
 			if len(b) == 0 {
 				return b, syntaxError(b, "unexpected EOF after array element")
 			}
@@ -633,9 +988,14 @@ func (d decoder) decodeSlice(b []byte, p unsafe.Pointer, size uintptr, t reflect
 			} else {
 				b = r
 			}
-			if e, ok := err.(*UnmarshalTypeError); ok {
+			switch e := err.(type) {
+			case *UnmarshalTypeError:
 				e.Struct = t.String() + e.Struct
 				e.Field = d.prependField(strconv.Itoa(s.len), e.Field)
+			case *MissingFieldError:
+				e.Field = d.prependField(strconv.Itoa(s.len), e.Field)
+			case *ValidationError:
+				e.Field = d.prependField(strconv.Itoa(s.len), e.Field)
 			}
 			return b, err
 		}
@@ -665,8 +1025,12 @@ func (d decoder) decodeMap(b []byte, p unsafe.Pointer, t, kt, vt reflect.Type, k
 
 	if m.IsNil() {
 		m = reflect.MakeMap(t)
+	} else if d.clearMaps() {
+		m.Clear()
 	}
 
+	merge := d.flags.has(MergeObjects)
+
 	var err error
 	b = b[1:]
 	for {
@@ -706,15 +1070,26 @@ func (d decoder) decodeMap(b []byte, p unsafe.Pointer, t, kt, vt reflect.Type, k
 		}
 		b = skipSpaces(b[1:])
 
+		if merge {
+			if x := m.MapIndex(k); x.IsValid() {
+				v.Set(x)
+			}
+		}
+
 		if b, err = decodeValue(d, b, vptr); err != nil {
 			if _, r, _, err := d.parseValue(input); err != nil {
 				return r, err
 			} else {
 				b = r
 			}
-			if e, ok := err.(*UnmarshalTypeError); ok {
+			switch e := err.(type) {
+			case *UnmarshalTypeError:
 				e.Struct = "map[" + kt.String() + "]" + vt.String() + "{" + e.Struct + "}"
 				e.Field = d.prependField(fmt.Sprint(k.Interface()), e.Field)
+			case *MissingFieldError:
+				e.Field = d.prependField(fmt.Sprint(k.Interface()), e.Field)
+			case *ValidationError:
+				e.Field = d.prependField(fmt.Sprint(k.Interface()), e.Field)
 			}
 			return b, err
 		}
@@ -739,6 +1114,8 @@ func (d decoder) decodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 
 	if m == nil {
 		m = make(map[string]any, 64)
+	} else if d.clearMaps() {
+		clear(m)
 	}
 
 	var (
@@ -788,6 +1165,10 @@ func (d decoder) decodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 		}
 		b = skipSpaces(b[1:])
 
+		if (d.flags & MergeObjects) != 0 {
+			val = m[key]
+		}
+
 		b, err = d.decodeInterface(b, unsafe.Pointer(&val))
 		if err != nil {
 			if _, r, _, err := d.parseValue(input); err != nil {
@@ -807,6 +1188,93 @@ func (d decoder) decodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 	}
 }
 
+func (d decoder) decodeOrderedObject(b []byte, p unsafe.Pointer) ([]byte, error) {
+	if hasNullPrefix(b) {
+		*(*OrderedObject)(p) = nil
+		return b[4:], nil
+	}
+
+	if len(b) < 2 || b[0] != '{' {
+		return d.inputError(b, orderedObjectType)
+	}
+
+	i := 0
+	obj := (*(*OrderedObject)(p))[:0]
+
+	if obj == nil {
+		obj = make(OrderedObject, 0, 8)
+	}
+
+	// Objects nested in the values are decoded as ordered objects as well.
+	dv := d
+	dv.flags |= UseOrderedObject
+
+	var (
+		input = b
+		key   string
+		val   any
+		err   error
+	)
+
+	b = b[1:]
+	for {
+		key = ""
+		val = nil
+
+		b = skipSpaces(b)
+
+		if len(b) != 0 && b[0] == '}' {
+			*(*OrderedObject)(p) = obj
+			return b[1:], nil
+		}
+
+		if i != 0 {
+			if len(b) == 0 {
+				return b, syntaxError(b, "unexpected end of JSON input after object field value")
+			}
+			if b[0] != ',' {
+				return b, syntaxError(b, "expected ',' after object field value but found '%c'", b[0])
+			}
+			b = skipSpaces(b[1:])
+		}
+
+		if hasNullPrefix(b) {
+			return b, syntaxError(b, "cannot decode object key string from 'null' value")
+		}
+
+		b, err = d.decodeString(b, unsafe.Pointer(&key))
+		if err != nil {
+			return objectKeyError(b, err)
+		}
+		b = skipSpaces(b)
+
+		if len(b) == 0 {
+			return b, syntaxError(b, "unexpected end of JSON input after object field key")
+		}
+		if b[0] != ':' {
+			return b, syntaxError(b, "expected ':' after object field key but found '%c'", b[0])
+		}
+		b = skipSpaces(b[1:])
+
+		b, err = dv.decodeInterface(b, unsafe.Pointer(&val))
+		if err != nil {
+			if _, r, _, err := d.parseValue(input); err != nil {
+				return r, err
+			} else {
+				b = r
+			}
+			if e, ok := err.(*UnmarshalTypeError); ok {
+				e.Struct = orderedObjectType.String() + e.Struct
+				e.Field = d.prependField(key, e.Field)
+			}
+			return b, err
+		}
+
+		obj = append(obj, KeyValue{Key: key, Value: val})
+		i++
+	}
+}
+
 func (d decoder) decodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte, error) {
 	if hasNullPrefix(b) {
 		*(*unsafe.Pointer)(p) = nil
@@ -822,6 +1290,8 @@ func (d decoder) decodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte,
 
 	if m == nil {
 		m = make(map[string]RawMessage, 64)
+	} else if d.clearMaps() {
+		clear(m)
 	}
 
 	var err error
@@ -903,6 +1373,8 @@ func (d decoder) decodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 
 	if m == nil {
 		m = make(map[string]string, 64)
+	} else if d.clearMaps() {
+		clear(m)
 	}
 
 	var err error
@@ -984,6 +1456,8 @@ func (d decoder) decodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 
 	if m == nil {
 		m = make(map[string][]string, 64)
+	} else if d.clearMaps() {
+		clear(m)
 	}
 
 	var err error
@@ -1032,6 +1506,11 @@ func (d decoder) decodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 		}
 		b = skipSpaces(b[1:])
 
+		buf = buf[:0]
+		if (d.flags & MergeObjects) != 0 {
+			buf = append(buf, m[key]...)
+		}
+
 		b, err = d.decodeSlice(b, unsafe.Pointer(&buf), stringSize, sliceStringType, decoder.decodeString)
 		if err != nil {
 			if _, r, _, err := d.parseValue(input); err != nil {
@@ -1069,6 +1548,8 @@ func (d decoder) decodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 
 	if m == nil {
 		m = make(map[string]bool, 64)
+	} else if d.clearMaps() {
+		clear(m)
 	}
 
 	var err error
@@ -1153,11 +1634,50 @@ func (d decoder) decodeStruct(b []byte, p unsafe.Pointer, st *structType) ([]byt
 	var key []byte
 	input := b
 
+	// bit set of the fields that were seen in the input, only maintained when
+	// the struct has required fields or fields with default values
+	var seenBuf [1]uint64
+	var seen []uint64
+	// last field that was decoded, used to predict the next key
+	var prev *structField
+	if st.tracked {
+		if len(st.fields) <= 64 {
+			seen = seenBuf[:]
+		} else {
+			seen = make([]uint64, (len(st.fields)+63)/64)
+		}
+	}
+
+	// discriminators of the envelope fields, only maintained when the struct
+	// has fields tagged with the "discriminator:" option
+	var envBuf [1]envelopeState
+	var env []envelopeState
+	if st.envelopes != nil {
+		if len(st.envelopes) <= len(envBuf) {
+			env = envBuf[:]
+		} else {
+			env = make([]envelopeState, len(st.envelopes))
+		}
+	}
+
+	// the discriminator of the object is not an unknown field when decoding
+	// into the concrete type of an interface, and is not passed to the fields
+	discriminated := d.discriminated
+	d.discriminated = nil
+
 	b = b[1:]
 	for {
 		b = skipSpaces(b)
 
 		if len(b) != 0 && b[0] == '}' {
+			if env != nil {
+				if err := d.decodeDeferredEnvelopes(p, st, env); err != nil {
+					return b[1:], err
+				}
+			}
+			if seen != nil {
+				return b[1:], d.decodeAbsentFields(p, st, seen)
+			}
 			return b[1:], nil
 		}
 
@@ -1190,45 +1710,185 @@ func (d decoder) decodeStruct(b []byte, p unsafe.Pointer, st *structType) ([]byt
 		}
 		b = skipSpaces(b[1:])
 
+		// Objects are usually produced by programs that always write keys in
+		// the same order, so before searching for the field we speculate that
+		// it is the one which followed the previous field last time.
 		var f *structField
-		if len(st.keyset) != 0 {
-			if n := keyset.Lookup(st.keyset, k); n < len(st.fields) {
-				if len(st.fields[n].name) == len(k) {
-					f = &st.fields[n]
-				}
-			}
+		var next *uint32
+		if prev == nil {
+			next = &st.first
 		} else {
-			f = st.fieldsIndex[string(k)]
+			next = &prev.next
 		}
 
-		if f == nil && (d.flags&DontMatchCaseInsensitiveStructFields) == 0 {
-			key = appendToLower(buf[:0], k)
-			f = st.ficaseIndex[string(key)]
+		if n := atomic.LoadUint32(next); n < uint32(len(st.fields)) && st.fields[n].name == string(k) {
+			f = &st.fields[n]
+		} else {
+			if len(st.keyset) != 0 {
+				if n := keyset.Lookup(st.keyset, k); n < len(st.fields) {
+					if len(st.fields[n].name) == len(k) {
+						f = &st.fields[n]
+					}
+				}
+			} else {
+				f = st.fieldsTable.lookup(k)
+			}
+
+			if f == nil && (d.flags&DontMatchCaseInsensitiveStructFields) == 0 {
+				key = appendToLower(buf[:0], k)
+				f = st.ficaseTable.lookup(key)
+			}
+
+			if f != nil && f.name == string(k) {
+				atomic.StoreUint32(next, uint32(f.pos))
+			}
 		}
 
 		if f == nil {
-			if (d.flags & DisallowUnknownFields) != 0 {
+			if (d.flags&DisallowUnknownFields) != 0 && (discriminated == nil || discriminated.key != string(k)) {
 				return b, fmt.Errorf("json: unknown field %q", k)
 			}
-			if _, b, _, err = d.parseValue(b); err != nil {
+			if _, b, err = d.skipValue(b); err != nil {
 				return b, err
 			}
 			continue
 		}
 
-		if b, err = f.codec.decode(d, b, unsafe.Pointer(uintptr(p)+f.offset)); err != nil {
+		if seen != nil {
+			seen[f.pos/64] |= 1 << (f.pos % 64)
+		}
+		prev = f
+
+		fd := d
+		if env != nil {
+			var deferred bool
+			if fd.discriminated, deferred, err = d.envelopeValue(b, st, f, env); deferred {
+				if _, b, err = d.skipValue(b); err != nil {
+					return b, err
+				}
+				continue
+			}
+		}
+
+		if err == nil {
+			b, err = f.codec.decode(fd, b, unsafe.Pointer(uintptr(p)+f.offset))
+		}
+
+		if err != nil {
 			if _, r, _, err := d.parseValue(input); err != nil {
 				return r, err
 			} else {
 				b = r
 			}
-			if e, ok := err.(*UnmarshalTypeError); ok {
-				e.Struct = st.typ.String() + e.Struct
-				e.Field = d.prependField(string(k), e.Field)
+			return b, d.structFieldError(st, string(k), err)
+		}
+	}
+}
+
+// structFieldError prepends the key of the struct field that err was returned
+// for to the path of the field in the error.
+func (d decoder) structFieldError(st *structType, key string, err error) error {
+	switch e := err.(type) {
+	case *UnmarshalTypeError:
+		e.Struct = st.typ.String() + e.Struct
+		e.Field = d.prependField(key, e.Field)
+	case *MissingFieldError:
+		e.Field = d.prependField(key, e.Field)
+	case *ValidationError:
+		e.Field = d.prependField(key, e.Field)
+	}
+	return err
+}
+
+// envelopeValue updates the state of the envelope fields of st before the
+// value b of field f is decoded. Discriminators are read from sibling keys,
+// and the state to decode envelope fields with is returned; when the
+// discriminator of an envelope field was not seen yet, its value is deferred
+// until the end of the object.
+func (d decoder) envelopeValue(b []byte, st *structType, f *structField, env []envelopeState) (*discriminated, bool, error) {
+	var payload *discriminated
+
+	for i := range st.envelopes {
+		e := &st.envelopes[i]
+
+		switch {
+		case f.name == e.key:
+			if hasNullPrefix(b) {
+				env[i].payload = nil
+				continue
 			}
-			return b, err
+			t, err := e.disc.typeOf(d, b)
+			if err != nil {
+				return nil, false, err
+			}
+			env[i].payload = e.disc.payloads[t]
+
+		case f.pos == e.pos:
+			if env[i].payload == nil && !hasNullPrefix(b) {
+				env[i].deferred = b
+				return nil, true, nil
+			}
+			payload = env[i].payload
+		}
+	}
+
+	return payload, false, nil
+}
+
+// decodeDeferredEnvelopes decodes the envelope fields of st which appeared in
+// the object before their discriminator.
+func (d decoder) decodeDeferredEnvelopes(p unsafe.Pointer, st *structType, env []envelopeState) error {
+	for i := range st.envelopes {
+		if env[i].deferred == nil {
+			continue
+		}
+
+		e := &st.envelopes[i]
+		if env[i].payload == nil {
+			return &MissingFieldError{Type: st.typ, Fields: []string{e.key}}
+		}
+
+		f := &st.fields[e.pos]
+		fd := d
+		fd.discriminated = env[i].payload
+
+		if _, err := f.codec.decode(fd, env[i].deferred, unsafe.Pointer(uintptr(p)+f.offset)); err != nil {
+			return d.structFieldError(st, f.name, err)
 		}
 	}
+	return nil
+}
+
+// decodeAbsentFields assigns the default values of fields that were not seen
+// in the input, and returns a *MissingFieldError listing the names of required
+// fields that were absent. When the MergeObjects flag is set, the absent fields
+// keep their existing values instead.
+func (d decoder) decodeAbsentFields(p unsafe.Pointer, st *structType, seen []uint64) error {
+	var missing []string
+	merge := (d.flags & MergeObjects) != 0
+
+	for i := range st.fields {
+		if (seen[i/64] & (1 << (i % 64))) != 0 {
+			continue
+		}
+
+		f := &st.fields[i]
+
+		if f.defaults != nil && !merge {
+			if _, err := f.defaults(d, nil, unsafe.Pointer(uintptr(p)+f.offset)); err != nil {
+				return err
+			}
+		}
+
+		if f.required {
+			missing = append(missing, f.name)
+		}
+	}
+
+	if missing != nil {
+		return &MissingFieldError{Type: st.typ, Fields: missing}
+	}
+	return nil
 }
 
 func (d decoder) decodeEmbeddedStructPointer(b []byte, p unsafe.Pointer, t reflect.Type, unexported bool, offset uintptr, decode decodeFunc) ([]byte, error) {
@@ -1245,6 +1905,20 @@ func (d decoder) decodeEmbeddedStructPointer(b []byte, p unsafe.Pointer, t refle
 	return decode(d, b, unsafe.Pointer(uintptr(v)+offset))
 }
 
+func (d decoder) decodeValidator(b []byte, p unsafe.Pointer, t reflect.Type, decode decodeFunc) ([]byte, error) {
+	null := hasNullPrefix(b)
+
+	b, err := decode(d, b, p)
+	if err != nil || null {
+		return b, err
+	}
+
+	if err := reflect.NewAt(t, p).Interface().(Validator).Validate(); err != nil {
+		return b, &ValidationError{Type: t, Err: err}
+	}
+	return b, nil
+}
+
 func (d decoder) decodePointer(b []byte, p unsafe.Pointer, t reflect.Type, decode decodeFunc) ([]byte, error) {
 	if hasNullPrefix(b) {
 		pp := *(*unsafe.Pointer)(p)
@@ -1279,7 +1953,7 @@ func (d decoder) decodeInterface(b []byte, p unsafe.Pointer) ([]byte, error) {
 			}
 		}
 
-		b, err := Parse(b, val, d.flags)
+		b, err := d.parse(b, val)
 		if err == nil {
 			*(*any)(p) = val
 		}
@@ -1294,12 +1968,24 @@ func (d decoder) decodeInterface(b []byte, p unsafe.Pointer) ([]byte, error) {
 
 	switch k.Class() {
 	case Object:
-		m := make(map[string]interface{})
-		v, err = d.decodeMapStringInterface(v, unsafe.Pointer(&m))
-		val = m
+		if (d.flags & UseOrderedObject) != 0 {
+			var obj OrderedObject
+			v, err = d.decodeOrderedObject(v, unsafe.Pointer(&obj))
+			val = obj
+		} else {
+			m, ok := val.(map[string]any)
+			if !ok || (d.flags&(MergeObjects|ReuseContainers)) == 0 {
+				m = make(map[string]interface{})
+			}
+			v, err = d.decodeMapStringInterface(v, unsafe.Pointer(&m))
+			val = m
+		}
 
 	case Array:
-		a := make([]interface{}, 0, 10)
+		a, ok := val.([]any)
+		if !ok || (d.flags&(AppendSlices|MergeSlices|ReuseContainers)) == 0 {
+			a = make([]interface{}, 0, 10)
+		}
 		v, err = d.decodeSlice(v, unsafe.Pointer(&a), unsafe.Sizeof(a[0]), sliceInterfaceType, decoder.decodeInterface)
 		val = a
 
@@ -1339,7 +2025,7 @@ func (d decoder) decodeDynamicNumber(b []byte, p unsafe.Pointer) ([]byte, error)
 
 	// Only pre-parse for numeric kind if a conditional decode
 	// has been requested.
-	if d.anyFlagsSet(UseBigInt | UseInt64 | UseUint64) {
+	if d.anyFlagsSet(UseBigInt | UseBigFloat | UseInt64 | UseUint64) {
 		_, _, kind, err = d.parseNumber(b)
 		if err != nil {
 			return b, err
@@ -1383,6 +2069,11 @@ func (d decoder) decodeDynamicNumber(b []byte, p unsafe.Pointer) ([]byte, error)
 	case kind == Int && d.anyFlagsSet(UseBigInt):
 		rem, err = decodeInto[*big.Int](anyPtr, b, d, bigIntDecoder)
 
+	// If *big.Float decode was requested, handle that case for the remaining
+	// numbers.
+	case d.anyFlagsSet(UseBigFloat):
+		rem, err = decodeInto[*big.Float](anyPtr, b, d, bigFloatDecoder)
+
 	// If json.Number decode was requested, handle that for any number.
 	case d.anyFlagsSet(UseNumber):
 		rem, err = decodeInto[Number](anyPtr, b, d, decoder.decodeNumber)
@@ -1403,15 +2094,75 @@ func (d decoder) decodeMaybeEmptyInterface(b []byte, p unsafe.Pointer, t reflect
 
 	if x := reflect.NewAt(t, p).Elem(); !x.IsNil() {
 		if e := x.Elem(); e.Kind() == reflect.Ptr {
-			return Parse(b, e.Interface(), d.flags)
+			return d.parse(b, e.Interface())
 		}
 	} else if t.NumMethod() == 0 { // empty interface
-		return Parse(b, (*any)(p), d.flags)
+		return d.parse(b, (*any)(p))
 	}
 
 	return d.decodeUnmarshalTypeError(b, p, t)
 }
 
+func (d decoder) decodeDiscriminated(b []byte, p unsafe.Pointer, disc *discriminator) ([]byte, error) {
+	x := reflect.NewAt(disc.iface, p).Elem()
+
+	if hasNullPrefix(b) {
+		x.SetZero()
+		return b[4:], nil
+	}
+
+	var t reflect.Type
+	var discriminated *discriminated
+
+	if d.discriminated != nil && d.discriminated.typ != nil {
+		// Envelope field, the type was decided by the sibling key.
+		t = d.discriminated.typ
+	} else {
+		if len(b) == 0 || b[0] != '{' {
+			return d.inputError(b, disc.iface)
+		}
+		var err error
+		if t, err = disc.lookup(d, b); err != nil {
+			return b, err
+		}
+		discriminated = disc.object
+	}
+
+	// Decode directly into a new value of the concrete type, so errors report
+	// the path of the fields within the value.
+	var v, ptr reflect.Value
+	if t.Kind() == reflect.Ptr {
+		v = reflect.New(t.Elem())
+		ptr = v
+	} else {
+		ptr = reflect.New(t)
+		v = ptr.Elem()
+	}
+
+	d.discriminated = nil
+	if ptr.Elem().Kind() == reflect.Struct {
+		d.discriminated = discriminated
+	}
+
+	r, err := codecOf(ptr.Elem().Type()).decode(d, b, ptr.UnsafePointer())
+	if err != nil {
+		return r, err
+	}
+
+	x.Set(v)
+	return r, nil
+}
+
+// decodeEnvelopeKey decodes the value of the sibling key injected for an
+// envelope field, the discriminator was already read by envelopeValue.
+func (d decoder) decodeEnvelopeKey(b []byte, p unsafe.Pointer) ([]byte, error) {
+	if hasNullPrefix(b) {
+		return b[4:], nil
+	}
+	_, r, _, err := d.parseString(b)
+	return r, err
+}
+
 func (d decoder) decodeUnmarshalTypeError(b []byte, _ unsafe.Pointer, t reflect.Type) ([]byte, error) {
 	v, b, _, err := d.parseValue(b)
 	if err != nil {
@@ -1424,7 +2175,7 @@ func (d decoder) decodeUnmarshalTypeError(b []byte, _ unsafe.Pointer, t reflect.
 }
 
 func (d decoder) decodeRawMessage(b []byte, p unsafe.Pointer) ([]byte, error) {
-	v, r, _, err := d.parseValue(b)
+	v, r, err := d.skipValue(b)
 	if err != nil {
 		return d.inputError(b, rawMessageType)
 	}
@@ -1455,6 +2206,41 @@ func (d decoder) decodeJSONUnmarshaler(b []byte, p unsafe.Pointer, t reflect.Typ
 	return b, u.Interface().(Unmarshaler).UnmarshalJSON(v)
 }
 
+func (d decoder) decodeJSONUnmarshalerFrom(b []byte, p unsafe.Pointer, t reflect.Type, pointer bool) ([]byte, error) {
+	v, b, _, err := d.parseValue(b)
+	if err != nil {
+		return b, err
+	}
+
+	u := reflect.NewAt(t, p)
+	if !pointer {
+		u = u.Elem()
+		t = t.Elem()
+	}
+	if u.IsNil() {
+		u.Set(reflect.New(t))
+	}
+
+	tok := tokenizerPool.Get().(*Tokenizer)
+	tok.Reset(v)
+
+	err = u.Interface().(UnmarshalerFrom).UnmarshalJSONFrom(tok)
+	if err == nil {
+		err = tok.Err
+	}
+	if err == nil && len(skipSpaces(tok.json)) != 0 {
+		err = fmt.Errorf("json: UnmarshalJSONFrom of %s did not read the entire value %q", t, prefix(v))
+	}
+
+	tok.Reset(nil)
+	tokenizerPool.Put(tok)
+	return b, err
+}
+
+var tokenizerPool = sync.Pool{
+	New: func() any { return new(Tokenizer) },
+}
+
 func (d decoder) decodeTextUnmarshaler(b []byte, p unsafe.Pointer, t reflect.Type, pointer bool) ([]byte, error) {
 	var value string
 
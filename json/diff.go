@@ -0,0 +1,344 @@
+package json
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// DiffOp is the kind of change described by a Difference.
+type DiffOp int
+
+const (
+	// Added is the kind of values which only exist in the second document.
+	Added DiffOp = iota
+	// Removed is the kind of values which only exist in the first document.
+	Removed
+	// Changed is the kind of values which exist in both documents but are
+	// not equal.
+	Changed
+)
+
+// String returns a lowercase name of op.
+func (op DiffOp) String() string {
+	switch op {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	default:
+		return "DiffOp(" + strconv.Itoa(int(op)) + ")"
+	}
+}
+
+// Difference describes a value which differs between two json documents, as
+// reported by Diff.
+type Difference struct {
+	Op DiffOp
+
+	// The location of the value in the documents, formatted as a JSON Pointer
+	// (RFC 6901). The root of the documents is the empty string.
+	Path string
+
+	// The raw json values in the first and second documents, which reference
+	// the inputs passed to Diff. Old is nil when Op is Added, and New is nil
+	// when Op is Removed.
+	Old RawMessage
+	New RawMessage
+}
+
+// String returns a human readable representation of d, for example:
+//
+//	changed /user/id: 42 => "42"
+func (d Difference) String() string {
+	switch d.Op {
+	case Added:
+		return d.Op.String() + " " + d.Path + ": " + string(d.New)
+	case Removed:
+		return d.Op.String() + " " + d.Path + ": " + string(d.Old)
+	default:
+		return d.Op.String() + " " + d.Path + ": " + string(d.Old) + " => " + string(d.New)
+	}
+}
+
+// Equal returns true if a and b contain semantically equal json documents.
+//
+// The comparison ignores whitespace and the order of object members, strings
+// are compared after being unescaped, and numbers are compared by value (1,
+// 1.0, and 10e-1 are all equal). When an object has duplicate keys, the last
+// value is the one which is compared, like when decoding into a map.
+//
+// The function returns false if a or b are not valid json.
+func Equal(a, b []byte) bool {
+	a, err := parseDocument(a)
+	if err != nil {
+		return false
+	}
+	b, err = parseDocument(b)
+	if err != nil {
+		return false
+	}
+	d := differ{equal: true}
+	d.diff(a, b)
+	return len(d.diffs) == 0
+}
+
+// Diff returns the differences between the json documents a and b, using the
+// same semantics as Equal. The returned slice is empty if the documents are
+// equal.
+//
+// Objects are compared member by member, reporting the values whose keys only
+// exist in one of the documents as Added or Removed. Arrays are compared
+// element by element at the same positions, reporting extra elements at the
+// end of either array as Added or Removed. Values of different types are
+// reported as Changed.
+//
+// The differences are reported in the order of the values in a, followed by
+// the values which only exist in b, in their order in b.
+//
+// An error is returned if a or b are not valid json.
+func Diff(a, b []byte) ([]Difference, error) {
+	a, err := parseDocument(a)
+	if err != nil {
+		return nil, err
+	}
+	b, err = parseDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	d := differ{}
+	d.diff(a, b)
+	return d.diffs, nil
+}
+
+type differ struct {
+	diffs []Difference
+	// The JSON Pointer of the values being compared.
+	path []byte
+	// When set, the differ stops at the first difference.
+	equal bool
+}
+
+func (d *differ) diff(a, b []byte) {
+	if d.equal && len(d.diffs) != 0 {
+		return
+	}
+
+	switch {
+	case a[0] == '{' && b[0] == '{':
+		d.diffObjects(a, b)
+	case a[0] == '[' && b[0] == '[':
+		d.diffArrays(a, b)
+	case !equalScalars(a, b):
+		d.report(Changed, a, b)
+	}
+}
+
+func (d *differ) diffObjects(a, b []byte) {
+	ma := appendMembers(nil, a)
+	mb := appendMembers(nil, b)
+	ia := lastMembers(ma)
+	ib := lastMembers(mb)
+
+	for i, m := range ma {
+		if ia[string(m.key)] != i {
+			continue
+		}
+		n := len(d.path)
+		d.path = appendPointerKey(d.path, m.key)
+		if j, ok := ib[string(m.key)]; ok {
+			d.diff(m.value, mb[j].value)
+		} else {
+			d.report(Removed, m.value, nil)
+		}
+		d.path = d.path[:n]
+	}
+
+	for j, m := range mb {
+		if ib[string(m.key)] != j {
+			continue
+		}
+		if _, ok := ia[string(m.key)]; !ok {
+			n := len(d.path)
+			d.path = appendPointerKey(d.path, m.key)
+			d.report(Added, nil, m.value)
+			d.path = d.path[:n]
+		}
+	}
+}
+
+func (d *differ) diffArrays(a, b []byte) {
+	ma := appendMembers(nil, a)
+	mb := appendMembers(nil, b)
+
+	for i := range max(len(ma), len(mb)) {
+		n := len(d.path)
+		d.path = strconv.AppendInt(append(d.path, '/'), int64(i), 10)
+		switch {
+		case i >= len(mb):
+			d.report(Removed, ma[i].value, nil)
+		case i >= len(ma):
+			d.report(Added, nil, mb[i].value)
+		default:
+			d.diff(ma[i].value, mb[i].value)
+		}
+		d.path = d.path[:n]
+	}
+}
+
+func (d *differ) report(op DiffOp, a, b []byte) {
+	if d.equal && len(d.diffs) != 0 {
+		return
+	}
+	d.diffs = append(d.diffs, Difference{
+		Op:   op,
+		Path: string(d.path),
+		Old:  RawMessage(a),
+		New:  RawMessage(b),
+	})
+}
+
+// member is an element of a json object or array, the key is nil in arrays.
+type member struct {
+	key   []byte
+	value []byte
+}
+
+// appendMembers appends the members of the valid json object or array v to m,
+// the keys of object members are unescaped.
+func appendMembers(m []member, v []byte) []member {
+	t := tokenizerPool.Get().(*Tokenizer)
+	t.Reset(v)
+	t.Next() // opening delimiter
+
+	// Keys are tracked here rather than with t.IsKey since nested objects
+	// and arrays are captured without being tokenized.
+	object := v[0] == '{'
+	expectKey := object
+
+	var key []byte
+loop:
+	for t.Next() {
+		switch {
+		case t.Delim == '}' || t.Delim == ']':
+			// Nested objects and arrays are captured, so this can only be
+			// the closing delimiter of v.
+			break loop
+		case t.Delim == ':':
+		case t.Delim == ',':
+			expectKey = object
+		case expectKey:
+			key, expectKey = t.String(), false
+		default:
+			m = append(m, member{key: key, value: t.Capture()})
+		}
+	}
+
+	t.Reset(nil)
+	tokenizerPool.Put(t)
+	return m
+}
+
+// lastMembers returns the index of the last member with each key of m.
+func lastMembers(m []member) map[string]int {
+	index := make(map[string]int, len(m))
+	for i := range m {
+		index[string(m[i].key)] = i
+	}
+	return index
+}
+
+func appendPointerKey(b, key []byte) []byte {
+	b = append(b, '/')
+	for _, c := range key {
+		switch c {
+		case '~':
+			b = append(b, '~', '0')
+		case '/':
+			b = append(b, '~', '1')
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// equalScalars compares the json values a and b, which are expected to be
+// scalars. Values of different types are never equal.
+func equalScalars(a, b []byte) bool {
+	switch {
+	case bytes.Equal(a, b):
+		return true
+	case a[0] == '"' && b[0] == '"':
+		return equalStrings(a, b)
+	case RawValue(a).Number() && RawValue(b).Number():
+		return equalNumbers(a, b)
+	default:
+		return false
+	}
+}
+
+// equalStrings compares the json strings a and b after unescaping them.
+func equalStrings(a, b []byte) bool {
+	if bytes.IndexByte(a, '\\') < 0 && bytes.IndexByte(b, '\\') < 0 {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(RawValue(a).Unquote(), RawValue(b).Unquote())
+}
+
+// equalNumbers compares the json numbers a and b by value, the comparison is
+// exact and does not lose precision on large or long numbers.
+func equalNumbers(a, b []byte) bool {
+	var bufA, bufB [32]byte
+	negA, digitsA, expA := normalizeNumber(bufA[:0], a)
+	negB, digitsB, expB := normalizeNumber(bufB[:0], b)
+	return negA == negB && expA == expB && bytes.Equal(digitsA, digitsB)
+}
+
+// normalizeNumber decomposes the json number b into its sign, significant
+// digits, and exponent, such that b = ±digits × 10^exp. The digits are
+// appended to buf and have no leading or trailing zeros; zero has no digits
+// and is never negative.
+func normalizeNumber(buf, b []byte) (neg bool, digits []byte, exp int) {
+	if b[0] == '-' {
+		neg, b = true, b[1:]
+	}
+
+	i := strings.IndexFunc(string(b), isNotDigit)
+	if i < 0 {
+		i = len(b)
+	}
+	digits, b = append(buf, b[:i]...), b[i:]
+
+	if len(b) != 0 && b[0] == '.' {
+		b = b[1:]
+		if i = strings.IndexFunc(string(b), isNotDigit); i < 0 {
+			i = len(b)
+		}
+		digits, b = append(digits, b[:i]...), b[i:]
+		exp -= i
+	}
+
+	if len(b) != 0 {
+		// The exponent saturates on overflow, such values are far beyond
+		// the range of any numeric type anyway.
+		e, _ := strconv.Atoi(string(b[1:]))
+		exp += e
+	}
+
+	digits = bytes.TrimLeft(digits, "0")
+	n := len(digits)
+	digits = bytes.TrimRight(digits, "0")
+	exp += n - len(digits)
+
+	if len(digits) == 0 {
+		return false, digits, 0
+	}
+	return neg, digits, exp
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
@@ -0,0 +1,205 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// discriminator holds the configuration of an interface type registered with
+// RegisterDiscriminator.
+type discriminator struct {
+	iface reflect.Type
+	field string
+	types map[string]reflect.Type
+	// Pre-encoded `"field":` key and names of each concrete type, which are
+	// injected in the JSON objects that values are encoded as.
+	key   []byte
+	names map[reflect.Type][]byte
+	// States passed to the decoders of concrete types, see discriminated.
+	object   *discriminated
+	payloads map[reflect.Type]*discriminated
+}
+
+// discriminated is passed to the decode functions of values held in interfaces
+// registered with RegisterDiscriminator (see decoder.discriminated).
+type discriminated struct {
+	// key is the discriminator field of the object decoded into a concrete
+	// type, which decodeStruct does not report as an unknown field.
+	key string
+	// typ is the concrete type of an envelope payload, decided by the value
+	// of its sibling key.
+	typ reflect.Type
+}
+
+var discriminators sync.Map // map[reflect.Type]*discriminator
+
+func lookupDiscriminator(t reflect.Type) *discriminator {
+	if disc, ok := discriminators.Load(t); ok {
+		return disc.(*discriminator)
+	}
+	return nil
+}
+
+// RegisterDiscriminator registers the concrete types that values of the
+// interface type iface may hold, indexed by the value of a discriminator field
+// in the JSON objects that they are encoded as.
+//
+// When decoding into a value of type iface, the decoder looks up the
+// discriminator field of the object, then decodes the object into a new value
+// of the associated type. When encoding a value of type iface, the
+// discriminator is injected as the first field of the object. For example:
+//
+//	json.RegisterDiscriminator(reflect.TypeOf((*Event)(nil)).Elem(), "type", map[string]reflect.Type{
+//		"click": reflect.TypeOf(&Click{}),
+//		"view":  reflect.TypeOf(&View{}),
+//	})
+//
+// Concrete types must be encoded as JSON objects, and should not have a field
+// named after the discriminator. Pointer types are decoded into newly allocated
+// values. Decoding is fastest when the discriminator is the first field of the
+// objects, which is where the encoder writes it.
+//
+// The discriminator may also be held by a sibling key of the object that a
+// struct field of type iface is a member of, when the field is tagged with the
+// "discriminator:" option naming that key. The field value is then encoded
+// without injecting the discriminator, and does not need to be an object:
+//
+//	type Envelope struct {
+//		ID      string `json:"id"`
+//		Payload Event  `json:"payload,discriminator:type"`
+//	}
+//
+// Values of Envelope are encoded as {"id":"1","type":"click","payload":{...}},
+// and the key may appear before or after the payload when decoding. If the
+// struct has a field for the key, the field is decoded and encoded like any
+// other instead of the key being injected.
+//
+// The function panics if iface is not an interface type with methods, if one
+// of the types does not implement it or is registered more than once, or if
+// iface was already registered. It is intended to be called during program
+// initialization, before values of type iface are encoded or decoded; it is
+// safe to call concurrently with encoding and decoding, but values encoded or
+// decoded concurrently with the registration may not observe it.
+func RegisterDiscriminator(iface reflect.Type, field string, types map[string]reflect.Type) {
+	if iface.Kind() != reflect.Interface || iface.NumMethod() == 0 {
+		panic(fmt.Errorf("json: cannot register discriminator for %s: not an interface type with methods", iface))
+	}
+
+	disc := &discriminator{
+		iface:    iface,
+		field:    field,
+		types:    make(map[string]reflect.Type, len(types)),
+		names:    make(map[reflect.Type][]byte, len(types)),
+		object:   &discriminated{key: field},
+		payloads: make(map[reflect.Type]*discriminated, len(types)),
+	}
+	disc.key, _ = Append(nil, field, 0)
+	disc.key = append(disc.key, ':')
+
+	for name, t := range types {
+		if !t.Implements(iface) {
+			panic(fmt.Errorf("json: cannot register discriminator %q for %s: %s does not implement the interface", name, iface, t))
+		}
+		if _, dup := disc.names[t]; dup {
+			panic(fmt.Errorf("json: cannot register discriminator %q for %s: %s is already registered", name, iface, t))
+		}
+		disc.types[name] = t
+		disc.names[t], _ = Append(nil, name, 0)
+		disc.payloads[t] = &discriminated{typ: t}
+	}
+
+	if _, loaded := discriminators.LoadOrStore(iface, disc); loaded {
+		panic(fmt.Errorf("json: discriminator for %s is already registered", iface))
+	}
+
+	// Codecs of types that embed the interface may have been constructed
+	// already, they are discarded so the discriminator is taken into account.
+	cacheReset()
+}
+
+// lookup searches the discriminator field among the keys of the JSON object b,
+// and returns the concrete type that it maps to. The values of other fields
+// are skipped without being decoded, so when the discriminator is the first
+// field the object is only decoded once, by the codec of the concrete type.
+func (disc *discriminator) lookup(d decoder, b []byte) (reflect.Type, error) {
+	b = skipSpaces(b[1:])
+
+	for i := 0; len(b) == 0 || b[0] != '}'; i++ {
+		if i != 0 {
+			if len(b) == 0 {
+				return nil, syntaxError(b, "unexpected end of JSON input after object field value")
+			}
+			if b[0] != ',' {
+				return nil, syntaxError(b, "expected ',' after object field value but found '%c'", b[0])
+			}
+			b = skipSpaces(b[1:])
+		}
+
+		k, r, _, err := d.parseStringUnquote(b, nil)
+		if err != nil {
+			_, err = objectKeyError(b, err)
+			return nil, err
+		}
+		r = skipSpaces(r)
+
+		if len(r) == 0 {
+			return nil, syntaxError(r, "unexpected end of JSON input after object field key")
+		}
+		if r[0] != ':' {
+			return nil, syntaxError(r, "expected ':' after object field key but found '%c'", r[0])
+		}
+		r = skipSpaces(r[1:])
+
+		if string(k) == disc.field {
+			t, err := disc.typeOf(d, r)
+			if e, ok := err.(*UnmarshalTypeError); ok {
+				e.Field = disc.field
+			}
+			return t, err
+		}
+
+		if _, r, err = d.skipValue(r); err != nil {
+			return nil, err
+		}
+		b = skipSpaces(r)
+	}
+
+	return nil, &MissingFieldError{Type: disc.iface, Fields: []string{disc.field}}
+}
+
+// typeOf returns the concrete type that the discriminator at the beginning of
+// b maps to.
+func (disc *discriminator) typeOf(d decoder, b []byte) (reflect.Type, error) {
+	if len(b) == 0 || b[0] != '"' {
+		return nil, &UnmarshalTypeError{Value: "discriminator " + prefix(b), Type: disc.iface}
+	}
+
+	name, _, _, err := d.parseStringUnquote(b, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := disc.types[string(name)]
+	if !ok {
+		return nil, &UnmarshalTypeError{Value: "discriminator " + strconv.Quote(string(name)), Type: disc.iface}
+	}
+	return t, nil
+}
+
+// envelopeField describes a struct field tagged with the "discriminator:"
+// option, whose concrete type is decided by the value of a sibling key.
+type envelopeField struct {
+	key  string
+	pos  int
+	disc *discriminator
+}
+
+// envelopeState tracks the decoding of an envelope field in an object.
+type envelopeState struct {
+	// discriminator read from the sibling key, nil until it was seen
+	payload *discriminated
+	// input of the field value when it appeared before the sibling key
+	deferred []byte
+}
@@ -1,14 +1,19 @@
 package json
 
 import (
+	"bytes"
 	"encoding"
+	hexenc "encoding/hex"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
+	"unicode/utf16"
 	"unicode/utf8"
 	"unsafe"
 
@@ -21,6 +26,27 @@ func (e encoder) encodeNull(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return append(b, "null"...), nil
 }
 
+func (e encoder) encodeNilSlice(b []byte) []byte {
+	if (e.flags & NilSlicesAsEmpty) != 0 {
+		return append(b, "[]"...)
+	}
+	return append(b, "null"...)
+}
+
+func (e encoder) encodeNilMap(b []byte) []byte {
+	if (e.flags & NilMapsAsEmpty) != 0 {
+		return append(b, "{}"...)
+	}
+	return append(b, "null"...)
+}
+
+func (e encoder) encodeNilBytes(b []byte) []byte {
+	if (e.flags & NilSlicesAsEmpty) != 0 {
+		return append(b, `""`...)
+	}
+	return append(b, "null"...)
+}
+
 func (e encoder) encodeBool(b []byte, p unsafe.Pointer) ([]byte, error) {
 	if *(*bool)(p) {
 		return append(b, "true"...), nil
@@ -81,11 +107,8 @@ func (e encoder) encodeFloat64(b []byte, p unsafe.Pointer) ([]byte, error) {
 }
 
 func (e encoder) encodeFloat(b []byte, f float64, bits int) ([]byte, error) {
-	switch {
-	case math.IsNaN(f):
-		return b, &UnsupportedValueError{Value: reflect.ValueOf(f), Str: "NaN"}
-	case math.IsInf(f, 0):
-		return b, &UnsupportedValueError{Value: reflect.ValueOf(f), Str: "inf"}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return e.encodeNonFiniteFloat(b, f)
 	}
 
 	// Convert as if by ES6 number to string conversion.
@@ -105,17 +128,133 @@ func (e encoder) encodeFloat(b []byte, f float64, bits int) ([]byte, error) {
 	b = strconv.AppendFloat(b, f, fmt, -1, int(bits))
 
 	if fmt == 'e' {
-		// clean up e-09 to e-9
-		n := len(b)
-		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
-			b[n-2] = b[n-1]
-			b = b[:n-1]
-		}
+		b = trimExponent(b)
 	}
 
 	return b, nil
 }
 
+// trimExponent cleans up e-09 to e-9 at the end of b.
+func trimExponent(b []byte) []byte {
+	n := len(b)
+	if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+		b[n-2] = b[n-1]
+		b = b[:n-1]
+	}
+	return b
+}
+
+// encodeBigFloat writes the output of the MarshalText method as a json string,
+// or when the BigValuesAsNumbers flag is set, the shortest decimal
+// representation which rounds to the same value at its precision, so values
+// decoded from json numbers are encoded back without being rounded.
+func (e encoder) encodeBigFloat(b []byte, p unsafe.Pointer) ([]byte, error) {
+	x := (*big.Float)(p)
+
+	if (e.flags & BigValuesAsNumbers) == 0 {
+		return e.encodeBigText(b, x)
+	}
+
+	if x.IsInf() {
+		return e.encodeNonFiniteFloat(b, math.Inf(x.Sign()))
+	}
+
+	// Use the same exponent cutoffs as for float64 values, the conversion only
+	// needs to be precise enough to compare with them.
+	f, _ := x.Float64()
+	format := byte('f')
+	if abs := math.Abs(f); x.Sign() != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	b = x.Append(b, format, -1)
+
+	if format == 'e' {
+		b = trimExponent(b)
+	}
+
+	return b, nil
+}
+
+// encodeBigRat writes the output of the MarshalText method as a json string,
+// or when the BigValuesAsNumbers flag is set, the exact decimal representation
+// of the number if its denominator has no prime factors other than 2 and 5.
+// Other rationals are always written as strings since their decimal
+// representation does not terminate.
+func (e encoder) encodeBigRat(b []byte, p unsafe.Pointer) ([]byte, error) {
+	x := (*big.Rat)(p)
+
+	if (e.flags & BigValuesAsNumbers) == 0 {
+		return e.encodeBigText(b, x)
+	}
+
+	if x.IsInt() {
+		return x.Num().Append(b, 10), nil
+	}
+
+	n, ok := decimalPlaces(x.Denom())
+	if !ok {
+		return e.encodeBigText(b, x)
+	}
+
+	return append(b, x.FloatString(n)...), nil
+}
+
+// encodeBigText writes the output of the MarshalText method of x as a json
+// string, which is how the standard encoding/json package encodes big.Float
+// and big.Rat values.
+func (e encoder) encodeBigText(b []byte, x encoding.TextMarshaler) ([]byte, error) {
+	t, err := x.MarshalText()
+	if err != nil {
+		return b, err
+	}
+	s := string(t)
+	return e.encodeString(b, unsafe.Pointer(&s))
+}
+
+// decimalPlaces returns the number of decimal places needed to represent the
+// fractions of denominator d exactly, and false if they are infinite.
+func decimalPlaces(d *big.Int) (int, bool) {
+	twos := d.TrailingZeroBits()
+	fives := 0
+	q := new(big.Int).Rsh(d, twos)
+	r := new(big.Int)
+
+	for !q.IsInt64() || q.Int64() != 1 {
+		if q.QuoRem(q, big5, r); r.Sign() != 0 {
+			return 0, false
+		}
+		fives++
+	}
+
+	return max(int(twos), fives), true
+}
+
+var big5 = big.NewInt(5)
+
+func (e encoder) encodeNonFiniteFloat(b []byte, f float64) ([]byte, error) {
+	switch {
+	case (e.flags & NonFiniteFloatsAsNull) != 0:
+		return append(b, "null"...), nil
+
+	case (e.flags & NonFiniteFloatsAsString) != 0:
+		switch {
+		case math.IsNaN(f):
+			return append(b, `"NaN"`...), nil
+		case f > 0:
+			return append(b, `"Infinity"`...), nil
+		default:
+			return append(b, `"-Infinity"`...), nil
+		}
+
+	case math.IsNaN(f):
+		return b, &UnsupportedValueError{Value: reflect.ValueOf(f), Str: "NaN"}
+
+	default:
+		return b, &UnsupportedValueError{Value: reflect.ValueOf(f), Str: "inf"}
+	}
+}
+
 func (e encoder) encodeNumber(b []byte, p unsafe.Pointer) ([]byte, error) {
 	n := *(*Number)(p)
 	if n == "" {
@@ -194,6 +333,14 @@ func (e encoder) encodeString(b []byte, p unsafe.Pointer) ([]byte, error) {
 			continue
 		}
 
+		if (e.flags & EscapeNonASCII) != 0 {
+			b = append(b, s[i:j]...)
+			b = appendEscapedRune(b, r)
+			i = j + size
+			j = j + size
+			continue
+		}
+
 		switch r {
 		case '\u2028', '\u2029':
 			// U+2028 is LINE SEPARATOR.
@@ -219,6 +366,20 @@ func (e encoder) encodeString(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return b, nil
 }
 
+// appendEscapedRune appends the \uXXXX escape sequence of r to b, made of a
+// UTF-16 surrogate pair if r is outside of the Basic Multilingual Plane.
+func appendEscapedRune(b []byte, r rune) []byte {
+	if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		b = appendEscapedUTF16(b, r1)
+		r = r2
+	}
+	return appendEscapedUTF16(b, r)
+}
+
+func appendEscapedUTF16(b []byte, r rune) []byte {
+	return append(b, '\\', 'u', hex[r>>12&0xF], hex[r>>8&0xF], hex[r>>4&0xF], hex[r&0xF])
+}
+
 func (e encoder) encodeToString(b []byte, p unsafe.Pointer, encode encodeFunc) ([]byte, error) {
 	i := len(b)
 
@@ -238,13 +399,43 @@ func (e encoder) encodeToString(b []byte, p unsafe.Pointer, encode encodeFunc) (
 	return b[:i+n], nil
 }
 
+// encodeFloatToString is like encodeToString for fields of type t, which is a
+// float type or a pointer to one. Non-finite values that the
+// NonFiniteFloatsAsNull and NonFiniteFloatsAsString flags allow are written as
+// a bare null or as their "NaN", "Infinity", or "-Infinity" strings, they are
+// not encoded a second time as a string.
+func (e encoder) encodeFloatToString(b []byte, p unsafe.Pointer, t reflect.Type, encode encodeFunc) ([]byte, error) {
+	if (e.flags & (NonFiniteFloatsAsNull | NonFiniteFloatsAsString)) != 0 {
+		v := p
+		if t.Kind() == reflect.Ptr {
+			v, t = *(*unsafe.Pointer)(p), t.Elem()
+		}
+		if v != nil {
+			var f float64
+			if t.Kind() == reflect.Float32 {
+				f = float64(*(*float32)(v))
+			} else {
+				f = *(*float64)(v)
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return encode(e, b, p)
+			}
+		}
+	}
+	return e.encodeToString(b, p, encode)
+}
+
 func (e encoder) encodeBytes(b []byte, p unsafe.Pointer) ([]byte, error) {
+	return e.encodeBytesBase64(b, p, base64.StdEncoding)
+}
+
+func (e encoder) encodeBytesBase64(b []byte, p unsafe.Pointer, enc *base64.Encoding) ([]byte, error) {
 	v := *(*[]byte)(p)
 	if v == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilBytes(b), nil
 	}
 
-	n := base64.StdEncoding.EncodedLen(len(v)) + 2
+	n := enc.EncodedLen(len(v)) + 2
 
 	if avail := cap(b) - len(b); avail < n {
 		newB := make([]byte, cap(b)+(n-avail))
@@ -257,11 +448,23 @@ func (e encoder) encodeBytes(b []byte, p unsafe.Pointer) ([]byte, error) {
 
 	b = b[:j]
 	b[i] = '"'
-	base64.StdEncoding.Encode(b[i+1:j-1], v)
+	enc.Encode(b[i+1:j-1], v)
 	b[j-1] = '"'
 	return b, nil
 }
 
+func (e encoder) encodeBytesHex(b []byte, p unsafe.Pointer) ([]byte, error) {
+	v := *(*[]byte)(p)
+	if v == nil {
+		return e.encodeNilBytes(b), nil
+	}
+
+	b = append(b, '"')
+	b = hexenc.AppendEncode(b, v)
+	b = append(b, '"')
+	return b, nil
+}
+
 func (e encoder) encodeDuration(b []byte, p unsafe.Pointer) ([]byte, error) {
 	b = append(b, '"')
 	b = appendDuration(b, *(*time.Duration)(p))
@@ -277,6 +480,59 @@ func (e encoder) encodeTime(b []byte, p unsafe.Pointer) ([]byte, error) {
 	return b, nil
 }
 
+func (e encoder) encodeTimeLayout(b []byte, p unsafe.Pointer, layout string) ([]byte, error) {
+	t := *(*time.Time)(p)
+	b = append(b, '"')
+	b = t.AppendFormat(b, layout)
+	b = append(b, '"')
+	return b, nil
+}
+
+// encodeUnixTime encodes the time as an integer number of units elapsed since
+// the Unix epoch. The sub-unit part of the time is truncated, which matches the
+// behavior of methods like time.Time.UnixMilli.
+func (e encoder) encodeUnixTime(b []byte, p unsafe.Pointer, unit int64) ([]byte, error) {
+	t := *(*time.Time)(p)
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	per := secondUnit / unit
+	return appendInt(b, sec*per+nsec/unit), nil
+}
+
+// encodeDurationUnit encodes the duration as a number of units, the fractional
+// part is only written when the duration is not a multiple of the unit.
+func (e encoder) encodeDurationUnit(b []byte, p unsafe.Pointer, unit int64) ([]byte, error) {
+	d := int64(*(*time.Duration)(p))
+	whole, frac := d/unit, d%unit
+
+	if d < 0 {
+		b = append(b, '-')
+		// Negating MinInt64 overflows, but its magnitude is correctly
+		// represented when converted to uint64.
+		b = appendUint(b, uint64(-whole))
+		frac = -frac
+	} else {
+		b = appendUint(b, uint64(whole))
+	}
+
+	if frac != 0 {
+		var buf [20]byte
+		i := len(buf)
+		for u := unit; u > 1; u /= 10 {
+			i--
+			buf[i] = byte('0' + frac%10)
+			frac /= 10
+		}
+		digits := buf[i:]
+		for digits[len(digits)-1] == '0' {
+			digits = digits[:len(digits)-1]
+		}
+		b = append(b, '.')
+		b = append(b, digits...)
+	}
+
+	return b, nil
+}
+
 func (e encoder) encodeArray(b []byte, p unsafe.Pointer, n int, size uintptr, t reflect.Type, encode encodeFunc) ([]byte, error) {
 	start := len(b)
 	var err error
@@ -287,7 +543,10 @@ func (e encoder) encodeArray(b []byte, p unsafe.Pointer, n int, size uintptr, t
 			b = append(b, ',')
 		}
 		if b, err = encode(e, b, unsafe.Pointer(uintptr(p)+(uintptr(i)*size))); err != nil {
-			return b[:start], err
+			return e.truncate(b, start), err
+		}
+		if b, err = e.flushElement(b); err != nil {
+			return e.truncate(b, start), err
 		}
 	}
 
@@ -299,7 +558,7 @@ func (e encoder) encodeSlice(b []byte, p unsafe.Pointer, size uintptr, t reflect
 	s := (*slice)(p)
 
 	if s.data == nil && s.len == 0 && s.cap == 0 {
-		return append(b, "null"...), nil
+		return e.encodeNilSlice(b), nil
 	}
 
 	return e.encodeArray(b, s.data, s.len, size, t, encode)
@@ -308,7 +567,7 @@ func (e encoder) encodeSlice(b []byte, p unsafe.Pointer, size uintptr, t reflect
 func (e encoder) encodeMap(b []byte, p unsafe.Pointer, t reflect.Type, encodeKey, encodeValue encodeFunc, sortKeys sortFunc) ([]byte, error) {
 	m := reflect.NewAt(t, p).Elem()
 	if m.IsNil() {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	keys := m.MapKeys()
@@ -328,13 +587,196 @@ func (e encoder) encodeMap(b []byte, p unsafe.Pointer, t reflect.Type, encodeKey
 		}
 
 		if b, err = encodeKey(e, b, (*iface)(unsafe.Pointer(&k)).ptr); err != nil {
-			return b[:start], err
+			return e.truncate(b, start), err
 		}
 
 		b = append(b, ':')
 
 		if b, err = encodeValue(e, b, (*iface)(unsafe.Pointer(&v)).ptr); err != nil {
-			return b[:start], err
+			return e.truncate(b, start), err
+		}
+		if b, err = e.flushElement(b); err != nil {
+			return e.truncate(b, start), err
+		}
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+// flush writes b to the output of the Encoder that the value is encoded by, if
+// any, and returns b truncated to be reused. It does nothing until b has grown
+// past the flush threshold, in order to issue writes of reasonable sizes.
+//
+// Once flushed, the output cannot be rolled back on errors.
+func (e encoder) flush(b []byte) ([]byte, error) {
+	if e.stream == nil {
+		return b, nil
+	}
+	threshold := e.stream.flushThreshold
+	if threshold == 0 {
+		threshold = flushThreshold
+	}
+	if len(b) < threshold {
+		return b, nil
+	}
+	if e.stream.framing == Lines {
+		b = removeNewlines(b)
+	}
+	e.stream.flushed = true
+	if _, err := e.stream.writer.Write(b); err != nil {
+		e.stream.err = err
+		return b, err
+	}
+	return b[:0], nil
+}
+
+// truncate returns b truncated to start, its length before encoding a value
+// which failed to encode. When part of the output was flushed, b cannot be
+// rolled back and is returned as is; the Encoder fails anyway in this case.
+func (e encoder) truncate(b []byte, start int) []byte {
+	if e.stream != nil && e.stream.flushed {
+		return b
+	}
+	return b[:start]
+}
+
+// removeNewlines removes the newlines from the JSON output in b. They can only
+// be whitespace copied from raw messages or the output of marshalers, since
+// newlines are escaped in strings.
+func removeNewlines(b []byte) []byte {
+	if bytes.IndexByte(b, nl) < 0 {
+		return b
+	}
+	return slices.DeleteFunc(b, func(c byte) bool { return c == nl })
+}
+
+// flushElement is called after encoding elements of arrays, maps, and structs,
+// it only flushes the output if enabled with Encoder.SetFlushThreshold.
+func (e encoder) flushElement(b []byte) ([]byte, error) {
+	if e.stream == nil || e.stream.flushThreshold == 0 {
+		return b, nil
+	}
+	return e.flush(b)
+}
+
+var (
+	yieldTrue  = []reflect.Value{reflect.ValueOf(true)}
+	yieldFalse = []reflect.Value{reflect.ValueOf(false)}
+)
+
+func (e encoder) encodeSeq(b []byte, p unsafe.Pointer, t reflect.Type, encode encodeFunc) ([]byte, error) {
+	seq := reflect.NewAt(t, p).Elem()
+	if seq.IsNil() {
+		return append(b, "null"...), nil
+	}
+
+	start := len(b)
+	var err error
+	var n int
+	b = append(b, '[')
+
+	yield := reflect.MakeFunc(t.In(0), func(args []reflect.Value) []reflect.Value {
+		if err != nil {
+			return yieldFalse
+		}
+
+		if n != 0 {
+			b = append(b, ',')
+		}
+		n++
+
+		v := args[0]
+		if b, err = encode(e, b, (*iface)(unsafe.Pointer(&v)).ptr); err != nil {
+			return yieldFalse
+		}
+		if b, err = e.flush(b); err != nil {
+			return yieldFalse
+		}
+		return yieldTrue
+	})
+
+	seq.Call([]reflect.Value{yield})
+
+	if err != nil {
+		return e.truncate(b, start), err
+	}
+
+	b = append(b, ']')
+	return b, nil
+}
+
+func (e encoder) encodeSeq2(b []byte, p unsafe.Pointer, t reflect.Type, encodeKey, encodeValue encodeFunc) ([]byte, error) {
+	seq := reflect.NewAt(t, p).Elem()
+	if seq.IsNil() {
+		return append(b, "null"...), nil
+	}
+
+	start := len(b)
+	var err error
+	var n int
+	b = append(b, '{')
+
+	yield := reflect.MakeFunc(t.In(0), func(args []reflect.Value) []reflect.Value {
+		if err != nil {
+			return yieldFalse
+		}
+
+		if n != 0 {
+			b = append(b, ',')
+		}
+		n++
+
+		k, v := args[0], args[1]
+		if b, err = encodeKey(e, b, (*iface)(unsafe.Pointer(&k)).ptr); err != nil {
+			return yieldFalse
+		}
+
+		b = append(b, ':')
+
+		if b, err = encodeValue(e, b, (*iface)(unsafe.Pointer(&v)).ptr); err != nil {
+			return yieldFalse
+		}
+		if b, err = e.flush(b); err != nil {
+			return yieldFalse
+		}
+		return yieldTrue
+	})
+
+	seq.Call([]reflect.Value{yield})
+
+	if err != nil {
+		return e.truncate(b, start), err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (e encoder) encodeOrderedObject(b []byte, p unsafe.Pointer) ([]byte, error) {
+	obj := *(*OrderedObject)(p)
+	if obj == nil {
+		return e.encodeNilMap(b), nil
+	}
+
+	start := len(b)
+	var err error
+	b = append(b, '{')
+
+	for i := range obj {
+		if i != 0 {
+			b = append(b, ',')
+		}
+
+		b, _ = e.encodeString(b, unsafe.Pointer(&obj[i].Key))
+		b = append(b, ':')
+
+		b, err = e.appendAny(b, obj[i].Value)
+		if err == nil {
+			b, err = e.flushElement(b)
+		}
+		if err != nil {
+			return e.truncate(b, start), err
 		}
 	}
 
@@ -363,7 +805,7 @@ var mapslicePool = sync.Pool{
 func (e encoder) encodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, error) {
 	m := *(*map[string]any)(p)
 	if m == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	if (e.flags & SortMapKeys) == 0 {
@@ -383,7 +825,10 @@ func (e encoder) encodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 				b, _ = e.encodeString(b, unsafe.Pointer(&k))
 				b = append(b, ':')
 
-				b, err = Append(b, v, e.flags)
+				b, err = e.appendAny(b, v)
+				if err == nil {
+					b, err = e.flushElement(b)
+				}
 				if err != nil {
 					return b, err
 				}
@@ -417,7 +862,10 @@ func (e encoder) encodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 		b, _ = e.encodeString(b, unsafe.Pointer(&elem.key))
 		b = append(b, ':')
 
-		b, err = Append(b, elem.val, e.flags)
+		b, err = e.appendAny(b, elem.val)
+		if err == nil {
+			b, err = e.flushElement(b)
+		}
 		if err != nil {
 			break
 		}
@@ -431,7 +879,7 @@ func (e encoder) encodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 	mapslicePool.Put(s)
 
 	if err != nil {
-		return b[:start], err
+		return e.truncate(b, start), err
 	}
 
 	b = append(b, '}')
@@ -441,7 +889,7 @@ func (e encoder) encodeMapStringInterface(b []byte, p unsafe.Pointer) ([]byte, e
 func (e encoder) encodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte, error) {
 	m := *(*map[string]RawMessage)(p)
 	if m == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	if (e.flags & SortMapKeys) == 0 {
@@ -463,6 +911,9 @@ func (e encoder) encodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte,
 				b = append(b, ':')
 
 				b, err = e.encodeRawMessage(b, unsafe.Pointer(&v))
+				if err == nil {
+					b, err = e.flushElement(b)
+				}
 				if err != nil {
 					break
 				}
@@ -497,6 +948,9 @@ func (e encoder) encodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte,
 		b = append(b, ':')
 
 		b, err = e.encodeRawMessage(b, unsafe.Pointer(&elem.raw))
+		if err == nil {
+			b, err = e.flushElement(b)
+		}
 		if err != nil {
 			break
 		}
@@ -510,7 +964,7 @@ func (e encoder) encodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte,
 	mapslicePool.Put(s)
 
 	if err != nil {
-		return b[:start], err
+		return e.truncate(b, start), err
 	}
 
 	b = append(b, '}')
@@ -520,7 +974,7 @@ func (e encoder) encodeMapStringRawMessage(b []byte, p unsafe.Pointer) ([]byte,
 func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, error) {
 	m := *(*map[string]string)(p)
 	if m == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	if (e.flags & SortMapKeys) == 0 {
@@ -529,6 +983,7 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 		b = append(b, '{')
 
 		if len(m) != 0 {
+			var err error
 			i := 0
 
 			for k, v := range m {
@@ -541,6 +996,10 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 				b = append(b, ':')
 				b, _ = e.encodeString(b, unsafe.Pointer(&v))
 
+				if b, err = e.flushElement(b); err != nil {
+					return b, err
+				}
+
 				i++
 			}
 		}
@@ -559,6 +1018,8 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 	}
 	sort.Sort(s)
 
+	start := len(b)
+	var err error
 	b = append(b, '{')
 
 	for i, elem := range s.elements {
@@ -570,6 +1031,10 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 		b, _ = e.encodeString(b, unsafe.Pointer(&elem.key))
 		b = append(b, ':')
 		b, _ = e.encodeString(b, unsafe.Pointer(elem.val.(*string)))
+
+		if b, err = e.flushElement(b); err != nil {
+			break
+		}
 	}
 
 	for i := range s.elements {
@@ -579,6 +1044,10 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 	s.elements = s.elements[:0]
 	mapslicePool.Put(s)
 
+	if err != nil {
+		return e.truncate(b, start), err
+	}
+
 	b = append(b, '}')
 	return b, nil
 }
@@ -586,7 +1055,7 @@ func (e encoder) encodeMapStringString(b []byte, p unsafe.Pointer) ([]byte, erro
 func (e encoder) encodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte, error) {
 	m := *(*map[string][]string)(p)
 	if m == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	stringSize := unsafe.Sizeof("")
@@ -609,6 +1078,9 @@ func (e encoder) encodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 				b = append(b, ':')
 
 				b, err = e.encodeSlice(b, unsafe.Pointer(&v), stringSize, sliceStringType, encoder.encodeString)
+				if err == nil {
+					b, err = e.flushElement(b)
+				}
 				if err != nil {
 					return b, err
 				}
@@ -644,6 +1116,9 @@ func (e encoder) encodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 		b = append(b, ':')
 
 		b, err = e.encodeSlice(b, unsafe.Pointer(elem.val.(*[]string)), stringSize, sliceStringType, encoder.encodeString)
+		if err == nil {
+			b, err = e.flushElement(b)
+		}
 		if err != nil {
 			break
 		}
@@ -657,7 +1132,7 @@ func (e encoder) encodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 	mapslicePool.Put(s)
 
 	if err != nil {
-		return b[:start], err
+		return e.truncate(b, start), err
 	}
 
 	b = append(b, '}')
@@ -667,7 +1142,7 @@ func (e encoder) encodeMapStringStringSlice(b []byte, p unsafe.Pointer) ([]byte,
 func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error) {
 	m := *(*map[string]bool)(p)
 	if m == nil {
-		return append(b, "null"...), nil
+		return e.encodeNilMap(b), nil
 	}
 
 	if (e.flags & SortMapKeys) == 0 {
@@ -676,6 +1151,7 @@ func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 		b = append(b, '{')
 
 		if len(m) != 0 {
+			var err error
 			i := 0
 
 			for k, v := range m {
@@ -691,6 +1167,10 @@ func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 					b = append(b, ":false"...)
 				}
 
+				if b, err = e.flushElement(b); err != nil {
+					return b, err
+				}
+
 				i++
 			}
 		}
@@ -708,6 +1188,8 @@ func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 	}
 	sort.Sort(s)
 
+	start := len(b)
+	var err error
 	b = append(b, '{')
 
 	for i, elem := range s.elements {
@@ -722,6 +1204,10 @@ func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 		} else {
 			b = append(b, ":false"...)
 		}
+
+		if b, err = e.flushElement(b); err != nil {
+			break
+		}
 	}
 
 	for i := range s.elements {
@@ -731,6 +1217,10 @@ func (e encoder) encodeMapStringBool(b []byte, p unsafe.Pointer) ([]byte, error)
 	s.elements = s.elements[:0]
 	mapslicePool.Put(s)
 
+	if err != nil {
+		return e.truncate(b, start), err
+	}
+
 	b = append(b, '}')
 	return b, nil
 }
@@ -743,6 +1233,7 @@ func (e encoder) encodeStruct(b []byte, p unsafe.Pointer, st *structType) ([]byt
 	b = append(b, '{')
 
 	escapeHTML := (e.flags & EscapeHTML) != 0
+	escapeNonASCII := (e.flags & EscapeNonASCII) != 0
 
 	for i := range st.fields {
 		f := &st.fields[i]
@@ -752,9 +1243,14 @@ func (e encoder) encodeStruct(b []byte, p unsafe.Pointer, st *structType) ([]byt
 			continue
 		}
 
-		if escapeHTML {
+		switch {
+		case escapeNonASCII && escapeHTML:
+			k = f.asciiHTML
+		case escapeNonASCII:
+			k = f.ascii
+		case escapeHTML:
 			k = f.html
-		} else {
+		default:
 			k = f.json
 		}
 
@@ -771,7 +1267,11 @@ func (e encoder) encodeStruct(b []byte, p unsafe.Pointer, st *structType) ([]byt
 				b = b[:lengthBeforeKey]
 				continue
 			}
-			return b[:start], err
+			return e.truncate(b, start), err
+		}
+
+		if b, err = e.flushElement(b); err != nil {
+			return e.truncate(b, start), err
 		}
 
 		n++
@@ -813,11 +1313,99 @@ func (e encoder) encodePointer(b []byte, p unsafe.Pointer, t reflect.Type, encod
 }
 
 func (e encoder) encodeInterface(b []byte, p unsafe.Pointer) ([]byte, error) {
-	return Append(b, *(*any)(p), e.flags)
+	return e.appendAny(b, *(*any)(p))
 }
 
 func (e encoder) encodeMaybeEmptyInterface(b []byte, p unsafe.Pointer, t reflect.Type) ([]byte, error) {
-	return Append(b, reflect.NewAt(t, p).Elem().Interface(), e.flags)
+	return e.appendAny(b, reflect.NewAt(t, p).Elem().Interface())
+}
+
+// appendAny encodes x with the flags of e, the encoding of values held in
+// interfaces restarts the detection of pointer cycles, but still writes to the
+// output stream of e.
+func (e encoder) appendAny(b []byte, x any) ([]byte, error) {
+	return encoder{flags: e.flags, stream: e.stream}.append(b, x)
+}
+
+func (e encoder) encodeDiscriminated(b []byte, p unsafe.Pointer, disc *discriminator) ([]byte, error) {
+	x := reflect.NewAt(disc.iface, p).Elem()
+	if x.IsNil() {
+		return append(b, "null"...), nil
+	}
+
+	v := x.Elem()
+	name, ok := disc.names[v.Type()]
+	if !ok {
+		return b, &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	start := len(b)
+	b, err := Append(b, v.Interface(), e.flags)
+	if err != nil {
+		return b, err
+	}
+
+	switch {
+	case hasNullPrefix(b[start:]):
+		return b, nil
+	case b[start] != '{':
+		return e.truncate(b, start), &UnsupportedValueError{Value: v, Str: "discriminated value not encoded as a JSON object"}
+	}
+
+	if (e.flags & EscapeNonASCII) != 0 {
+		name = appendCompactEscape(nil, name, EscapeNonASCII)
+	}
+
+	// Shift the content of the object to insert the discriminator as its
+	// first field, followed by a comma unless the object was empty.
+	n := len(disc.key) + len(name)
+	if b[start+1] != '}' {
+		n++
+	}
+	b = slices.Grow(b, n)[:len(b)+n]
+	copy(b[start+1+n:], b[start+1:len(b)-n])
+	i := start + 1
+	i += copy(b[i:], disc.key)
+	i += copy(b[i:], name)
+	if i < start+1+n {
+		b[i] = ','
+	}
+	return b, nil
+}
+
+// encodeEnvelopePayload writes the value of a struct field tagged with the
+// "discriminator:" option, its discriminator is written by encodeEnvelopeKey.
+func (e encoder) encodeEnvelopePayload(b []byte, p unsafe.Pointer, disc *discriminator) ([]byte, error) {
+	x := reflect.NewAt(disc.iface, p).Elem()
+	if x.IsNil() {
+		return append(b, "null"...), nil
+	}
+
+	v := x.Elem()
+	if _, ok := disc.names[v.Type()]; !ok {
+		return b, &UnsupportedTypeError{Type: v.Type()}
+	}
+	return e.appendAny(b, v.Interface())
+}
+
+// encodeEnvelopeKey writes the discriminator of the envelope field at p as the
+// value of its sibling key, which is omitted when the field is nil.
+func (e encoder) encodeEnvelopeKey(b []byte, p unsafe.Pointer, disc *discriminator) ([]byte, error) {
+	x := reflect.NewAt(disc.iface, p).Elem()
+	if x.IsNil() {
+		return b, rollback{}
+	}
+
+	t := x.Elem().Type()
+	name, ok := disc.names[t]
+	if !ok {
+		return b, &UnsupportedTypeError{Type: t}
+	}
+
+	if (e.flags & EscapeNonASCII) != 0 {
+		return appendCompactEscape(b, name, EscapeNonASCII), nil
+	}
+	return append(b, name...), nil
 }
 
 func (e encoder) encodeUnsupportedTypeError(b []byte, p unsafe.Pointer, t reflect.Type) ([]byte, error) {
@@ -845,8 +1433,8 @@ func (e encoder) encodeRawMessage(b []byte, p unsafe.Pointer) ([]byte, error) {
 		}
 	}
 
-	if (e.flags & EscapeHTML) != 0 {
-		return appendCompactEscapeHTML(b, s), nil
+	if (e.flags & (EscapeHTML | EscapeNonASCII)) != 0 {
+		return appendCompactEscape(b, s, e.flags), nil
 	}
 
 	return append(b, s...), nil
@@ -877,13 +1465,45 @@ func (e encoder) encodeJSONMarshaler(b []byte, p unsafe.Pointer, t reflect.Type,
 		return b, &MarshalerError{Type: t, Err: err}
 	}
 
-	if (e.flags & EscapeHTML) != 0 {
-		return appendCompactEscapeHTML(b, s), nil
+	if (e.flags & (EscapeHTML | EscapeNonASCII)) != 0 {
+		return appendCompactEscape(b, s, e.flags), nil
 	}
 
 	return append(b, s...), nil
 }
 
+func (e encoder) encodeJSONMarshalerTo(b []byte, p unsafe.Pointer, t reflect.Type, pointer bool) ([]byte, error) {
+	v := reflect.NewAt(t, p)
+
+	// Values which are not pointers or interfaces are passed by address, the
+	// method set of their pointer type includes the methods of the value, and
+	// converting the pointer to an interface does not allocate.
+	if !pointer && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface) {
+		if v = v.Elem(); v.IsNil() {
+			return append(b, "null"...), nil
+		}
+	}
+
+	start := len(b)
+	b, err := v.Interface().(MarshalerTo).AppendJSON(b, e.flags&^appendNewline)
+	if err != nil {
+		return e.truncate(b, start), err
+	}
+
+	if (e.flags & TrustRawMessage) == 0 {
+		d := decoder{}
+		_, r, _, err := d.parseValue(b[start:])
+		if err == nil && len(skipSpaces(r)) != 0 {
+			err = syntaxError(r, "invalid character '%c' after top-level value", r[0])
+		}
+		if err != nil {
+			return e.truncate(b, start), &MarshalerError{Type: t, Err: err}
+		}
+	}
+
+	return b, nil
+}
+
 func (e encoder) encodeTextMarshaler(b []byte, p unsafe.Pointer, t reflect.Type, pointer bool) ([]byte, error) {
 	v := reflect.NewAt(t, p)
 
@@ -906,12 +1526,19 @@ func (e encoder) encodeTextMarshaler(b []byte, p unsafe.Pointer, t reflect.Type,
 	return e.encodeString(b, unsafe.Pointer(&s))
 }
 
-func appendCompactEscapeHTML(dst []byte, src []byte) []byte {
+// appendCompactEscape appends the json value src to dst without insignificant
+// whitespace, escaping the characters of strings required by the EscapeHTML
+// and EscapeNonASCII flags.
+func appendCompactEscape(dst []byte, src []byte, flags AppendFlags) []byte {
 	start := 0
 	escape := false
 	inString := false
+	escapeHTML := (flags & EscapeHTML) != 0
+	escapeNonASCII := (flags & EscapeNonASCII) != 0
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
 
-	for i, c := range src {
 		if !inString {
 			switch c {
 			case '"': // enter string
@@ -940,7 +1567,7 @@ func appendCompactEscapeHTML(dst []byte, src []byte) []byte {
 			continue
 		}
 
-		if c == '<' || c == '>' || c == '&' {
+		if escapeHTML && (c == '<' || c == '>' || c == '&') {
 			if start < i {
 				dst = append(dst, src[start:i]...)
 			}
@@ -950,8 +1577,23 @@ func appendCompactEscapeHTML(dst []byte, src []byte) []byte {
 			continue
 		}
 
+		if escapeNonASCII && c >= utf8.RuneSelf {
+			if start < i {
+				dst = append(dst, src[start:i]...)
+			}
+			r, size := utf8.DecodeRune(src[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, `\ufffd`...)
+			} else {
+				dst = appendEscapedRune(dst, r)
+			}
+			i += size - 1
+			start = i + 1
+			continue
+		}
+
 		// Convert U+2028 and U+2029 (E2 80 A8 and E2 80 A9).
-		if c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
+		if escapeHTML && c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
 			if start < i {
 				dst = append(dst, src[start:i]...)
 			}
@@ -0,0 +1,448 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+// ArrayStyle configures how Flatten and Unflatten represent the elements of
+// arrays in flattened keys.
+type ArrayStyle int
+
+const (
+	// IndexWithSeparator represents array elements by their index, joined to
+	// the key of the array with the separator, for example "a.0".
+	//
+	// Unflatten converts objects back to arrays when all their keys are the
+	// indexes 0 to n-1, so objects with such keys do not survive a round trip.
+	IndexWithSeparator ArrayStyle = iota
+
+	// IndexWithBrackets represents array elements by their index in brackets,
+	// appended to the key of the array, for example "a[0]".
+	IndexWithBrackets
+
+	// ArraysAsValues leaves arrays as values of the flattened objects.
+	ArraysAsValues
+)
+
+// FlattenOptions configures the behavior of Flatten and Unflatten, the zero
+// value is a valid configuration which uses "." as separator, represents array
+// indexes with the separator, and has no maximum depth.
+type FlattenOptions struct {
+	// The separator placed between the keys of nested values, "." is used
+	// when empty.
+	Separator string
+
+	// The representation of array elements in the flattened keys.
+	Arrays ArrayStyle
+
+	// The maximum number of keys joined in a flattened key, objects and arrays
+	// nested deeper are left as values of the flattened object. There is no
+	// maximum when zero.
+	MaxDepth int
+
+	// Flags used to escape the keys and values written to the output.
+	Flags AppendFlags
+}
+
+func (opts *FlattenOptions) separator() string {
+	if opts.Separator == "" {
+		return "."
+	}
+	return opts.Separator
+}
+
+// Flatten is a convenience helper to flatten the json object src, for more
+// control over the output buffer, use AppendFlatten.
+func Flatten(src []byte, opts FlattenOptions) ([]byte, error) {
+	return AppendFlatten(make([]byte, 0, len(src)), src, opts)
+}
+
+// AppendFlatten appends to b a flattened version of the json object src, where
+// the values of nested objects and arrays are moved to the top-level object,
+// with keys joining the keys of the enclosing objects. For example:
+//
+//	{"a":{"b":1,"c":[true,false]}}
+//
+// is flattened to:
+//
+//	{"a.b":1,"a.c.0":true,"a.c.1":false}
+//
+// Empty objects and arrays are kept as values, so that Unflatten restores
+// them. The output is compact, and the members are written in the order they
+// appear in src.
+//
+// The input is transformed as it is tokenized, values are only copied to the
+// output. An error is returned if src is not a valid json object, in which
+// case b is returned unchanged.
+func AppendFlatten(b, src []byte, opts FlattenOptions) ([]byte, error) {
+	src, err := parseDocument(src)
+	if err != nil {
+		return b, err
+	}
+	if src[0] != '{' {
+		return b, fmt.Errorf("json: cannot flatten %q, expected an object", prefix(src))
+	}
+
+	f := flattener{
+		opts:      &opts,
+		separator: opts.separator(),
+		output:    append(b, '{'),
+	}
+
+	t := tokenizerPool.Get().(*Tokenizer)
+	t.Reset(src)
+	t.Next() // opening delimiter of src
+	f.flatten(t)
+	t.Reset(nil)
+	tokenizerPool.Put(t)
+
+	return append(f.output, '}'), nil
+}
+
+type flattener struct {
+	opts      *FlattenOptions
+	separator string
+	output    []byte
+	// The unescaped flattened key of the value being written.
+	path   []byte
+	scopes []flattenScope
+	count  int
+}
+
+// flattenScope is an object or array being flattened.
+type flattenScope struct {
+	// The length of the path to the object or array.
+	prefix int
+	// The number of values found in the object or array.
+	count int
+	array bool
+	// Set when the next string is a key of the object.
+	expectKey bool
+}
+
+func (f *flattener) flatten(t *Tokenizer) {
+	f.scopes = append(f.scopes, flattenScope{expectKey: true})
+
+	for t.Next() {
+		top := &f.scopes[len(f.scopes)-1]
+
+		switch {
+		case t.Delim == ':':
+
+		case t.Delim == ',':
+			top.expectKey = !top.array
+
+		case t.Delim == '}' || t.Delim == ']':
+			if len(f.scopes) == 1 {
+				return
+			}
+			if top.count == 0 {
+				f.write(t.Value)
+			}
+			f.scopes = f.scopes[:len(f.scopes)-1]
+
+		case top.expectKey:
+			top.expectKey = false
+			f.path = f.appendKey(f.path[:top.prefix], t.String())
+
+		default:
+			if top.array {
+				f.path = f.appendIndex(f.path[:top.prefix], top.count)
+			}
+			top.count++
+
+			switch {
+			case t.Delim == '{' && f.descend():
+				f.scopes = append(f.scopes, flattenScope{prefix: len(f.path), expectKey: true})
+			case t.Delim == '[' && f.descend() && f.opts.Arrays != ArraysAsValues:
+				f.scopes = append(f.scopes, flattenScope{prefix: len(f.path), array: true})
+			default:
+				f.write(t.Capture())
+			}
+		}
+	}
+}
+
+// descend returns true if the values of the object or array at the current
+// depth must be flattened.
+func (f *flattener) descend() bool {
+	return f.opts.MaxDepth <= 0 || len(f.scopes) < f.opts.MaxDepth
+}
+
+// write writes a member with the current path and value v to the output. When
+// v is a closing delimiter, the member is the empty object or array that it
+// terminates.
+func (f *flattener) write(v []byte) {
+	if f.count++; f.count > 1 {
+		f.output = append(f.output, ',')
+	}
+	f.output = AppendEscape(f.output, *(*string)(unsafe.Pointer(&f.path)), f.opts.Flags)
+	f.output = append(f.output, ':')
+
+	switch v[0] {
+	case '}':
+		f.output = append(f.output, '{', '}')
+	case ']':
+		f.output = append(f.output, '[', ']')
+	default:
+		f.output = appendCompactEscape(f.output, v, f.opts.Flags)
+	}
+}
+
+func (f *flattener) appendKey(path, key []byte) []byte {
+	if len(f.scopes) > 1 {
+		path = append(path, f.separator...)
+	}
+	return append(path, key...)
+}
+
+func (f *flattener) appendIndex(path []byte, i int) []byte {
+	if f.opts.Arrays == IndexWithBrackets {
+		return append(strconv.AppendInt(append(path, '['), int64(i), 10), ']')
+	}
+	return strconv.AppendInt(append(path, f.separator...), int64(i), 10)
+}
+
+// Unflatten is a convenience helper to unflatten the json object src, for more
+// control over the output buffer, use AppendUnflatten.
+func Unflatten(src []byte, opts FlattenOptions) ([]byte, error) {
+	return AppendUnflatten(make([]byte, 0, len(src)), src, opts)
+}
+
+// AppendUnflatten appends to b the json object src with its flattened keys
+// expanded to nested objects and arrays, reversing the transformation applied
+// by AppendFlatten with the same options. For example:
+//
+//	{"a.b":1,"a.c.0":true,"a.c.1":false}
+//
+// is unflattened to:
+//
+//	{"a":{"b":1,"c":[true,false]}}
+//
+// Keys are split on the separator, and when MaxDepth is set, the remainder of
+// keys with more parts is used as the key of the deepest object. Arrays are
+// restored from keys with indexes 0 to n-1, missing indexes are reported as
+// errors when using the IndexWithBrackets style. The members of objects are
+// written in the order that their first key appears in src, and when a key is
+// repeated, the last value is the one written to the output.
+//
+// Unlike AppendFlatten, this is not a streaming transformation: the members of
+// src are all read before writing the output since the values of nested
+// objects may be spread across src. An error is returned if src is not a valid
+// json object, if a key refers to the inside of a value assigned by another key
+// (for example "a" and "a.b"), or if array indexes are missing, in which case b
+// is returned unchanged.
+func AppendUnflatten(b, src []byte, opts FlattenOptions) ([]byte, error) {
+	src, err := parseDocument(src)
+	if err != nil {
+		return b, err
+	}
+	if src[0] != '{' {
+		return b, fmt.Errorf("json: cannot unflatten %q, expected an object", prefix(src))
+	}
+
+	u := unflattener{
+		opts:      &opts,
+		separator: []byte(opts.separator()),
+	}
+	root := &unflattenNode{}
+
+	for _, m := range appendMembers(nil, src) {
+		if err := u.insert(root, m.key, m.value); err != nil {
+			return b, err
+		}
+	}
+
+	// Missing array indexes are only detected while writing the output, the
+	// partial output must be discarded.
+	start := len(b)
+	b, err = u.write(b, root)
+	if err != nil {
+		return b[:start], err
+	}
+	return b, nil
+}
+
+type unflattener struct {
+	opts      *FlattenOptions
+	separator []byte
+	segments  []unflattenSegment
+}
+
+// unflattenSegment is a part of a flattened key, either the key of an object
+// member or the index of an array element.
+type unflattenSegment struct {
+	key []byte
+	// The position of the segment in the flattened key.
+	offset int
+	index  bool
+}
+
+// unflattenNode is a value of the unflattened object. Leaves hold the raw json
+// value found in the input, other nodes are objects or arrays holding their
+// members in the order that they were inserted.
+type unflattenNode struct {
+	value   []byte
+	members []unflattenMember
+	lookup  map[string]int
+	// Set when the members were inserted from indexes in brackets.
+	indexed bool
+}
+
+type unflattenMember struct {
+	key  string
+	node *unflattenNode
+}
+
+func (u *unflattener) insert(root *unflattenNode, key, value []byte) error {
+	u.segments = u.split(u.segments[:0], key)
+	n := root
+
+	for i, s := range u.segments {
+		if n.value != nil || (len(n.members) != 0 && n.indexed != s.index) {
+			return fmt.Errorf("json: cannot unflatten %q, the key conflicts with a previous key", key)
+		}
+		n.indexed = s.index
+
+		if n.lookup == nil {
+			n.lookup = make(map[string]int)
+		}
+		j, ok := n.lookup[string(s.key)]
+		if !ok {
+			j = len(n.members)
+			n.lookup[string(s.key)] = j
+			n.members = append(n.members, unflattenMember{key: string(s.key), node: &unflattenNode{}})
+		}
+		n = n.members[j].node
+
+		if i == len(u.segments)-1 && len(n.members) != 0 {
+			return fmt.Errorf("json: cannot unflatten %q, the key conflicts with a previous key", key)
+		}
+	}
+
+	n.value = value
+	return nil
+}
+
+// split appends the segments of the flattened key to the empty slice s. When the maximum depth
+// is exceeded, the rest of the key is used as the last segment.
+func (u *unflattener) split(s []unflattenSegment, key []byte) []unflattenSegment {
+	for i := 0; ; {
+		part, _, found := bytes.Cut(key[i:], u.separator)
+		if u.opts.Arrays == IndexWithBrackets {
+			s = splitBrackets(s, key, i, i+len(part))
+		} else {
+			s = append(s, unflattenSegment{key: part, offset: i})
+		}
+		if !found {
+			break
+		}
+		i += len(part) + len(u.separator)
+	}
+
+	if n := u.opts.MaxDepth; n > 0 && len(s) > n {
+		s[n-1] = unflattenSegment{key: key[s[n-1].offset:]}
+		s = s[:n]
+	}
+	return s
+}
+
+// splitBrackets appends the segments of key[i:j], a key followed by any number
+// of array indexes in brackets, to s.
+func splitBrackets(s []unflattenSegment, key []byte, i, j int) []unflattenSegment {
+	n := j
+	for n != i && key[n-1] == ']' {
+		k := bytes.LastIndexByte(key[i:n], '[')
+		if k < 0 || !isIndex(key[i+k+1:n-1]) {
+			break
+		}
+		n = i + k
+	}
+
+	s = append(s, unflattenSegment{key: key[i:n], offset: i})
+
+	for n < j {
+		k := n + bytes.IndexByte(key[n:j], ']')
+		s = append(s, unflattenSegment{key: key[n+1 : k], offset: n, index: true})
+		n = k + 1
+	}
+
+	return s
+}
+
+func (u *unflattener) write(b []byte, n *unflattenNode) ([]byte, error) {
+	if n.value != nil {
+		return appendCompactEscape(b, n.value, u.opts.Flags), nil
+	}
+
+	if elems, ok := u.elements(n); ok {
+		var err error
+		b = append(b, '[')
+		for i, e := range elems {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			if b, err = u.write(b, e); err != nil {
+				return b, err
+			}
+		}
+		return append(b, ']'), nil
+	}
+
+	if n.indexed {
+		return b, errors.New("json: cannot unflatten array with missing indexes")
+	}
+
+	var err error
+	b = append(b, '{')
+	for i, m := range n.members {
+		if i != 0 {
+			b = append(b, ',')
+		}
+		b = AppendEscape(b, m.key, u.opts.Flags)
+		b = append(b, ':')
+		if b, err = u.write(b, m.node); err != nil {
+			return b, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// elements returns the elements of n ordered by index if n is an array, which
+// is the case when its members are keyed by all the indexes from 0 to n-1.
+func (u *unflattener) elements(n *unflattenNode) ([]*unflattenNode, bool) {
+	if len(n.members) == 0 || (!n.indexed && u.opts.Arrays != IndexWithSeparator) {
+		return nil, false
+	}
+
+	elems := make([]*unflattenNode, len(n.members))
+	for _, m := range n.members {
+		if !isIndex([]byte(m.key)) {
+			return nil, false
+		}
+		i, err := strconv.Atoi(m.key)
+		if err != nil || i >= len(elems) {
+			return nil, false
+		}
+		elems[i] = m.node
+	}
+	return elems, true
+}
+
+// isIndex returns true if b is the decimal representation of an array index,
+// without leading zeros.
+func isIndex(b []byte) bool {
+	if len(b) == 0 || (b[0] == '0' && len(b) > 1) {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
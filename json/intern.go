@@ -0,0 +1,69 @@
+package json
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync/atomic"
+)
+
+// StringTable is a bounded table of strings used to deduplicate the strings
+// allocated when decoding json input, see the InternStrings parsing flag.
+//
+// The table behaves like a cache: each string is stored in a slot selected by
+// its hash, replacing the string which previously occupied the slot. The
+// memory retained by a table is therefore bounded by its size and the maximum
+// length of strings that it interns, while frequently repeated strings like
+// object keys tend to remain in the table.
+//
+// StringTable values are safe to use concurrently from multiple goroutines.
+type StringTable struct {
+	slots  []atomic.Pointer[string]
+	maxLen int
+	seed   maphash.Seed
+}
+
+// NewStringTable constructs a table with size slots, rounded up to a power of
+// two, which interns strings of up to maxLen bytes.
+func NewStringTable(size, maxLen int) *StringTable {
+	if size < 1 {
+		size = 1
+	}
+	return &StringTable{
+		slots:  make([]atomic.Pointer[string], 1<<bits.Len(uint(size-1))),
+		maxLen: maxLen,
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+// Intern returns a string equal to b, which is shared with previous calls to
+// Intern when the string is found in the table. Strings longer than the
+// maximum length of the table are always allocated.
+func (t *StringTable) Intern(b []byte) string {
+	if len(b) > t.maxLen {
+		return string(b)
+	}
+	slot := &t.slots[maphash.Bytes(t.seed, b)&uint64(len(t.slots)-1)]
+	if s := slot.Load(); s != nil && *s == string(b) {
+		return *s
+	}
+	s := string(b)
+	slot.Store(&s)
+	return s
+}
+
+// sharedStringTable is the table used when the InternStrings flag is set and
+// no table was configured on the Decoder.
+var sharedStringTable = NewStringTable(4096, 64)
+
+// stringTable returns the table used to intern decoded strings, or nil if the
+// InternStrings flag is not set.
+func (d decoder) stringTable() *StringTable {
+	switch {
+	case (d.flags & InternStrings) == 0:
+		return nil
+	case d.strings != nil:
+		return d.strings
+	default:
+		return sharedStringTable
+	}
+}
@@ -0,0 +1,173 @@
+// Package jsonopts implements the options shared by the json/v2 and jsontext
+// packages.
+//
+// The Options interface can only be implemented in this package, the public
+// packages re-export the constructors of the options that apply to them.
+package jsonopts
+
+import (
+	"io"
+
+	"github.com/segmentio/encoding/json"
+)
+
+// Options is the interface implemented by all options.
+type Options interface {
+	// JSONOptions is a marker method, its argument type cannot be named
+	// outside of this package.
+	JSONOptions(internal)
+}
+
+type internal struct{}
+
+// Flags is a bit set of boolean options.
+type Flags uint64
+
+const (
+	// Options of the jsontext package.
+	EscapeForHTML Flags = 1 << iota
+	Multiline
+	Indent
+	IndentPrefix
+	AllowDuplicateNames
+
+	// Options of the json/v2 package.
+	Deterministic
+	FormatNilMapAsNull
+	FormatNilSliceAsNull
+	MatchCaseInsensitiveNames
+	RejectUnknownMembers
+)
+
+// Struct is the representation of a set of options merged together.
+type Struct struct {
+	// Flags records which options were set.
+	Flags Flags
+	// Values holds the values of boolean options set in Flags.
+	Values Flags
+
+	Indent       string
+	IndentPrefix string
+}
+
+func (*Struct) JSONOptions(internal) {}
+
+// Bool is the option setting a boolean flag.
+type Bool struct {
+	Flag  Flags
+	Value bool
+}
+
+func (Bool) JSONOptions(internal) {}
+
+// String is the option setting a string value, identified by a flag.
+type String struct {
+	Flag  Flags
+	Value string
+}
+
+func (String) JSONOptions(internal) {}
+
+// Join merges opts into s, later options take precedence over earlier ones.
+func (s *Struct) Join(opts ...Options) {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case nil:
+		case Bool:
+			s.Flags |= opt.Flag
+			if opt.Value {
+				s.Values |= opt.Flag
+			} else {
+				s.Values &^= opt.Flag
+			}
+		case String:
+			s.Flags |= opt.Flag
+			switch opt.Flag {
+			case Indent:
+				s.Indent = opt.Value
+			case IndentPrefix:
+				s.IndentPrefix = opt.Value
+			}
+		case *Struct:
+			s.Flags |= opt.Flags
+			s.Values = (s.Values &^ opt.Flags) | (opt.Values & opt.Flags)
+			if (opt.Flags & Indent) != 0 {
+				s.Indent = opt.Indent
+			}
+			if (opt.Flags & IndentPrefix) != 0 {
+				s.IndentPrefix = opt.IndentPrefix
+			}
+		}
+	}
+}
+
+// Get returns the value of the boolean option f, and whether it was set.
+func (s *Struct) Get(f Flags) (value, ok bool) {
+	return (s.Values & f) != 0, (s.Flags & f) != 0
+}
+
+// IsSet returns true if the boolean option f was set to true.
+func (s *Struct) IsSet(f Flags) bool {
+	return (s.Values & f) != 0
+}
+
+// Multiline returns true if the output must be indented.
+func (s *Struct) Multiline() bool {
+	return s.IsSet(Multiline) || (s.Flags&(Indent|IndentPrefix)) != 0
+}
+
+// AppendFlags returns the flags of the json package matching the options.
+func (s *Struct) AppendFlags() json.AppendFlags {
+	var flags json.AppendFlags
+	if s.IsSet(EscapeForHTML) {
+		flags |= json.EscapeHTML
+	}
+	if s.IsSet(Deterministic) {
+		flags |= json.SortMapKeys
+	}
+	if !s.IsSet(FormatNilMapAsNull) {
+		flags |= json.NilMapsAsEmpty
+	}
+	if !s.IsSet(FormatNilSliceAsNull) {
+		flags |= json.NilSlicesAsEmpty
+	}
+	return flags
+}
+
+// Encoder returns an encoder of the json package writing to w, configured with
+// the flags returned by AppendFlags. The encoder does not append newlines or
+// indent the output.
+func (s *Struct) Encoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetAppendNewline(false)
+	enc.SetEscapeHTML(s.IsSet(EscapeForHTML))
+	enc.SetSortMapKeys(s.IsSet(Deterministic))
+	enc.SetNilMapsAsEmpty(!s.IsSet(FormatNilMapAsNull))
+	enc.SetNilSlicesAsEmpty(!s.IsSet(FormatNilSliceAsNull))
+	return enc
+}
+
+// ParseFlags returns the flags of the json package matching the options.
+func (s *Struct) ParseFlags() json.ParseFlags {
+	var flags json.ParseFlags
+	if s.IsSet(RejectUnknownMembers) {
+		flags |= json.DisallowUnknownFields
+	}
+	if !s.IsSet(MatchCaseInsensitiveNames) {
+		flags |= json.DontMatchCaseInsensitiveStructFields
+	}
+	if !s.IsSet(AllowDuplicateNames) {
+		flags |= json.RejectDuplicateNames
+	}
+	return flags
+}
+
+// IndentStrings returns the prefix and indentation to use when the output is
+// multiline; the indentation defaults to a tab.
+func (s *Struct) IndentStrings() (prefix, indent string) {
+	prefix, indent = s.IndentPrefix, s.Indent
+	if (s.Flags & Indent) == 0 {
+		indent = "\t"
+	}
+	return prefix, indent
+}
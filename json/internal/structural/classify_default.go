@@ -0,0 +1,7 @@
+//go:build purego || !amd64
+
+package structural
+
+func classify(blocks []Block, src []byte) {
+	classifyGeneric(blocks, src)
+}
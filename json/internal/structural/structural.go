@@ -0,0 +1,237 @@
+// Package structural implements the first stage of the parsing technique
+// described in "Parsing Gigabytes of JSON per Second" (Langdale & Lemire): it
+// classifies blocks of 64 bytes of JSON input into bitmasks, then combines the
+// bitmasks to locate the structural characters of the input without having to
+// inspect it byte by byte.
+//
+// The classification uses AVX2 instructions when they are available, with a
+// portable fallback for other platforms and the purego build tag. The AVX2
+// kernel is generated with avo by the program in build/structural, run with
+// make build-asm.
+package structural
+
+import "math/bits"
+
+// BlockSize is the number of bytes described by a Block.
+const BlockSize = 64
+
+// Block holds the bitmasks describing a block of 64 bytes of input, where bit i
+// of each mask corresponds to the byte at index i in the block.
+type Block struct {
+	// Quotes has the bits of '"' bytes set.
+	Quotes uint64
+	// Backslashes has the bits of '\\' bytes set.
+	Backslashes uint64
+	// Operators has the bits of '{', '}', '[', ']', ':', and ',' bytes set.
+	Operators uint64
+	// Controls has the bits of bytes lower than 0x20 set.
+	Controls uint64
+}
+
+// Classify fills blocks with the bitmasks of consecutive blocks of 64 bytes of
+// src. It returns the number of blocks it filled, which is the smallest of
+// len(blocks) and len(src)/64; trailing bytes which do not fill a whole block
+// are not classified.
+func Classify(blocks []Block, src []byte) int {
+	n := min(len(blocks), len(src)/BlockSize)
+	if n != 0 {
+		classify(blocks[:n], src[:n*BlockSize])
+	}
+	return n
+}
+
+const (
+	classQuote = 1 << iota
+	classBackslash
+	classOperator
+	classControl
+)
+
+var classes = [256]uint8{
+	'"':  classQuote,
+	'\\': classBackslash,
+	'{':  classOperator,
+	'}':  classOperator,
+	'[':  classOperator,
+	']':  classOperator,
+	':':  classOperator,
+	',':  classOperator,
+	0x00: classControl, 0x01: classControl, 0x02: classControl, 0x03: classControl,
+	0x04: classControl, 0x05: classControl, 0x06: classControl, 0x07: classControl,
+	0x08: classControl, 0x09: classControl, 0x0A: classControl, 0x0B: classControl,
+	0x0C: classControl, 0x0D: classControl, 0x0E: classControl, 0x0F: classControl,
+	0x10: classControl, 0x11: classControl, 0x12: classControl, 0x13: classControl,
+	0x14: classControl, 0x15: classControl, 0x16: classControl, 0x17: classControl,
+	0x18: classControl, 0x19: classControl, 0x1A: classControl, 0x1B: classControl,
+	0x1C: classControl, 0x1D: classControl, 0x1E: classControl, 0x1F: classControl,
+}
+
+func classifyGeneric(blocks []Block, src []byte) {
+	for i := range blocks {
+		var quotes, backslashes, operators, controls uint64
+
+		for j, c := range src[i*BlockSize : (i+1)*BlockSize] {
+			k := uint64(classes[c])
+			quotes |= (k & 1) << j
+			backslashes |= (k >> 1 & 1) << j
+			operators |= (k >> 2 & 1) << j
+			controls |= (k >> 3) << j
+		}
+
+		blocks[i] = Block{
+			Quotes:      quotes,
+			Backslashes: backslashes,
+			Operators:   operators,
+			Controls:    controls,
+		}
+	}
+}
+
+// Indexer produces the offsets of the structural characters of a JSON input,
+// which are the unescaped quotes delimiting strings, and the operators found
+// outside of strings.
+//
+// The input is classified incrementally as the offsets are consumed, so the
+// cost of using an Indexer is proportional to the length of the input which
+// was actually indexed.
+type Indexer struct {
+	src []byte
+	// Offset in src of the next block to classify.
+	next int
+	// Classified blocks which were not consumed yet.
+	blocks [16]Block
+	head   int
+	tail   int
+	// Structural bits of the current block, and its offset in src.
+	bits uint64
+	base int
+	// State carried from one block to the next.
+	escaped  uint64
+	inString uint64
+	// Set if a control character was found in a string.
+	invalid bool
+}
+
+// Reset sets src as the input of the indexer.
+func (x *Indexer) Reset(src []byte) {
+	*x = Indexer{src: src, base: -BlockSize}
+}
+
+// Next returns the offset of the next structural character of the input, or -1
+// if there are no more.
+//
+// Indexing stops when a control character is found in a string, which makes
+// the input invalid, see Invalid.
+func (x *Indexer) Next() int {
+	if x.bits == 0 && !x.advance() {
+		return -1
+	}
+	m := x.bits
+	x.bits = m & (m - 1)
+	return x.base + bits.TrailingZeros64(m)
+}
+
+// Invalid returns true if a control character was found in a string of the
+// input indexed so far.
+func (x *Indexer) Invalid() bool {
+	return x.invalid
+}
+
+// advance moves to the next block which has structural characters, it is not
+// inlined to keep Next within the inlining budget.
+//
+//go:noinline
+func (x *Indexer) advance() bool {
+	for !x.invalid {
+		if x.head == x.tail && !x.fill() {
+			break
+		}
+
+		b := &x.blocks[x.head]
+		x.head++
+		x.base += BlockSize
+
+		escaped := x.findEscaped(b.Backslashes)
+		quotes := b.Quotes &^ escaped
+		inString := prefixXor(quotes) ^ x.inString
+		x.inString = uint64(int64(inString) >> 63)
+
+		if (b.Controls & inString) != 0 {
+			x.invalid = true
+			break
+		}
+
+		if x.bits = quotes | (b.Operators &^ inString); x.bits != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *Indexer) fill() bool {
+	src := x.src[x.next:]
+	if len(src) == 0 {
+		return false
+	}
+
+	// Start with a single block since the input is often used to skip short
+	// values, then classify more blocks at once to amortize the cost of
+	// calling Classify as the value grows.
+	blocks := x.blocks[:max(1, min(4*x.tail, len(x.blocks)))]
+
+	n := Classify(blocks, src)
+	if n == 0 {
+		// The last bytes are padded with spaces to form a whole block.
+		var tail [BlockSize]byte
+		for i := copy(tail[:], src); i < len(tail); i++ {
+			tail[i] = ' '
+		}
+		n = Classify(blocks, tail[:])
+	}
+
+	x.next = min(x.next+n*BlockSize, len(x.src))
+	x.head, x.tail = 0, n
+	return true
+}
+
+// findEscaped returns the mask of the characters escaped by backslashes, taking
+// into account the escape sequences which started in the previous block.
+func (x *Indexer) findEscaped(backslashes uint64) uint64 {
+	const evenBits = 0x5555555555555555
+
+	backslashes &^= x.escaped
+	followsEscape := backslashes<<1 | x.escaped
+	oddStarts := backslashes &^ evenBits &^ followsEscape
+
+	evenStarts, carry := bits.Add64(oddStarts, backslashes, 0)
+	x.escaped = carry
+
+	return (evenBits ^ evenStarts<<1) & followsEscape
+}
+
+// prefixXor returns the mask where each bit is the XOR of all the bits of m at
+// the same or lower positions, which turns a mask of quotes into the mask of
+// bytes in strings.
+func prefixXor(m uint64) uint64 {
+	m ^= m << 1
+	m ^= m << 2
+	m ^= m << 4
+	m ^= m << 8
+	m ^= m << 16
+	m ^= m << 32
+	return m
+}
+
+// Index appends the offsets of the structural characters of src to dst and
+// returns the extended slice, and false if a control character was found in a
+// string (the offsets found until then are still appended).
+func Index(dst []uint32, src []byte) ([]uint32, bool) {
+	var x Indexer
+	x.Reset(src)
+
+	for i := x.Next(); i >= 0; i = x.Next() {
+		dst = append(dst, uint32(i))
+	}
+
+	return dst, !x.invalid
+}
@@ -0,0 +1,18 @@
+//go:build !purego
+
+package structural
+
+import (
+	"github.com/segmentio/asm/cpu"
+	"github.com/segmentio/asm/cpu/x86"
+)
+
+var hasAVX2 = cpu.X86.Has(x86.AVX2)
+
+func classify(blocks []Block, src []byte) {
+	if hasAVX2 {
+		classifyAVX2(&blocks[0], &src[0], len(blocks))
+	} else {
+		classifyGeneric(blocks, src)
+	}
+}
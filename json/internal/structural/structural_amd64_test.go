@@ -0,0 +1,44 @@
+//go:build !purego
+
+package structural
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexAVX2(t *testing.T) {
+	if !hasAVX2 {
+		t.Skip("AVX2 is not supported")
+	}
+
+	tests := []string{
+		`{"a":[1,2,{"b":null}],"c":"d"}`,
+		strings.Repeat(`{"key":"value with \"escaped\" quotes","list":[1,2,3]},`, 20),
+		strings.Repeat(" ", 60) + `"` + strings.Repeat(`\`, 71) + `"",`,
+		`["` + strings.Repeat("x", 200) + `",` + "\t" + `"` + "\x01" + `"]`,
+	}
+
+	prng := rand.New(rand.NewSource(1))
+	for range 1000 {
+		tests = append(tests, string(randomInput(prng, prng.Intn(16*BlockSize))))
+	}
+
+	defer func() { hasAVX2 = true }()
+
+	for _, test := range tests {
+		hasAVX2 = false
+		want, wantOK := Index(nil, []byte(test))
+		hasAVX2 = true
+		got, gotOK := Index(nil, []byte(test))
+
+		if gotOK != wantOK {
+			t.Errorf("%q: validity mismatch: want %t, got %t", test, wantOK, gotOK)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: offsets mismatch\nwant: %v\ngot:  %v", test, want, got)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package structural
+
+import (
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// index is a reference implementation of Index which scans the input byte by
+// byte. Like the indexer, it considers that backslashes escape quotes even
+// outside of strings, which only makes a difference on invalid inputs.
+func index(src []byte) ([]uint32, bool) {
+	var offsets []uint32
+	inString := false
+
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '"':
+			offsets = append(offsets, uint32(i))
+			inString = !inString
+		case c == '\\':
+			if i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\\') {
+				i++
+			}
+		case inString && c < 0x20:
+			return offsets, false
+		case !inString && strings.IndexByte("{}[]:,", c) >= 0:
+			offsets = append(offsets, uint32(i))
+		}
+	}
+
+	return offsets, true
+}
+
+func randomInput(prng *rand.Rand, n int) []byte {
+	const alphabet = `"\{}[]:, a1` + "\n"
+	b := make([]byte, n)
+	for i := range b {
+		if prng.Intn(8) == 0 {
+			b[i] = byte(prng.Intn(256))
+		} else {
+			b[i] = alphabet[prng.Intn(len(alphabet))]
+		}
+	}
+	return b
+}
+
+func TestClassify(t *testing.T) {
+	prng := rand.New(rand.NewSource(0))
+
+	for n := range 10 {
+		src := randomInput(prng, n*BlockSize+prng.Intn(BlockSize))
+		want := make([]Block, n)
+		got := make([]Block, n+1)
+
+		classifyGeneric(want, src)
+
+		if k := Classify(got, src); k != n {
+			t.Fatalf("classified %d blocks of %d bytes", k, len(src))
+		}
+		if !reflect.DeepEqual(got[:n], want) {
+			t.Errorf("blocks mismatch\nwant: %+v\ngot:  %+v", want, got[:n])
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []string{
+		``,
+		`{}`,
+		`{"a":[1,2,{"b":null}],"c":"d"}`,
+		`["\"",",","\\",":","\\\"[]"]`,
+		strings.Repeat(" ", 63) + `"\` + `"` + `"`,
+		strings.Repeat(" ", 62) + `"\\` + `"` + `,`,
+		strings.Repeat(" ", 60) + `"` + strings.Repeat(`\`, 71) + `"",`,
+		strings.Repeat(" ", 60) + `"` + strings.Repeat(`\`, 70) + `",`,
+		`"unterminated`,
+		`["control` + "\n" + `"]`,
+		`["` + strings.Repeat("x", 200) + `",` + "\t" + `"` + "\x01" + `"]`,
+	}
+
+	prng := rand.New(rand.NewSource(0))
+	for range 1000 {
+		tests = append(tests, string(randomInput(prng, prng.Intn(4*BlockSize))))
+	}
+
+	for _, test := range tests {
+		want, wantOK := index([]byte(test))
+		got, gotOK := Index(nil, []byte(test))
+
+		if gotOK != wantOK {
+			t.Errorf("%q: validity mismatch: want %t, got %t", test, wantOK, gotOK)
+		}
+		// When the input is invalid, the index stops at the beginning of the
+		// block containing the control character.
+		if !wantOK {
+			want = want[:min(len(want), len(got))]
+		}
+		if !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+			t.Errorf("%q: offsets mismatch\nwant: %v\ngot:  %v", test, want, got)
+		}
+	}
+}
+
+func loadCodeJSON(b *testing.B) []byte {
+	f, err := os.Open("../../testdata/code.json.gz")
+	if err != nil {
+		b.Skip(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkClassify(b *testing.B) {
+	data := loadCodeJSON(b)
+	blocks := make([]Block, len(data)/BlockSize)
+	b.SetBytes(int64(len(blocks) * BlockSize))
+
+	for range b.N {
+		Classify(blocks, data)
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	data := loadCodeJSON(b)
+	offsets, _ := Index(nil, data)
+	b.SetBytes(int64(len(data)))
+
+	for range b.N {
+		offsets, _ = Index(offsets[:0], data)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math/bits"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"unsafe"
 )
@@ -53,6 +54,103 @@ type UnsupportedTypeError = json.UnsupportedTypeError
 // UnsupportedValueError is documented at https://golang.org/pkg/encoding/json/#UnsupportedValueError
 type UnsupportedValueError = json.UnsupportedValueError
 
+// MarshalerTo is an extension to the Marshaler interface, implemented by types
+// that can append their JSON representation to a byte slice.
+//
+// When a type implements both MarshalerTo and Marshaler, the encoder prefers
+// MarshalerTo, which lets hot types be encoded without allocating intermediary
+// buffers. The flags are those of the encoding operation, implementations are
+// expected to honor the formatting flags like EscapeHTML that apply to them.
+//
+// The output is validated by the encoder, like the output of MarshalJSON,
+// unless the TrustRawMessage flag is set. Programs encoding hot types should
+// set the flag to also remove the cost of validation, which is then left to
+// the implementations.
+type MarshalerTo interface {
+	AppendJSON(b []byte, flags AppendFlags) ([]byte, error)
+}
+
+// UnmarshalerFrom is an extension to the Unmarshaler interface, implemented by
+// types that can decode themselves by reading tokens from a Tokenizer.
+//
+// When a type implements both UnmarshalerFrom and Unmarshaler, the decoder
+// prefers UnmarshalerFrom. The tokenizer only reads the JSON value being
+// decoded, and is reused after UnmarshalJSONFrom returns, so implementations
+// must not retain it. Implementations must read the entire value, the decoder
+// returns an error if tokens remain after UnmarshalJSONFrom returns.
+type UnmarshalerFrom interface {
+	UnmarshalJSONFrom(t *Tokenizer) error
+}
+
+// MissingFieldError is returned when decoding a JSON object into a Go struct
+// which has fields tagged as `required` that were absent from the object, or
+// into an interface registered with RegisterDiscriminator when the object has
+// no discriminator field.
+//
+// The error lists all the absent keys rather than only the first one, so a
+// program can report every missing field at once.
+type MissingFieldError struct {
+	// Type is the Go type that the object was decoded into.
+	Type reflect.Type
+	// Field is the path of the object in the JSON document, empty when the
+	// object was the top-level value.
+	Field string
+	// Fields lists the JSON names of the required fields that were absent.
+	Fields []string
+}
+
+func (e *MissingFieldError) Error() string {
+	s := "json: missing required field"
+	if len(e.Fields) > 1 {
+		s += "s"
+	}
+	s += " " + strings.Join(e.Fields, ", ")
+	if e.Type.Kind() == reflect.Struct {
+		s += " in Go struct " + e.Type.String()
+	} else {
+		s += " in Go value of type " + e.Type.String()
+	}
+	if e.Field != "" {
+		s += " at " + e.Field
+	}
+	return s
+}
+
+// Validator is implemented by types that check the validity of their values
+// after they were decoded.
+//
+// The decoder calls the Validate method of struct values once all their fields
+// were decoded, including the assignment of default values, so invalid nested
+// values are rejected at the point where they are decoded. The method is not
+// called when decoding a JSON null.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError is returned when the Validate method of a decoded value
+// returns an error, which it wraps.
+type ValidationError struct {
+	// Type is the Go type of the invalid value.
+	Type reflect.Type
+	// Field is the path of the value in the JSON document, empty when the
+	// value was the top-level value.
+	Field string
+	// Err is the error returned by the Validate method.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	s := "json: invalid value of Go type " + e.Type.String()
+	if e.Field != "" {
+		s += " at " + e.Field
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 // AppendFlags is a type used to represent configuration options that can be
 // applied when formatting json output.
 type AppendFlags uint32
@@ -71,6 +169,47 @@ const (
 	// known to be valid json (e.g., they were created by json.Unmarshal).
 	TrustRawMessage
 
+	// NonFiniteFloatsAsNull is a formatting flag used to encode NaN and
+	// infinite floating point values as null instead of returning an
+	// *UnsupportedValueError.
+	NonFiniteFloatsAsNull
+
+	// NonFiniteFloatsAsString is a formatting flag used to encode NaN and
+	// infinite floating point values as the strings "NaN", "Infinity", and
+	// "-Infinity" instead of returning an *UnsupportedValueError (this is the
+	// representation used by JavaScript's String function). The values can be
+	// decoded back when the AllowNonFiniteFloats parsing flag is set.
+	//
+	// With either flag, the values of fields with the ",string" option are
+	// written as a bare null or as one of these strings, without a second
+	// layer of quotes.
+	//
+	// NonFiniteFloatsAsNull takes precedence when both flags are set.
+	NonFiniteFloatsAsString
+
+	// EscapeNonASCII is a formatting flag used to escape all non-ASCII
+	// characters in json strings as \uXXXX sequences, using UTF-16 surrogate
+	// pairs for characters outside of the Basic Multilingual Plane, so the
+	// output only contains ASCII characters.
+	EscapeNonASCII
+
+	// BigValuesAsNumbers is a formatting flag used to encode *big.Float and
+	// *big.Rat values as json numbers instead of strings holding the output
+	// of their MarshalText method. Floats are written with the shortest
+	// decimal representation which rounds to the same value at their
+	// precision, and rationals with their exact decimal representation, or as
+	// strings like "1/3" when their decimal representation does not
+	// terminate. Infinite floats follow the same rules as float64 values.
+	BigValuesAsNumbers
+
+	// NilSlicesAsEmpty is a formatting flag used to encode nil slices as
+	// empty json arrays instead of null, and nil byte slices as empty strings.
+	NilSlicesAsEmpty
+
+	// NilMapsAsEmpty is a formatting flag used to encode nil maps and nil
+	// values of OrderedObject as empty json objects instead of null.
+	NilMapsAsEmpty
+
 	// appendNewline is a formatting flag to enable the addition of a newline
 	// in Encode (this matches the behavior of the standard encoding/json
 	// package).
@@ -141,6 +280,77 @@ const (
 	// for positive, in-range integers.
 	UseUint64
 
+	// AllowNonFiniteFloats is a parsing flag used to accept the strings "NaN",
+	// "Infinity", and "-Infinity" when decoding floating point values (see the
+	// NonFiniteFloatsAsString formatting flag).
+	AllowNonFiniteFloats
+
+	// UseOrderedObject is a parsing flag used to decode JSON objects into
+	// values of type OrderedObject instead of map[string]any when the
+	// destination is an empty interface, preserving the order of keys.
+	UseOrderedObject
+
+	// MergeObjects is a parsing flag used to merge JSON objects into the
+	// values held by maps for keys that already exist, instead of replacing
+	// them. Nested maps and structs are merged recursively. The flag also
+	// applies to map[string]any values held in interfaces, which are decoded
+	// into instead of being replaced by new maps.
+	//
+	// Objects are always merged into structs, as fields absent from the input
+	// are left unchanged. When the flag is set, this includes fields declared
+	// with a default value, which is only assigned to absent fields otherwise.
+	MergeObjects
+
+	// AppendSlices is a parsing flag used to append the elements of JSON
+	// arrays to the existing elements of slices, instead of replacing them.
+	AppendSlices
+
+	// MergeSlices is a parsing flag used to decode the elements of JSON arrays
+	// into the existing elements of slices at the same index. Slices keep
+	// their existing elements past the length of the JSON arrays. When both
+	// AppendSlices and MergeSlices are set, AppendSlices takes precedence.
+	MergeSlices
+
+	// ReuseContainers is a parsing flag used to recycle the memory of existing
+	// maps and byte slices, which are cleared instead of being merged into or
+	// reallocated. The flag also applies to map[string]any and []any values
+	// held in interfaces, which are reused instead of being replaced by new
+	// values.
+	//
+	// This flag is intended to reduce allocations when repeatedly decoding
+	// into the same value, the program must not retain references to the
+	// containers between decoding operations.
+	ReuseContainers
+
+	// Decode numbers into *big.Float, with a precision large enough to
+	// represent all their digits.
+	// Takes precedence over UseNumber. UseBigInt, UseInt64, and UseUint64
+	// take precedence for integers. The values are encoded back as json
+	// numbers when the BigValuesAsNumbers formatting flag is set.
+	UseBigFloat
+
+	// The flags below are not allocated with iota since the bits 16 to 23 hold
+	// the kind of json values (see kindOffset).
+
+	// InternStrings is a parsing flag used to deduplicate the strings decoded
+	// from the input, including map keys, by looking them up in a StringTable
+	// (see Decoder.SetStringTable). By default, the decoder uses a table
+	// shared by the whole program, which interns up to 4096 strings of up to
+	// 64 bytes.
+	//
+	// The flag reduces the memory retained by programs which keep many decoded
+	// values with repeated strings, at the cost of hashing the strings when
+	// they are decoded. Strings which are interned are always copied, the flag
+	// takes precedence over DontCopyString.
+	InternStrings ParseFlags = 1 << 24
+
+	// RejectDuplicateNames is a parsing flag used to return a *SyntaxError
+	// when an object of the input has two members with the same name, after
+	// unescaping. Names are compared case-sensitively, and the flag applies
+	// to all values of the input, including those decoded into RawMessage or
+	// skipped as unknown fields.
+	RejectDuplicateNames ParseFlags = 1 << 25
+
 	// ZeroCopy is a parsing flag that combines all the copy optimizations
 	// available in the package.
 	//
@@ -194,6 +404,10 @@ func (k Kind) Class() Kind { return Kind(1 << uint(bits.Len(uint(k))-1)) }
 // Append acts like Marshal but appends the json representation to b instead of
 // always reallocating a new slice.
 func Append(b []byte, x any, flags AppendFlags) ([]byte, error) {
+	return encoder{flags: flags}.append(b, x)
+}
+
+func (e encoder) append(b []byte, x any) ([]byte, error) {
 	if x == nil {
 		// Special case for nil values because it makes the rest of the code
 		// simpler to assume that it won't be seeing nil pointers.
@@ -203,14 +417,9 @@ func Append(b []byte, x any, flags AppendFlags) ([]byte, error) {
 	t := reflect.TypeOf(x)
 	p := (*iface)(unsafe.Pointer(&x)).ptr
 
-	cache := cacheLoad()
-	c, found := cache[typeid(t)]
+	c := codecOf(t)
 
-	if !found {
-		c = constructCachedCodec(t, cache)
-	}
-
-	b, err := c.encode(encoder{flags: flags}, b, p)
+	b, err := c.encode(e, b, p)
 	runtime.KeepAlive(x)
 	return b, err
 }
@@ -254,8 +463,17 @@ func AppendUnescape(b []byte, s []byte, flags ParseFlags) []byte {
 }
 
 // Compact is documented at https://golang.org/pkg/encoding/json/#Compact
+//
+// The input is validated like Valid, using the structural index of the input
+// on objects and arrays containing non-ASCII or escaped characters.
 func Compact(dst *bytes.Buffer, src []byte) error {
-	return json.Compact(dst, src)
+	v, err := parseDocument(src)
+	if err != nil {
+		return err
+	}
+	dst.Grow(len(v))
+	dst.Write(appendCompactEscape(dst.AvailableBuffer(), v, 0))
+	return nil
 }
 
 // HTMLEscape is documented at https://golang.org/pkg/encoding/json/#HTMLEscape
@@ -268,6 +486,35 @@ func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
 	return json.Indent(dst, src, prefix, indent)
 }
 
+// AppendCompact appends the json value in src to b without insignificant
+// whitespace, and returns the extended buffer. Strings are escaped according
+// to the EscapeHTML and EscapeNonASCII flags, other flags are ignored.
+//
+// An error is returned if src is not valid json, in which case b is returned
+// unchanged.
+func AppendCompact(b, src []byte, flags AppendFlags) ([]byte, error) {
+	v, err := parseDocument(src)
+	if err != nil {
+		return b, err
+	}
+	return appendCompactEscape(b, v, flags), nil
+}
+
+// AppendIndent is like AppendCompact but formats the output like Indent, with
+// each element of objects and arrays on a new line starting with prefix and
+// one copy of indent per nesting level.
+func AppendIndent(b, src []byte, prefix, indent string, flags AppendFlags) ([]byte, error) {
+	v, err := parseDocument(src)
+	if err != nil {
+		return b, err
+	}
+	buf := bytes.NewBuffer(b)
+	if err := json.Indent(buf, appendCompactEscape(nil, v, flags), prefix, indent); err != nil {
+		return b, err
+	}
+	return buf.Bytes(), nil
+}
+
 // Marshal is documented at https://golang.org/pkg/encoding/json/#Marshal
 func Marshal(x any) ([]byte, error) {
 	var err error
@@ -315,13 +562,23 @@ func Unmarshal(b []byte, x any) error {
 // Parse behaves like Unmarshal but the caller can pass a set of flags to
 // configure the parsing behavior.
 func Parse(b []byte, x any, flags ParseFlags) ([]byte, error) {
+	return decoder{flags: flags}.parse(b, x)
+}
+
+func (d decoder) parse(b []byte, x any) ([]byte, error) {
 	t := reflect.TypeOf(x)
 	p := (*iface)(unsafe.Pointer(&x)).ptr
 
-	d := decoder{flags: flags | internalParseFlags(b)}
+	d.flags |= internalParseFlags(b)
 
 	b = skipSpaces(b)
 
+	if d.flags.has(RejectDuplicateNames) {
+		if err := d.checkDuplicateNames(b); err != nil {
+			return b, err
+		}
+	}
+
 	if t == nil || p == nil || t.Kind() != reflect.Ptr {
 		_, r, _, err := d.parseValue(b)
 		r = skipSpaces(r)
@@ -332,12 +589,7 @@ func Parse(b []byte, x any, flags ParseFlags) ([]byte, error) {
 	}
 	t = t.Elem()
 
-	cache := cacheLoad()
-	c, found := cache[typeid(t)]
-
-	if !found {
-		c = constructCachedCodec(t, cache)
-	}
+	c := codecOf(t)
 
 	r, err := c.decode(d, b, p)
 	return skipSpaces(r), err
@@ -347,13 +599,39 @@ func Parse(b []byte, x any, flags ParseFlags) ([]byte, error) {
 func Valid(data []byte) bool {
 	data = skipSpaces(data)
 	d := decoder{flags: internalParseFlags(data)}
-	_, data, _, err := d.parseValue(data)
+	_, data, err := d.skipValue(data)
 	if err != nil {
 		return false
 	}
 	return len(skipSpaces(data)) == 0
 }
 
+// Framing represents the ways of delimiting JSON values in the streams read by
+// a Decoder or written by an Encoder.
+type Framing int
+
+const (
+	// Concatenated is the default framing, where JSON values are concatenated
+	// and optionally separated by whitespace.
+	Concatenated Framing = iota
+
+	// Lines is the framing of streams containing exactly one JSON value per
+	// line, also known as JSON Lines or NDJSON. The Decoder returns an error
+	// when a value spans multiple lines or shares a line with another value,
+	// and the Encoder never indents values and always writes a newline after
+	// them.
+	Lines
+
+	// TextSequence is the framing of JSON text sequences defined in RFC 7464
+	// (application/json-seq), where each value is prefixed with an ASCII
+	// record separator (0x1E) and followed by a newline.
+	//
+	// When the Decoder reads a record which does not contain exactly one valid
+	// JSON value, or which looks truncated, it returns an error and resumes
+	// decoding at the next record. Empty records are skipped.
+	TextSequence
+)
+
 // Decoder is documented at https://golang.org/pkg/encoding/json/#Decoder
 type Decoder struct {
 	reader      io.Reader
@@ -362,6 +640,11 @@ type Decoder struct {
 	inputOffset int64
 	err         error
 	flags       ParseFlags
+	strings     *StringTable
+	framing     Framing
+	// sameLine is true when no newline was read since the last value, it is
+	// only maintained with the Lines framing.
+	sameLine bool
 }
 
 // NewDecoder is documented at https://golang.org/pkg/encoding/json/#NewDecoder
@@ -378,7 +661,7 @@ func (dec *Decoder) Decode(v any) error {
 	if err != nil {
 		return err
 	}
-	_, err = Parse(raw, v, dec.flags)
+	_, err = decoder{flags: dec.flags, strings: dec.strings}.parse(raw, v)
 	return err
 }
 
@@ -390,6 +673,10 @@ const (
 // readValue reads one JSON value from the buffer and returns its raw bytes. It
 // is optimized for the "one JSON value per line" case.
 func (dec *Decoder) readValue() (v []byte, err error) {
+	if dec.framing == TextSequence {
+		return dec.readRecord()
+	}
+
 	var n int
 	var r []byte
 	d := decoder{flags: dec.flags}
@@ -398,8 +685,17 @@ func (dec *Decoder) readValue() (v []byte, err error) {
 		if len(dec.remain) != 0 {
 			v, r, _, err = d.parseValue(dec.remain)
 			if err == nil {
+				if dec.framing == Lines {
+					if dec.sameLine {
+						return nil, syntaxError(v, "expected newline before JSON value")
+					}
+					if bytes.IndexByte(v, nl) >= 0 {
+						return nil, syntaxError(v, "unexpected newline in JSON value")
+					}
+				}
 				dec.remain, n = skipSpacesN(r)
 				dec.inputOffset += int64(len(v) + n)
+				dec.sameLine = bytes.IndexByte(r[:len(r)-len(dec.remain)], nl) < 0
 				return
 			}
 			if len(r) != 0 {
@@ -417,33 +713,106 @@ func (dec *Decoder) readValue() (v []byte, err error) {
 			return
 		}
 
-		if dec.buffer == nil {
-			dec.buffer = make([]byte, 0, minBufferSize)
-		} else {
-			dec.buffer = dec.buffer[:copy(dec.buffer[:cap(dec.buffer)], dec.remain)]
-			dec.remain = nil
+		dec.fill()
+		d.flags = dec.flags | internalParseFlags(dec.remain)
+	}
+}
+
+// readRecord reads one record of a JSON text sequence and returns the raw
+// bytes of the JSON value that it contains. Records are consumed even when
+// they are invalid, so the next call resumes at the following record.
+func (dec *Decoder) readRecord() ([]byte, error) {
+	for {
+		if len(dec.remain) != 0 {
+			if i := bytes.IndexByte(dec.remain[1:], rs); i >= 0 || dec.err != nil {
+				var record []byte
+				if i >= 0 {
+					record, dec.remain = dec.remain[:i+1], dec.remain[i+1:]
+				} else {
+					record, dec.remain = dec.remain, nil
+				}
+				dec.inputOffset += int64(len(record))
+
+				if v, err := dec.parseRecord(record); v != nil || err != nil {
+					return v, err
+				}
+				continue
+			}
 		}
 
-		if (cap(dec.buffer) - len(dec.buffer)) < minReadSize {
-			buf := make([]byte, len(dec.buffer), 2*cap(dec.buffer))
-			copy(buf, dec.buffer)
-			dec.buffer = buf
+		if dec.err != nil {
+			return nil, dec.err
 		}
 
-		n, err = io.ReadFull(dec.reader, dec.buffer[len(dec.buffer):cap(dec.buffer)])
-		if n > 0 {
-			dec.buffer = dec.buffer[:len(dec.buffer)+n]
-			if err != nil {
-				err = nil
-			}
-		} else if err == io.ErrUnexpectedEOF {
-			err = io.EOF
+		dec.fill()
+	}
+}
+
+// parseRecord returns the JSON value contained in a record of a JSON text
+// sequence, or nil if the record is empty.
+func (dec *Decoder) parseRecord(record []byte) ([]byte, error) {
+	if record[0] != rs {
+		return nil, syntaxError(record, "expected record separator before JSON text")
+	}
+
+	b := skipSpaces(record[1:])
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	d := decoder{flags: dec.flags | internalParseFlags(b)}
+	v, r, k, err := d.parseValue(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 7464 section 2.4: values which are only known to be complete when
+	// they are followed by whitespace were possibly truncated otherwise.
+	switch k.Class() {
+	case Null, Bool, Num:
+		if len(r) == 0 {
+			return nil, syntaxError(v, "truncated JSON text")
 		}
-		dec.remain, n = skipSpacesN(dec.buffer)
-		d.flags = dec.flags | internalParseFlags(dec.remain)
-		dec.inputOffset += int64(n)
-		dec.err = err
 	}
+
+	if r = skipSpaces(r); len(r) != 0 {
+		return nil, syntaxError(r, "invalid character '%c' after JSON text", r[0])
+	}
+
+	return v, nil
+}
+
+// fill reads more bytes from the underlying reader into the buffer, preserving
+// the bytes which were not consumed yet.
+func (dec *Decoder) fill() {
+	if dec.buffer == nil {
+		dec.buffer = make([]byte, 0, minBufferSize)
+	} else {
+		dec.buffer = dec.buffer[:copy(dec.buffer[:cap(dec.buffer)], dec.remain)]
+		dec.remain = nil
+	}
+
+	if (cap(dec.buffer) - len(dec.buffer)) < minReadSize {
+		buf := make([]byte, len(dec.buffer), 2*cap(dec.buffer))
+		copy(buf, dec.buffer)
+		dec.buffer = buf
+	}
+
+	n, err := io.ReadFull(dec.reader, dec.buffer[len(dec.buffer):cap(dec.buffer)])
+	if n > 0 {
+		dec.buffer = dec.buffer[:len(dec.buffer)+n]
+		if err != nil {
+			err = nil
+		}
+	} else if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	dec.remain, n = skipSpacesN(dec.buffer)
+	if bytes.IndexByte(dec.buffer[:len(dec.buffer)-len(dec.remain)], nl) >= 0 {
+		dec.sameLine = false
+	}
+	dec.inputOffset += int64(n)
+	dec.err = err
 }
 
 // DisallowUnknownFields is documented at https://golang.org/pkg/encoding/json/#Decoder.DisallowUnknownFields
@@ -480,6 +849,70 @@ func (dec *Decoder) DontMatchCaseInsensitiveStructFields() {
 // all the copy optimizations of the decoder.
 func (dec *Decoder) ZeroCopy() { dec.flags |= ZeroCopy }
 
+// AllowNonFiniteFloats is an extension to the standard encoding/json package
+// which instructs the decoder to accept the strings "NaN", "Infinity", and
+// "-Infinity" as floating point values.
+func (dec *Decoder) AllowNonFiniteFloats() { dec.flags |= AllowNonFiniteFloats }
+
+// UseOrderedObject is an extension to the standard encoding/json package
+// which instructs the decoder to decode JSON objects into values of type
+// OrderedObject instead of map[string]any when the destination is an empty
+// interface.
+func (dec *Decoder) UseOrderedObject() { dec.flags |= UseOrderedObject }
+
+// MergeObjects is an extension to the standard encoding/json package which
+// merges JSON objects into the existing values of maps (see the MergeObjects
+// parsing flag).
+func (dec *Decoder) MergeObjects() { dec.flags |= MergeObjects }
+
+// AppendSlices is an extension to the standard encoding/json package which
+// appends the elements of JSON arrays to existing slices (see the
+// AppendSlices parsing flag).
+func (dec *Decoder) AppendSlices() { dec.flags |= AppendSlices }
+
+// MergeSlices is an extension to the standard encoding/json package which
+// decodes the elements of JSON arrays into the existing elements of slices
+// (see the MergeSlices parsing flag).
+func (dec *Decoder) MergeSlices() { dec.flags |= MergeSlices }
+
+// ReuseContainers is an extension to the standard encoding/json package which
+// recycles the memory of existing maps and byte slices (see the
+// ReuseContainers parsing flag).
+func (dec *Decoder) ReuseContainers() { dec.flags |= ReuseContainers }
+
+// SetFraming is an extension to the standard encoding/json package which sets
+// the way values are delimited in the input stream, the default being
+// Concatenated.
+func (dec *Decoder) SetFraming(f Framing) { dec.framing = f }
+
+// UseBigFloat is an extension to the standard encoding/json package which
+// causes the Decoder to unmarshal numbers into an interface{} as a *big.Float
+// instead of as a float64 (see the UseBigFloat parsing flag).
+func (dec *Decoder) UseBigFloat() { dec.flags |= UseBigFloat }
+
+// RejectDuplicateNames is an extension to the standard encoding/json package
+// which causes the Decoder to return an error when an object has two members
+// with the same name (see the RejectDuplicateNames parsing flag).
+func (dec *Decoder) RejectDuplicateNames() { dec.flags |= RejectDuplicateNames }
+
+// InternStrings is an extension to the standard encoding/json package which
+// deduplicates the decoded strings using the table shared by the program (see
+// the InternStrings parsing flag).
+func (dec *Decoder) InternStrings() { dec.flags |= InternStrings }
+
+// SetStringTable is an extension to the standard encoding/json package which
+// deduplicates the decoded strings using t instead of the table shared by the
+// program. Tables can be shared by multiple decoders.
+//
+// Passing a nil table disables interning.
+func (dec *Decoder) SetStringTable(t *StringTable) {
+	if dec.strings = t; t != nil {
+		dec.flags |= InternStrings
+	} else {
+		dec.flags &= ^InternStrings
+	}
+}
+
 // InputOffset returns the input stream byte offset of the current decoder position.
 // The offset gives the location of the end of the most recently returned token
 // and the beginning of the next token.
@@ -495,6 +928,14 @@ type Encoder struct {
 	buffer *bytes.Buffer
 	err    error
 	flags  AppendFlags
+	// framing is the way values are delimited in the output.
+	framing Framing
+	// flushThreshold is the size of the output buffer past which it is
+	// written while encoding arrays, maps, and structs, zero when disabled.
+	flushThreshold int
+	// flushed is set when part of the output of the value being encoded was
+	// written, which means that it cannot be rolled back on errors.
+	flushed bool
 }
 
 // NewEncoder is documented at https://golang.org/pkg/encoding/json/#NewEncoder
@@ -503,33 +944,72 @@ func NewEncoder(w io.Writer) *Encoder {
 }
 
 // Encode is documented at https://golang.org/pkg/encoding/json/#Encoder.Encode
+//
+// Unless an indentation was configured, the output of values produced by
+// iterators (iter.Seq and iter.Seq2) is written incrementally while the values
+// are encoded, see also SetFlushThreshold. If an error occurs after part of the
+// output was written, the output is left truncated and the error is returned by
+// all subsequent calls to Encode.
 func (enc *Encoder) Encode(v any) error {
 	if enc.err != nil {
 		return enc.err
 	}
+	enc.flushed = false
 
 	var err error
 	buf := encoderBufferPool.Get().(*encoderBuffer)
 
-	buf.data, err = Append(buf.data[:0], v, enc.flags)
+	// Values are never indented when the output has one value per line.
+	indent := (enc.prefix != "" || enc.indent != "") && enc.framing != Lines
+
+	// The output is written incrementally while encoding values such as
+	// iterators, unless it needs to be indented.
+	e := encoder{flags: enc.flags}
+	if !indent {
+		e.stream = enc
+	}
+
+	// Records of JSON text sequences start with a separator, which is kept
+	// out of the input of Indent.
+	start := 0
+	if enc.framing == TextSequence {
+		buf.data = append(buf.data[:0], rs)
+		start = 1
+	}
+
+	buf.data, err = e.append(buf.data[:start], v)
+	if err == nil {
+		// Write errors may have been swallowed by codecs which ignore the
+		// errors of their elements.
+		err = enc.err
+	}
 	if err != nil {
+		if enc.flushed {
+			// The output holds a truncated value, values encoded after it
+			// would not be decodable.
+			enc.err = err
+		}
 		encoderBufferPool.Put(buf)
 		return err
 	}
 
-	if (enc.flags & appendNewline) != 0 {
+	if enc.framing == Lines {
+		buf.data = removeNewlines(buf.data)
+	}
+	if (enc.flags&appendNewline) != 0 || enc.framing != Concatenated {
 		buf.data = append(buf.data, '\n')
 	}
 	b := buf.data
 
-	if enc.prefix != "" || enc.indent != "" {
+	if indent {
 		if enc.buffer == nil {
 			enc.buffer = new(bytes.Buffer)
 			enc.buffer.Grow(2 * len(buf.data))
 		} else {
 			enc.buffer.Reset()
 		}
-		Indent(enc.buffer, buf.data, enc.prefix, enc.indent)
+		enc.buffer.Write(buf.data[:start])
+		Indent(enc.buffer, buf.data[start:], enc.prefix, enc.indent)
 		b = enc.buffer.Bytes()
 	}
 
@@ -550,6 +1030,17 @@ func (enc *Encoder) SetEscapeHTML(on bool) {
 	}
 }
 
+// SetEscapeNonASCII is an extension to the standard encoding/json package which
+// allows the program to toggle the escaping of non-ASCII characters in json
+// strings on and off, see EscapeNonASCII.
+func (enc *Encoder) SetEscapeNonASCII(on bool) {
+	if on {
+		enc.flags |= EscapeNonASCII
+	} else {
+		enc.flags &= ^EscapeNonASCII
+	}
+}
+
 // SetIndent is documented at https://golang.org/pkg/encoding/json/#Encoder.SetIndent
 func (enc *Encoder) SetIndent(prefix, indent string) {
 	enc.prefix = prefix
@@ -577,6 +1068,80 @@ func (enc *Encoder) SetTrustRawMessage(on bool) {
 	}
 }
 
+// SetNonFiniteFloatsAsNull is an extension to the standard encoding/json
+// package which allows the program to encode NaN and infinite floating point
+// values as null instead of failing.
+func (enc *Encoder) SetNonFiniteFloatsAsNull(on bool) {
+	if on {
+		enc.flags |= NonFiniteFloatsAsNull
+	} else {
+		enc.flags &= ^NonFiniteFloatsAsNull
+	}
+}
+
+// SetNonFiniteFloatsAsString is an extension to the standard encoding/json
+// package which allows the program to encode NaN and infinite floating point
+// values as the strings "NaN", "Infinity", and "-Infinity" instead of failing.
+func (enc *Encoder) SetNonFiniteFloatsAsString(on bool) {
+	if on {
+		enc.flags |= NonFiniteFloatsAsString
+	} else {
+		enc.flags &= ^NonFiniteFloatsAsString
+	}
+}
+
+// SetBigValuesAsNumbers is an extension to the standard encoding/json package
+// which allows the program to toggle the encoding of *big.Float and *big.Rat
+// values as json numbers on and off, see BigValuesAsNumbers.
+func (enc *Encoder) SetBigValuesAsNumbers(on bool) {
+	if on {
+		enc.flags |= BigValuesAsNumbers
+	} else {
+		enc.flags &= ^BigValuesAsNumbers
+	}
+}
+
+// SetNilSlicesAsEmpty is an extension to the standard encoding/json package
+// which allows the program to toggle the encoding of nil slices as empty json
+// arrays on and off, see NilSlicesAsEmpty.
+func (enc *Encoder) SetNilSlicesAsEmpty(on bool) {
+	if on {
+		enc.flags |= NilSlicesAsEmpty
+	} else {
+		enc.flags &= ^NilSlicesAsEmpty
+	}
+}
+
+// SetNilMapsAsEmpty is an extension to the standard encoding/json package
+// which allows the program to toggle the encoding of nil maps as empty json
+// objects on and off, see NilMapsAsEmpty.
+func (enc *Encoder) SetNilMapsAsEmpty(on bool) {
+	if on {
+		enc.flags |= NilMapsAsEmpty
+	} else {
+		enc.flags &= ^NilMapsAsEmpty
+	}
+}
+
+// SetFlushThreshold is an extension to the standard encoding/json package which
+// bounds the memory used by Encode: when the encoded output grows past n bytes
+// while encoding the elements of arrays, slices, maps, and structs, it is
+// written to the underlying io.Writer and the buffer is reused.
+//
+// A value of zero, the default, disables the behavior. Flushing is also
+// disabled when the Encoder is configured to indent the output. Like with
+// iterators, the output is left truncated when an error occurs after part of
+// a value was written, and the Encoder fails on subsequent calls to Encode.
+func (enc *Encoder) SetFlushThreshold(n int) {
+	enc.flushThreshold = max(n, 0)
+}
+
+// SetFraming is an extension to the standard encoding/json package which sets
+// the way values are delimited in the output stream, the default being
+// Concatenated. With the Lines and TextSequence framings, a newline is always
+// written after each value.
+func (enc *Encoder) SetFraming(f Framing) { enc.framing = f }
+
 // SetAppendNewline is an extension to the standard encoding/json package which
 // allows the program to toggle the addition of a newline in Encode on or off.
 func (enc *Encoder) SetAppendNewline(on bool) {
@@ -587,6 +1152,10 @@ func (enc *Encoder) SetAppendNewline(on bool) {
 	}
 }
 
+// flushThreshold is the size of the output buffer past which the Encoder writes
+// it while encoding values, see encoder.flush.
+const flushThreshold = 4096
+
 var encoderBufferPool = sync.Pool{
 	New: func() any { return &encoderBuffer{data: make([]byte, 0, 4096)} },
 }
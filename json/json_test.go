@@ -9,16 +9,20 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
 	"math"
 	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 // The encoding/json package does not export the msg field of json.SyntaxError,
@@ -591,6 +595,38 @@ var numericParseTests = [...]struct {
 		flags: UseUint64 | UseInt64,
 		want:  float64(-1 << 128),
 	},
+	{
+		name:  "decimal_flags_bigfloat_number",
+		input: `0.1`,
+		flags: UseBigFloat | UseNumber,
+		want:  mustParseBigFloat("0.1"),
+	},
+	{
+		name:  "zero_flags_bigfloat",
+		input: `0`,
+		flags: UseBigFloat,
+		want:  mustParseBigFloat("0"),
+	},
+	{
+		name:  "zero_flags_bigint_bigfloat",
+		input: `0`,
+		flags: UseBigInt | UseBigFloat,
+		want:  big.NewInt(0),
+	},
+	{
+		name:  "decimal_flags_bigint_bigfloat",
+		input: `-1.5e3`,
+		flags: UseBigInt | UseBigFloat,
+		want:  mustParseBigFloat("-1.5e3"),
+	},
+}
+
+func mustParseBigFloat(s string) *big.Float {
+	f, ok := new(big.Float).SetPrec(64).SetString(s)
+	if !ok {
+		panic("invalid big.Float: " + s)
+	}
+	return f
 }
 
 func TestParse_numeric(t *testing.T) {
@@ -1993,3 +2029,2786 @@ func TestKind(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeStructDefaults(t *testing.T) {
+	type Embedded struct {
+		Level int `json:"level,default=3"`
+	}
+
+	type config struct {
+		*Embedded
+		Name    string            `json:"name,default=anonymous"`
+		Quoted  string            `json:"quoted,default=\"true\""`
+		Count   int               `json:"count,default=42"`
+		Ratio   float64           `json:"ratio,omitempty,default=0.5"`
+		Enabled bool              `json:"enabled,default=true"`
+		Timeout time.Duration     `json:"timeout,default=1m30s"`
+		Tags    []string          `json:"tags,default=[\"a\",\"b\"]"`
+		Labels  map[string]string `json:"labels,default={\"k\":\"v\"}"`
+		Other   int               `json:"other"`
+	}
+
+	c1 := config{}
+	if err := Unmarshal([]byte(`{"count":1,"tags":null}`), &c1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c1.Embedded == nil || c1.Level != 3 {
+		t.Errorf("embedded default was not applied: %+v", c1.Embedded)
+	}
+	if c1.Name != "anonymous" {
+		t.Errorf("name: want=anonymous got=%q", c1.Name)
+	}
+	if c1.Quoted != "true" {
+		t.Errorf("quoted: want=true got=%q", c1.Quoted)
+	}
+	if c1.Count != 1 {
+		t.Errorf("count: want=1 got=%d", c1.Count)
+	}
+	if c1.Ratio != 0.5 {
+		t.Errorf("ratio: want=0.5 got=%g", c1.Ratio)
+	}
+	if !c1.Enabled {
+		t.Error("enabled: want=true got=false")
+	}
+	if c1.Timeout != 90*time.Second {
+		t.Errorf("timeout: want=1m30s got=%s", c1.Timeout)
+	}
+	if c1.Tags != nil {
+		t.Errorf("tags: present keys must not be replaced by defaults, got %q", c1.Tags)
+	}
+	if !reflect.DeepEqual(c1.Labels, map[string]string{"k": "v"}) {
+		t.Errorf("labels: want=map[k:v] got=%v", c1.Labels)
+	}
+
+	// Defaults holding references must not be shared between values.
+	c1.Labels["k"] = "changed"
+	c2 := config{}
+	if err := Unmarshal([]byte(`{}`), &c2); err != nil {
+		t.Fatal(err)
+	}
+	if c2.Labels["k"] != "v" {
+		t.Errorf("labels: default value was mutated by a previous decode: %v", c2.Labels)
+	}
+	if !reflect.DeepEqual(c2.Tags, []string{"a", "b"}) {
+		t.Errorf("tags: want=[a b] got=%q", c2.Tags)
+	}
+}
+
+func TestDecodeStructInvalidDefault(t *testing.T) {
+	var v struct {
+		N int `json:"n,default=abc"`
+	}
+	if err := Unmarshal([]byte(`{}`), &v); err == nil {
+		t.Error("expected an error decoding a struct with an invalid default value")
+	}
+	if err := Unmarshal([]byte(`{"n":1}`), &v); err == nil {
+		t.Error("expected an error decoding a field with an invalid default value")
+	}
+	if _, err := Marshal(v); err == nil {
+		t.Error("expected an error encoding a field with an invalid default value")
+	}
+}
+
+func TestDecodeStructDefaultsMergeObjects(t *testing.T) {
+	type D struct {
+		A int    `json:"a,default=5"`
+		B string `json:"b"`
+	}
+
+	// Absent fields keep their values when objects are merged, otherwise the
+	// default value is assigned.
+	tests := []struct {
+		flags  ParseFlags
+		expect D
+	}{
+		{flags: 0, expect: D{A: 5, B: "x"}},
+		{flags: MergeObjects, expect: D{A: 9, B: "x"}},
+	}
+
+	for _, test := range tests {
+		v := D{A: 9}
+		if _, err := Parse([]byte(`{"b":"x"}`), &v, test.flags); err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expect {
+			t.Errorf("flags %v: want=%+v got=%+v", test.flags, test.expect, v)
+		}
+	}
+}
+
+func TestDecodeStructRequiredFields(t *testing.T) {
+	type inner struct {
+		ID   string `json:"id,required"`
+		Name string `json:"name,required"`
+	}
+
+	type outer struct {
+		Kind   string             `json:"kind,required"`
+		Inner  inner              `json:"inner"`
+		Items  []inner            `json:"items"`
+		Pair   [2]inner           `json:"pair"`
+		M      map[string]inner   `json:"m"`
+		Nested []map[string]inner `json:"nested"`
+	}
+
+	tests := []struct {
+		input  string
+		field  string
+		fields []string
+	}{
+		{input: `{"kind":"a","inner":{"id":"1","name":"n"}}`},
+		{input: `{"kind":null,"inner":{"ID":"1","NAME":"n"}}`},
+		{input: `{"inner":{"id":"1","name":"n"}}`, fields: []string{"kind"}},
+		{input: `{"kind":"a","inner":{}}`, field: "inner", fields: []string{"id", "name"}},
+		{input: `{"kind":"a","items":[{"id":"1","name":"n"},{"name":"n"}]}`, field: "items.1", fields: []string{"id"}},
+		{input: `{"kind":"a","pair":[{"id":"1","name":"n"},{}]}`, field: "pair.1", fields: []string{"id", "name"}},
+		{input: `{"kind":"a","m":{"k":{}}}`, field: "m.k", fields: []string{"id", "name"}},
+		{input: `{"kind":"a","m":{"k":{"id":"1"}},"inner":{"id":"1","name":"n"}}`, field: "m.k", fields: []string{"name"}},
+		{input: `{"kind":"a","nested":[{"x":{"name":"n"}}]}`, field: "nested.0.x", fields: []string{"id"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var v outer
+			err := Unmarshal([]byte(test.input), &v)
+
+			if test.fields == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var e *MissingFieldError
+			if !errors.As(err, &e) {
+				t.Fatalf("expected *MissingFieldError but got %T: %v", err, err)
+			}
+			if e.Field != test.field {
+				t.Errorf("field: want=%q got=%q", test.field, e.Field)
+			}
+			if !reflect.DeepEqual(e.Fields, test.fields) {
+				t.Errorf("fields: want=%q got=%q", test.fields, e.Fields)
+			}
+		})
+	}
+}
+
+func TestCodecTimeFormats(t *testing.T) {
+	type event struct {
+		Unix      time.Time      `json:"unix,format:unix"`
+		UnixMilli time.Time      `json:"unix_milli,format:unixmilli"`
+		UnixNano  *time.Time     `json:"unix_nano,format:unixnano"`
+		Date      time.Time      `json:"date,format:DateOnly"`
+		Custom    time.Time      `json:"custom,format:'Jan-2,2006@15:04'"`
+		Timeout   time.Duration  `json:"timeout,format:sec"`
+		Delay     *time.Duration `json:"delay,format:milli"`
+		Elapsed   time.Duration  `json:"elapsed,format:nanos"`
+	}
+
+	ts := time.Date(2023, 11, 14, 22, 13, 20, 123456789, time.UTC)
+	delay := -1500 * time.Microsecond
+	in := event{
+		Unix:      ts,
+		UnixMilli: ts,
+		UnixNano:  &ts,
+		Date:      ts,
+		Custom:    ts,
+		Timeout:   2500 * time.Millisecond,
+		Delay:     &delay,
+		Elapsed:   time.Second,
+	}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `{"unix":1700000000,"unix_milli":1700000000123,"unix_nano":1700000000123456789,"date":"2023-11-14","custom":"Nov-14,2023@22:13","timeout":2.5,"delay":-1.5,"elapsed":1000000000}`
+	if string(b) != expect {
+		t.Errorf("encoding mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+
+	var out event
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.Unix.Equal(ts.Truncate(time.Second)) {
+		t.Errorf("unix: want=%s got=%s", ts.Truncate(time.Second), out.Unix)
+	}
+	if !out.UnixMilli.Equal(ts.Truncate(time.Millisecond)) {
+		t.Errorf("unix_milli: want=%s got=%s", ts.Truncate(time.Millisecond), out.UnixMilli)
+	}
+	if out.UnixNano == nil || !out.UnixNano.Equal(ts) {
+		t.Errorf("unix_nano: want=%s got=%v", ts, out.UnixNano)
+	}
+	if want := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC); !out.Date.Equal(want) {
+		t.Errorf("date: want=%s got=%s", want, out.Date)
+	}
+	if want := time.Date(2023, 11, 14, 22, 13, 0, 0, time.UTC); !out.Custom.Equal(want) {
+		t.Errorf("custom: want=%s got=%s", want, out.Custom)
+	}
+	if out.Timeout != in.Timeout {
+		t.Errorf("timeout: want=%s got=%s", in.Timeout, out.Timeout)
+	}
+	if out.Delay == nil || *out.Delay != delay {
+		t.Errorf("delay: want=%s got=%v", delay, out.Delay)
+	}
+	if out.Elapsed != in.Elapsed {
+		t.Errorf("elapsed: want=%s got=%s", in.Elapsed, out.Elapsed)
+	}
+}
+
+func TestDecodeUnixTimeFormats(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect time.Time
+	}{
+		{input: `1700000000`, expect: time.Unix(1700000000, 0)},
+		{input: `"1700000000"`, expect: time.Unix(1700000000, 0)},
+		{input: `1700000000.25`, expect: time.Unix(1700000000, 250000000)},
+		{input: `-1.5`, expect: time.Unix(-2, 500000000)},
+		{input: `1.7e9`, expect: time.Unix(1700000000, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var v struct {
+				T time.Time `json:"t,format:unix"`
+			}
+			if err := Unmarshal([]byte(`{"t":`+test.input+`}`), &v); err != nil {
+				t.Fatal(err)
+			}
+			if !v.T.Equal(test.expect) {
+				t.Errorf("want=%s got=%s", test.expect, v.T)
+			}
+		})
+	}
+
+	var v struct {
+		T time.Time `json:"t,format:unix"`
+	}
+	if err := Unmarshal([]byte(`{"t":"yesterday"}`), &v); err == nil {
+		t.Error("expected an error decoding a non-numeric unix timestamp")
+	}
+}
+
+func TestCodecInvalidFormats(t *testing.T) {
+	values := []any{
+		&struct {
+			T time.Time `json:"t,format:unixmili"`
+		}{},
+		&struct {
+			T time.Time `json:"t,format:rfc3339"`
+		}{},
+		&struct {
+			T *time.Time `json:"t,format:'not-a-layout'"`
+		}{},
+		&struct {
+			D time.Duration `json:"d,format:seconds"`
+		}{},
+		&struct {
+			N int `json:"n,format:hex"`
+		}{},
+	}
+
+	for _, v := range values {
+		t.Run(reflect.TypeOf(v).Elem().Field(0).Tag.Get("json"), func(t *testing.T) {
+			if _, err := Marshal(v); err == nil {
+				t.Error("expected an error encoding a field with an invalid format")
+			}
+			if err := Unmarshal([]byte(`{}`), v); err != nil {
+				t.Errorf("unexpected error decoding an object without the field: %v", err)
+			}
+			if err := Unmarshal([]byte(`{"t":1,"d":1,"n":1}`), v); err == nil {
+				t.Error("expected an error decoding a field with an invalid format")
+			}
+		})
+	}
+}
+
+func TestDecodeDurationUnitOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expect   time.Duration
+		overflow bool
+	}{
+		{input: `9223372036.854775807`, expect: math.MaxInt64},
+		{input: `-9223372036.854775808`, expect: math.MinInt64},
+		{input: `9223372036.854775808`, overflow: true},
+		{input: `9223372036.9`, overflow: true},
+		{input: `-9223372036.9`, overflow: true},
+		{input: `9223372037`, overflow: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var v struct {
+				D time.Duration `json:"d,format:sec"`
+			}
+			err := Unmarshal([]byte(`{"d":`+test.input+`}`), &v)
+			if test.overflow {
+				if err == nil {
+					t.Errorf("expected an overflow error but decoded %d", v.D)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.D != test.expect {
+				t.Errorf("want=%d got=%d", test.expect, v.D)
+			}
+		})
+	}
+}
+
+func TestDecodeStructKeyOrderChanges(t *testing.T) {
+	type T struct {
+		A int `json:"a"`
+		B int `json:"b"`
+		C int `json:"c"`
+	}
+
+	// Alternate between key orders so the predictions of the decoder keep
+	// being invalidated.
+	inputs := []string{
+		`{"a":1,"b":2,"c":3}`,
+		`{"c":3,"b":2,"a":1}`,
+		`{"b":2,"x":0,"C":3,"a":1}`,
+		`{"a":1,"b":2,"c":3}`,
+	}
+
+	for i := range 3 * len(inputs) {
+		var v T
+		if err := Unmarshal([]byte(inputs[i%len(inputs)]), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v != (T{A: 1, B: 2, C: 3}) {
+			t.Fatalf("%s: wrong value decoded: %+v", inputs[i%len(inputs)], v)
+		}
+	}
+}
+
+func BenchmarkUnmarshalKeyOrder(b *testing.B) {
+	v, ok := loadTestdata("testdata/code.json.gz").(*codeResponse2)
+	if !ok {
+		b.Skip("testdata/code.json.gz could not be loaded")
+	}
+
+	ordered, err := Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Decoding into an empty interface and re-encoding sorts the keys, which
+	// is a different order than the struct fields.
+	var m any
+	if err := Unmarshal(ordered, &m); err != nil {
+		b.Fatal(err)
+	}
+	sorted, err := Marshal(m)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "struct-order", input: ordered},
+		{name: "sorted-keys", input: sorted},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			b.SetBytes(int64(len(test.input)))
+			b.ReportAllocs()
+
+			for range b.N {
+				r := codeResponse2{}
+				if _, err := Parse(test.input, &r, ZeroCopy); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalWideStruct(b *testing.B) {
+	fields := make([]reflect.StructField, 64)
+	for i := range fields {
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: reflect.TypeOf(0),
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"field_%d"`, i)),
+		}
+	}
+
+	typ := reflect.StructOf(fields)
+	val := reflect.New(typ)
+	input, err := Marshal(val.Interface())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for range b.N {
+		if _, err := Parse(input, val.Interface(), ZeroCopy); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCodecBytesFormats(t *testing.T) {
+	type payload struct {
+		Std    []byte  `json:"std,format:base64"`
+		URL    []byte  `json:"url,format:base64url"`
+		Raw    []byte  `json:"raw,format:base64raw"`
+		Hex    []byte  `json:"hex,format:hex"`
+		Array  []byte  `json:"array,format:array"`
+		PtrHex *[]byte `json:"ptr_hex,format:hex"`
+		Nil    []byte  `json:"nil,format:hex"`
+	}
+
+	data := []byte{0xfb, 0xff, 0x01}
+	in := payload{
+		Std:    data,
+		URL:    data,
+		Raw:    data,
+		Hex:    data,
+		Array:  data,
+		PtrHex: &data,
+	}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `{"std":"+/8B","url":"-_8B","raw":"+/8B","hex":"fbff01","array":[251,255,1],"ptr_hex":"fbff01","nil":null}`
+	if string(b) != expect {
+		t.Errorf("encoding mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+
+	var out payload
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", in, out)
+	}
+
+	// Padding is tolerated when decoding unpadded base64 formats, and the
+	// array format also accepts base64 strings.
+	if err := Unmarshal([]byte(`{"url":"-_8=","raw":"+/8=","array":"+/8B"}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.URL, data[:2]) || !bytes.Equal(out.Raw, data[:2]) || !bytes.Equal(out.Array, data) {
+		t.Errorf("wrong values decoded: %#v", out)
+	}
+
+	if err := Unmarshal([]byte(`{"hex":"xyz"}`), &out); err == nil {
+		t.Error("expected an error decoding invalid hexadecimal")
+	}
+}
+
+func TestCodecNonFiniteFloats(t *testing.T) {
+	type payload struct {
+		F32 float32 `json:"f32"`
+		F64 float64 `json:"f64"`
+		Neg float64 `json:"neg"`
+	}
+
+	in := payload{
+		F32: float32(math.NaN()),
+		F64: math.Inf(+1),
+		Neg: math.Inf(-1),
+	}
+
+	if _, err := Marshal(in); err == nil {
+		t.Error("expected an error encoding non-finite floats without flags")
+	}
+
+	b, err := Append(nil, in, NonFiniteFloatsAsNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"f32":null,"f64":null,"neg":null}` {
+		t.Errorf("wrong encoding with NonFiniteFloatsAsNull: %s", s)
+	}
+
+	b, err = Append(nil, in, NonFiniteFloatsAsString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = `{"f32":"NaN","f64":"Infinity","neg":"-Infinity"}`
+	if s := string(b); s != expect {
+		t.Errorf("wrong encoding with NonFiniteFloatsAsString: %s", s)
+	}
+
+	var out payload
+	if _, err := Parse(b, &out, 0); err == nil {
+		t.Error("expected an error decoding non-finite floats without flags")
+	}
+
+	out = payload{}
+	if _, err := Parse(b, &out, AllowNonFiniteFloats); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(float64(out.F32)) || !math.IsInf(out.F64, +1) || !math.IsInf(out.Neg, -1) {
+		t.Errorf("wrong values decoded: %#v", out)
+	}
+
+	if _, err := Parse([]byte(`{"f64":"Inf"}`), &out, AllowNonFiniteFloats); err == nil {
+		t.Error("expected an error decoding an invalid float string")
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetNonFiniteFloatsAsString(true)
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(buf)
+	dec.AllowNonFiniteFloats()
+	out = payload{}
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(out.F64, +1) {
+		t.Errorf("wrong value decoded from stream: %#v", out)
+	}
+
+	// The string option does not encode non-finite values a second time as
+	// strings.
+	type stringified struct {
+		F   float64  `json:"f,string"`
+		F32 float32  `json:"f32,string"`
+		P   *float64 `json:"p,string"`
+		X   float64  `json:"x,string"`
+	}
+
+	nan := math.NaN()
+	s := stringified{F: math.NaN(), F32: float32(math.Inf(-1)), P: &nan, X: 1.5}
+
+	for _, test := range []struct {
+		flags  AppendFlags
+		expect string
+	}{
+		{flags: NonFiniteFloatsAsNull, expect: `{"f":null,"f32":null,"p":null,"x":"1.5"}`},
+		{flags: NonFiniteFloatsAsString, expect: `{"f":"NaN","f32":"-Infinity","p":"NaN","x":"1.5"}`},
+	} {
+		b, err := Append(nil, s, test.flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("wrong encoding of string option with flags %v\nwant: %s\ngot:  %s", test.flags, test.expect, b)
+		}
+
+		var out stringified
+		if _, err := Parse(b, &out, AllowNonFiniteFloats); err != nil {
+			t.Fatal(err)
+		}
+		if out.X != 1.5 {
+			t.Errorf("wrong value decoded with flags %v: %#v", test.flags, out)
+		}
+		if test.flags == NonFiniteFloatsAsString && (!math.IsNaN(out.F) || !math.IsInf(float64(out.F32), -1) || out.P == nil || !math.IsNaN(*out.P)) {
+			t.Errorf("wrong non-finite values decoded: %#v", out)
+		}
+	}
+
+	if _, err := Marshal(s); err == nil {
+		t.Error("expected an error encoding non-finite floats with the string option without flags")
+	}
+	if _, err := Parse([]byte(`{"f":"NaN"}`), new(stringified), 0); err == nil {
+		t.Error("expected an error decoding non-finite floats with the string option without flags")
+	}
+}
+
+func TestCodecOrderedObject(t *testing.T) {
+	const input = `{"z":1,"a":{"y":[{"c":true,"b":null}],"x":"hello"},"m":2}`
+
+	var v any
+	if _, err := Parse([]byte(input), &v, UseOrderedObject); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, ok := v.(OrderedObject)
+	if !ok {
+		t.Fatalf("expected an ordered object but got %T", v)
+	}
+	if i := obj.Index("m"); i != 2 {
+		t.Errorf("wrong index of key \"m\": %d", i)
+	}
+	if i := obj.Index("nope"); i != -1 {
+		t.Errorf("wrong index of missing key: %d", i)
+	}
+	if a, _ := obj.Get("a"); reflect.TypeOf(a) != reflect.TypeOf(OrderedObject{}) {
+		t.Errorf("nested objects must also be ordered: %T", a)
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != input {
+		t.Errorf("key order was not preserved\nwant: %s\ngot:  %s", input, b)
+	}
+
+	// SortMapKeys does not apply to ordered objects.
+	b, err = Append(nil, v, SortMapKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != input {
+		t.Errorf("key order was not preserved with SortMapKeys\nwant: %s\ngot:  %s", input, b)
+	}
+
+	obj.Set("z", "first")
+	obj.Set("new", false)
+	if !obj.Delete("a") || obj.Delete("a") {
+		t.Error("wrong results deleting key \"a\"")
+	}
+	b, _ = Marshal(obj)
+	if s := string(b); s != `{"z":"first","m":2,"new":false}` {
+		t.Errorf("wrong encoding after updates: %s", s)
+	}
+
+	// Without the flag, decoding into an interface still produces maps, but
+	// ordered objects can be used as explicit destinations.
+	v = nil
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(map[string]any); !ok {
+		t.Errorf("expected a map but got %T", v)
+	}
+
+	var s struct {
+		Obj OrderedObject `json:"obj"`
+	}
+	if err := Unmarshal([]byte(`{"obj":{"b":1,"a":2,"b":3}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	expect := OrderedObject{{"b", 1.0}, {"a", 2.0}, {"b", 3.0}}
+	if !reflect.DeepEqual(s.Obj, expect) {
+		t.Errorf("wrong object decoded\nwant: %#v\ngot:  %#v", expect, s.Obj)
+	}
+
+	// Nested objects are ordered without the flag when the destination is an
+	// ordered object.
+	if err := Unmarshal([]byte(`{"obj":{"b":{"d":1,"c":[{"f":2,"e":3}]}}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	expect = OrderedObject{{"b", OrderedObject{
+		{"d", 1.0},
+		{"c", []any{OrderedObject{{"f", 2.0}, {"e", 3.0}}}},
+	}}}
+	if !reflect.DeepEqual(s.Obj, expect) {
+		t.Errorf("wrong nested objects decoded\nwant: %#v\ngot:  %#v", expect, s.Obj)
+	}
+
+	if err := Unmarshal([]byte(`{"obj":[]}`), &s); err == nil {
+		t.Error("expected an error decoding an array into an ordered object")
+	}
+}
+
+type testEvent interface{ eventName() string }
+
+type testClickEvent struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (*testClickEvent) eventName() string { return "click" }
+
+type testViewEvent struct {
+	Page string `json:"page"`
+}
+
+func (testViewEvent) eventName() string { return "view" }
+
+type testEmptyEvent struct{}
+
+func (testEmptyEvent) eventName() string { return "empty" }
+
+func init() {
+	RegisterDiscriminator(reflect.TypeOf((*testEvent)(nil)).Elem(), "type", map[string]reflect.Type{
+		"click": reflect.TypeOf(&testClickEvent{}),
+		"view":  reflect.TypeOf(testViewEvent{}),
+		"empty": reflect.TypeOf(testEmptyEvent{}),
+	})
+}
+
+func TestCodecDiscriminator(t *testing.T) {
+	type envelope struct {
+		ID      int         `json:"id"`
+		Payload testEvent   `json:"payload"`
+		Events  []testEvent `json:"events"`
+	}
+
+	in := envelope{
+		ID:      1,
+		Payload: &testClickEvent{X: 1, Y: 2},
+		Events: []testEvent{
+			testViewEvent{Page: "home"},
+			testEmptyEvent{},
+			nil,
+		},
+	}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `{"id":1,"payload":{"type":"click","x":1,"y":2},"events":[{"type":"view","page":"home"},{"type":"empty"},null]}`
+	if string(b) != expect {
+		t.Errorf("encoding mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+
+	var out envelope
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", in, out)
+	}
+
+	// The discriminator does not have to be the first field, and does not
+	// trip DisallowUnknownFields.
+	inputs := []struct {
+		json  string
+		flags ParseFlags
+	}{
+		{`{"x":3,"type":"click","y":4}`, DisallowUnknownFields},
+		{`{"x":3,"y":4,"type":"click"}`, DisallowUnknownFields},
+		{`{ "type" : "click" , "x":3,"y":4}`, DisallowUnknownFields},
+		{`{"nested":{"type":"view"},"x":3,"type":"click","y":4}`, 0},
+	}
+	for _, input := range inputs {
+		var e testEvent
+		if _, err := Parse([]byte(input.json), &e, input.flags); err != nil {
+			t.Errorf("%s: %v", input.json, err)
+			continue
+		}
+		if c, ok := e.(*testClickEvent); !ok || *c != (testClickEvent{X: 3, Y: 4}) {
+			t.Errorf("%s: wrong value decoded: %#v", input.json, e)
+		}
+	}
+
+	var e testEvent
+	if err := Unmarshal([]byte(`{"type":"scroll"}`), &e); err == nil {
+		t.Error("expected an error decoding an unknown discriminator")
+	}
+	if err := Unmarshal([]byte(`{"type":42}`), &e); err == nil {
+		t.Error("expected an error decoding a non-string discriminator")
+	}
+
+	err = Unmarshal([]byte(`{"payload":{"x":1}}`), &out)
+	if m, ok := err.(*MissingFieldError); !ok {
+		t.Errorf("expected a missing field error but got %v", err)
+	} else if m.Field != "payload" || !reflect.DeepEqual(m.Fields, []string{"type"}) {
+		t.Errorf("wrong missing field error: %v", m)
+	}
+
+	// Errors report the path of fields within the concrete values.
+	failures := []struct {
+		json  string
+		field string
+	}{
+		{`{"payload":{"type":"click","x":"1"}}`, "payload.x"},
+		{`{"events":[{"type":"view"},{"page":true,"type":"view"}]}`, "events.1.page"},
+		{`{"payload":{"type":"scroll"}}`, "payload.type"},
+	}
+	for _, test := range failures {
+		err := Unmarshal([]byte(test.json), &out)
+		if e, ok := err.(*UnmarshalTypeError); !ok {
+			t.Errorf("%s: expected an unmarshal type error but got %v", test.json, err)
+		} else if e.Field != test.field {
+			t.Errorf("%s: wrong field path: want=%q got=%q", test.json, test.field, e.Field)
+		}
+	}
+
+	if _, err := Parse([]byte(`{"type":"click","z":1}`), &e, DisallowUnknownFields); err == nil {
+		t.Error("expected an error decoding an unknown field")
+	}
+}
+
+func TestCodecDiscriminatorEnvelope(t *testing.T) {
+	type envelope struct {
+		ID      int       `json:"id"`
+		Payload testEvent `json:"payload,discriminator:type"`
+	}
+
+	tests := []struct {
+		value envelope
+		json  string
+	}{
+		{envelope{ID: 1, Payload: &testClickEvent{X: 1, Y: 2}}, `{"id":1,"type":"click","payload":{"x":1,"y":2}}`},
+		{envelope{ID: 2, Payload: testViewEvent{Page: "home"}}, `{"id":2,"type":"view","payload":{"page":"home"}}`},
+		{envelope{ID: 3}, `{"id":3,"payload":null}`},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.json {
+			t.Errorf("encoding mismatch\nwant: %s\ngot:  %s", test.json, b)
+		}
+
+		var v envelope
+		if _, err := Parse(b, &v, DisallowUnknownFields); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, test.value) {
+			t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", test.value, v)
+		}
+	}
+
+	// The discriminator may appear after the payload.
+	var v envelope
+	if _, err := Parse([]byte(`{"payload":{"x":3,"y":4},"id":4,"type":"click"}`), &v, DisallowUnknownFields); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := v.Payload.(*testClickEvent); !ok || *c != (testClickEvent{X: 3, Y: 4}) || v.ID != 4 {
+		t.Errorf("wrong value decoded: %#v", v)
+	}
+
+	failures := []struct {
+		json  string
+		field string
+	}{
+		{`{"type":"click","payload":{"x":"1"}}`, "payload.x"},
+		{`{"payload":{"x":"1"},"type":"click"}`, "payload.x"},
+		{`{"type":"scroll","payload":{}}`, "type"},
+		{`{"type":1,"payload":{}}`, "type"},
+	}
+	for _, test := range failures {
+		err := Unmarshal([]byte(test.json), &v)
+		if e, ok := err.(*UnmarshalTypeError); !ok {
+			t.Errorf("%s: expected an unmarshal type error but got %v", test.json, err)
+		} else if e.Field != test.field {
+			t.Errorf("%s: wrong field path: want=%q got=%q", test.json, test.field, e.Field)
+		}
+	}
+
+	err := Unmarshal([]byte(`{"id":5,"payload":{"x":1}}`), &v)
+	if m, ok := err.(*MissingFieldError); !ok {
+		t.Errorf("expected a missing field error but got %v", err)
+	} else if !reflect.DeepEqual(m.Fields, []string{"type"}) {
+		t.Errorf("wrong missing field error: %v", m)
+	}
+
+	// When the struct has a field for the key, it is encoded and decoded like
+	// other fields.
+	type typedEnvelope struct {
+		Type    string    `json:"type"`
+		Payload testEvent `json:"payload,discriminator:type"`
+	}
+
+	var tv typedEnvelope
+	if err := Unmarshal([]byte(`{"payload":{"page":"home"},"type":"view"}`), &tv); err != nil {
+		t.Fatal(err)
+	}
+	if tv.Type != "view" || tv.Payload != (testViewEvent{Page: "home"}) {
+		t.Errorf("wrong value decoded: %#v", tv)
+	}
+	if b, err := Marshal(tv); err != nil {
+		t.Fatal(err)
+	} else if expect := `{"type":"view","payload":{"page":"home"}}`; string(b) != expect {
+		t.Errorf("encoding mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+
+	// The option requires an interface registered with RegisterDiscriminator.
+	var invalid struct {
+		Payload fmt.Stringer `json:"payload,discriminator:type"`
+	}
+	if _, err := Marshal(invalid); err == nil {
+		t.Error("expected an error encoding a field of unregistered type")
+	}
+	if err := Unmarshal([]byte(`{"payload":null}`), &invalid); err == nil {
+		t.Error("expected an error decoding a field of unregistered type")
+	}
+}
+
+// testPoint implements both the standard and streaming marshaler interfaces,
+// the latter must be preferred by the codec.
+type testPoint struct{ X, Y int64 }
+
+func (p testPoint) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("MarshalJSON must not be called")
+}
+
+func (p testPoint) AppendJSON(b []byte, _ AppendFlags) ([]byte, error) {
+	b = append(b, '[')
+	b = strconv.AppendInt(b, p.X, 10)
+	b = append(b, ',')
+	b = strconv.AppendInt(b, p.Y, 10)
+	return append(b, ']'), nil
+}
+
+func (p *testPoint) UnmarshalJSON([]byte) error {
+	return errors.New("UnmarshalJSON must not be called")
+}
+
+func (p *testPoint) UnmarshalJSONFrom(t *Tokenizer) error {
+	var coords [2]*int64
+	coords[0], coords[1] = &p.X, &p.Y
+
+	for t.Next() {
+		if t.Kind().Class() == Num {
+			if t.Index >= len(coords) {
+				return fmt.Errorf("too many coordinates")
+			}
+			*coords[t.Index] = t.Int()
+		}
+	}
+	return nil
+}
+
+// testPartialUnmarshalerFrom only reads the first token of the values it is
+// decoded from.
+type testPartialUnmarshalerFrom struct{ first RawValue }
+
+func (p *testPartialUnmarshalerFrom) UnmarshalJSONFrom(t *Tokenizer) error {
+	if t.Next() {
+		p.first = append(p.first[:0], t.Value...)
+	}
+	return nil
+}
+
+type testBrokenMarshalerTo struct{}
+
+func (testBrokenMarshalerTo) AppendJSON(b []byte, _ AppendFlags) ([]byte, error) {
+	return append(b, `{"a":`...), nil
+}
+
+func TestCodecMarshalerToUnmarshalerFrom(t *testing.T) {
+	type shape struct {
+		Points []testPoint `json:"points"`
+		Origin *testPoint  `json:"origin"`
+	}
+
+	in := shape{
+		Points: []testPoint{{1, 2}, {-3, 4}},
+		Origin: &testPoint{0, 0},
+	}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"points":[[1,2],[-3,4]],"origin":[0,0]}` {
+		t.Errorf("wrong encoding: %s", s)
+	}
+
+	var out shape
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("values mismatch\nwant: %#v\ngot:  %#v", in, out)
+	}
+
+	if err := Unmarshal([]byte(`{"origin":[1,2,3]}`), &out); err == nil {
+		t.Error("expected an error returned by UnmarshalJSONFrom")
+	}
+
+	var partial testPartialUnmarshalerFrom
+	if err := Unmarshal([]byte(`"a"`), &partial); err != nil || string(partial.first) != `"a"` {
+		t.Errorf("wrong value decoded from a scalar: %s (%v)", partial.first, err)
+	}
+	for _, input := range []string{`[1,2]`, `{"a":1}`, `[]`} {
+		if err := Unmarshal([]byte(input), &partial); err == nil {
+			t.Errorf("%s: expected an error when UnmarshalJSONFrom does not read the entire value", input)
+		}
+	}
+
+	if _, err := Marshal(testBrokenMarshalerTo{}); err == nil {
+		t.Error("expected an error encoding invalid output of AppendJSON")
+	} else if _, ok := err.(*MarshalerError); !ok {
+		t.Errorf("expected a marshaler error but got %T", err)
+	}
+
+	if b, err := Append(nil, testBrokenMarshalerTo{}, TrustRawMessage); err != nil {
+		t.Error(err)
+	} else if string(b) != `{"a":` {
+		t.Errorf("the output of AppendJSON must be trusted: %s", b)
+	}
+
+	p := testPoint{X: 1, Y: 2}
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = Append(buf[:0], &p, 0)
+	})
+	if allocs != 0 {
+		t.Errorf("encoding a MarshalerTo allocated %g times", allocs)
+	}
+
+	points := []testPoint{{1, 2}, {3, 4}}
+	allocs = testing.AllocsPerRun(100, func() {
+		buf, _ = Append(buf[:0], &points, 0)
+	})
+	if allocs != 0 {
+		t.Errorf("encoding a slice of MarshalerTo values allocated %g times", allocs)
+	}
+}
+
+// testMarshalerPoint is the same as testPoint, but only implements the
+// standard Marshaler interface.
+type testMarshalerPoint struct{ X, Y int64 }
+
+func (p testMarshalerPoint) MarshalJSON() ([]byte, error) {
+	return testPoint(p).AppendJSON(nil, 0)
+}
+
+func BenchmarkMarshalerTo(b *testing.B) {
+	points := make([]testPoint, 1000)
+	marshalers := make([]testMarshalerPoint, len(points))
+	for i := range points {
+		points[i] = testPoint{X: int64(i), Y: -int64(i)}
+		marshalers[i] = testMarshalerPoint(points[i])
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		flags AppendFlags
+	}{
+		{"Marshaler", marshalers, 0},
+		{"MarshalerTo", points, 0},
+		{"MarshalerTo+TrustRawMessage", points, TrustRawMessage},
+	}
+
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			buf, _ := Append(nil, test.value, test.flags)
+			b.SetBytes(int64(len(buf)))
+			b.ReportAllocs()
+			for range b.N {
+				buf, _ = Append(buf[:0], test.value, test.flags)
+			}
+		})
+	}
+}
+
+func TestCodecMapSizes(t *testing.T) {
+	// The sizes cross the thresholds where the runtime grows maps, including
+	// the split of swiss tables once they exceed 1024 entries.
+	for _, n := range []int{0, 1, 7, 8, 9, 100, 1024, 1025, 5000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			m := make(map[string]int, n)
+			for i := 0; i < n; i++ {
+				m[strconv.Itoa(i)] = i
+			}
+
+			b, err := Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, want) {
+				t.Fatalf("output mismatch\nwant: %.100s...\ngot:  %.100s...", want, b)
+			}
+
+			var got map[string]int
+			if err := Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, m) {
+				t.Fatalf("value mismatch after decoding %d entries", n)
+			}
+		})
+	}
+}
+
+func TestCodecSeq(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	values := func(n int) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		scenario string
+		value    any
+		expect   string
+	}{
+		{
+			scenario: "nil sequence",
+			value:    iter.Seq[int](nil),
+			expect:   `null`,
+		},
+		{
+			scenario: "empty sequence",
+			value:    values(0),
+			expect:   `[]`,
+		},
+		{
+			scenario: "sequence of integers",
+			value:    values(3),
+			expect:   `[0,1,2]`,
+		},
+		{
+			scenario: "sequence of pointers",
+			value: iter.Seq[*point](func(yield func(*point) bool) {
+				_ = yield(&point{X: 1, Y: 2}) && yield(nil)
+			}),
+			expect: `[{"x":1,"y":2},null]`,
+		},
+		{
+			scenario: "sequence of key/value pairs",
+			value:    maps.All(map[string]int{"a": 1}),
+			expect:   `{"a":1}`,
+		},
+		{
+			scenario: "sequence of integer keys",
+			value: iter.Seq2[int, []string](func(yield func(int, []string) bool) {
+				_ = yield(2, []string{"b"}) && yield(1, nil)
+			}),
+			expect: `{"2":["b"],"1":null}`,
+		},
+		{
+			scenario: "sequence field",
+			value: struct {
+				Seq iter.Seq[int] `json:"seq"`
+			}{Seq: values(2)},
+			expect: `{"seq":[0,1]}`,
+		},
+		{
+			scenario: "unnamed iterator type",
+			value:    (func(func(string) bool))(slices.Values([]string{"a", "b"})),
+			expect:   `["a","b"]`,
+		},
+		{
+			scenario: "sequence of pointer-shaped arrays",
+			value: iter.Seq[[1]*int](func(yield func([1]*int) bool) {
+				one := 1
+				_ = yield([1]*int{&one}) && yield([1]*int{nil})
+			}),
+			expect: `[[1],[null]]`,
+		},
+		{
+			scenario: "sequence of pointer-shaped structs",
+			value: iter.Seq[struct{ p *int }](func(yield func(struct{ p *int }) bool) {
+				one := 1
+				_ = yield(struct{ p *int }{&one}) && yield(struct{ p *int }{})
+			}),
+			expect: `[{},{}]`,
+		},
+		{
+			scenario: "sequence of pointer-shaped structs with exported fields",
+			value: iter.Seq[struct{ P *int }](func(yield func(struct{ P *int }) bool) {
+				one := 1
+				_ = yield(struct{ P *int }{&one}) && yield(struct{ P *int }{})
+			}),
+			expect: `[{"P":1},{"P":null}]`,
+		},
+		{
+			scenario: "sequence of pointer-shaped keys and values",
+			value: iter.Seq2[string, struct{ P [1]*int }](func(yield func(string, struct{ P [1]*int }) bool) {
+				one := 1
+				yield("a", struct{ P [1]*int }{[1]*int{&one}})
+			}),
+			expect: `{"a":{"P":[1]}}`,
+		},
+		{
+			scenario: "pointer-shaped array",
+			value:    map[string][1]*int{"a": {new(int)}},
+			expect:   `{"a":[0]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			b, err := Marshal(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.expect {
+				t.Errorf("output mismatch\nwant: %s\ngot:  %s", test.expect, b)
+			}
+		})
+	}
+}
+
+func TestCodecSeqUnsupported(t *testing.T) {
+	for _, v := range []any{
+		func() {},
+		func(yield func(int) int) {},
+		func(yield func(int, int, int) bool) {},
+		iter.Seq2[[]byte, int](func(yield func([]byte, int) bool) {}),
+	} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("expected error marshaling %T", v)
+		}
+	}
+
+	var seq iter.Seq[int]
+	if err := Unmarshal([]byte(`[1,2,3]`), &seq); err == nil {
+		t.Error("expected error unmarshaling a sequence")
+	}
+}
+
+func TestCodecSeqError(t *testing.T) {
+	stopped := false
+	seq := func(yield func(float64) bool) {
+		if yield(1) && yield(math.NaN()) {
+			yield(2)
+		} else {
+			stopped = true
+		}
+	}
+
+	b, err := Append([]byte("prefix"), iter.Seq[float64](seq), 0)
+	if err == nil {
+		t.Fatal("expected an error encoding NaN")
+	}
+	if string(b) != "prefix" {
+		t.Errorf("output not rolled back: %q", b)
+	}
+	if !stopped {
+		t.Error("iteration was not stopped after the error")
+	}
+}
+
+type writeRecorder struct {
+	writes []int
+	buffer bytes.Buffer
+}
+
+func (w *writeRecorder) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, len(b))
+	return w.buffer.Write(b)
+}
+
+func TestEncoderSeqFlush(t *testing.T) {
+	const n = 10000
+	value := struct {
+		Items iter.Seq2[int, string] `json:"items"`
+	}{
+		Items: func(yield func(int, string) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(i, "value") {
+					return
+				}
+			}
+		},
+	}
+
+	w := new(writeRecorder)
+	if err := NewEncoder(w).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.writes) < 2 {
+		t.Fatalf("output was not written incrementally: %d writes", len(w.writes))
+	}
+	for _, size := range w.writes {
+		if size > 2*flushThreshold {
+			t.Errorf("write of %d bytes exceeds the flush threshold", size)
+		}
+	}
+
+	var got struct {
+		Items map[int]string `json:"items"`
+	}
+	if err := Unmarshal(w.buffer.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != n {
+		t.Errorf("wrong number of items: want %d, got %d", n, len(got.Items))
+	}
+
+	// Indented output is buffered and written at once.
+	w = new(writeRecorder)
+	enc := NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 1 {
+		t.Errorf("indented output was written in %d writes", len(w.writes))
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, io.ErrClosedPipe
+	}
+	w.n--
+	return len(b), nil
+}
+
+func TestEncoderSeqWriteError(t *testing.T) {
+	calls := 0
+	seq := iter.Seq[string](func(yield func(string) bool) {
+		for calls = 0; calls < 100000; calls++ {
+			if !yield("hello world") {
+				return
+			}
+		}
+	})
+
+	enc := NewEncoder(&failingWriter{n: 1})
+	if err := enc.Encode(seq); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if calls == 100000 {
+		t.Error("iteration was not stopped after the write error")
+	}
+	if err := enc.Encode(1); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected the encoder to keep failing, got %v", err)
+	}
+}
+
+func TestEncoderSeqErrorAfterFlush(t *testing.T) {
+	seq := iter.Seq[float64](func(yield func(float64) bool) {
+		for i := range 10000 {
+			if !yield(float64(i)) {
+				return
+			}
+		}
+		yield(math.NaN())
+	})
+
+	w := new(writeRecorder)
+	enc := NewEncoder(w)
+	err := enc.Encode(seq)
+	if err == nil {
+		t.Fatal("expected an error encoding NaN")
+	}
+	if len(w.writes) == 0 {
+		t.Fatal("output was not flushed before the error")
+	}
+
+	n := w.buffer.Len()
+	if err2 := enc.Encode(1); err2 != err {
+		t.Errorf("expected the encoder to keep failing with %v, got %v", err, err2)
+	}
+	if w.buffer.Len() != n {
+		t.Errorf("output written after the truncated value: %q", w.buffer.Bytes()[n:])
+	}
+
+	// Errors which occur before any output is written do not fail the next
+	// calls.
+	enc = NewEncoder(w)
+	if err := enc.Encode(math.NaN()); err == nil {
+		t.Fatal("expected an error encoding NaN")
+	}
+	if err := enc.Encode(1); err != nil {
+		t.Errorf("expected the encoder to recover, got %v", err)
+	}
+}
+
+func TestEncoderFlushThreshold(t *testing.T) {
+	type item struct {
+		ID   int               `json:"id"`
+		Name string            `json:"name"`
+		Tags map[string]string `json:"tags"`
+		Any  any               `json:"any"`
+	}
+
+	items := make([]item, 1000)
+	for i := range items {
+		items[i] = item{
+			ID:   i,
+			Name: strings.Repeat("x", i%50),
+			Tags: map[string]string{"a": "1", "b": "2"},
+			Any:  map[string]any{"list": []any{1, "2", 3.5}},
+		}
+	}
+
+	values := []any{
+		items,
+		map[string]item{"a": items[0], "b": items[1]},
+		map[string][]item{"items": items},
+		map[string]any{"items": items},
+		OrderedObject{{Key: "items", Value: items}},
+	}
+
+	for _, threshold := range []int{1, 100, 4096} {
+		for _, v := range values {
+			t.Run(fmt.Sprintf("%d/%T", threshold, v), func(t *testing.T) {
+				w := new(writeRecorder)
+				enc := NewEncoder(w)
+				enc.SetFlushThreshold(threshold)
+
+				if err := enc.Encode(v); err != nil {
+					t.Fatal(err)
+				}
+
+				want, err := Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, '\n')
+
+				if !bytes.Equal(w.buffer.Bytes(), want) {
+					t.Fatalf("output mismatch\nwant: %.100s...\ngot:  %.100s...", want, w.buffer.Bytes())
+				}
+				if len(w.writes) < 2 && len(want) > 2*threshold {
+					t.Errorf("output of %d bytes was written in %d writes", len(want), len(w.writes))
+				}
+			})
+		}
+	}
+}
+
+func TestEncoderFlushThresholdError(t *testing.T) {
+	values := make([]float64, 1000)
+	values[len(values)-1] = math.Inf(1)
+
+	for _, v := range []any{
+		values,
+		map[string]any{"values": values},
+		OrderedObject{{Key: "a", Value: values[:10]}, {Key: "b", Value: values}},
+	} {
+		t.Run(fmt.Sprintf("%T", v), func(t *testing.T) {
+			w := new(writeRecorder)
+			enc := NewEncoder(w)
+			enc.SetFlushThreshold(100)
+
+			err := enc.Encode(v)
+			if err == nil {
+				t.Fatal("expected an error encoding +Inf")
+			}
+			if len(w.writes) == 0 {
+				t.Fatal("output was not flushed before the error")
+			}
+
+			n := w.buffer.Len()
+			if err2 := enc.Encode(1); err2 != err {
+				t.Errorf("expected the encoder to keep failing with %v, got %v", err, err2)
+			}
+			if w.buffer.Len() != n {
+				t.Errorf("output written after the truncated value: %q", w.buffer.Bytes()[n:])
+			}
+		})
+	}
+}
+
+func TestEncoderFlushThresholdAllocs(t *testing.T) {
+	value := make([]int, 100000)
+	for i := range value {
+		value[i] = i
+	}
+
+	enc := NewEncoder(io.Discard)
+	enc.SetFlushThreshold(1024)
+	enc.Encode(&value) // warm up the codec cache and buffer pool
+
+	w := new(writeRecorder)
+	enc = NewEncoder(w)
+	enc.SetFlushThreshold(1024)
+	if err := enc.Encode(&value); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range w.writes {
+		if size > 1024+32 {
+			t.Errorf("write of %d bytes exceeds the flush threshold", size)
+		}
+	}
+
+	enc = NewEncoder(io.Discard)
+	enc.SetFlushThreshold(1024)
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := enc.Encode(&value); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("encoding allocated %v times", allocs)
+	}
+}
+
+func TestEncoderFlushThresholdWriteError(t *testing.T) {
+	value := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		value[strconv.Itoa(i)] = "hello world"
+	}
+
+	for _, sort := range []bool{false, true} {
+		enc := NewEncoder(&failingWriter{n: 1})
+		enc.SetSortMapKeys(sort)
+		enc.SetFlushThreshold(100)
+
+		if err := enc.Encode(value); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the write error, got %v", err)
+		}
+	}
+}
+
+func TestDecodeMergeFlags(t *testing.T) {
+	type inner struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}
+
+	type config struct {
+		Name   string            `json:"name"`
+		Inner  inner             `json:"inner"`
+		Items  map[string]inner  `json:"items"`
+		Tags   []string          `json:"tags"`
+		Points []inner           `json:"points"`
+		Labels map[string]string `json:"labels"`
+		Extra  map[string]any    `json:"extra"`
+	}
+
+	base := func() *config {
+		return &config{
+			Name:   "base",
+			Inner:  inner{A: 1, B: 2},
+			Items:  map[string]inner{"x": {A: 1, B: 2}},
+			Tags:   []string{"a", "b", "c"},
+			Points: []inner{{A: 1, B: 1}, {A: 2, B: 2}},
+			Labels: map[string]string{"env": "prod", "team": "core"},
+			Extra:  map[string]any{"nested": map[string]any{"k1": "v1"}, "list": []any{"a"}},
+		}
+	}
+
+	input := []byte(`{
+		"inner": {"b": 3},
+		"items": {"x": {"b": 3}, "y": {"a": 4}},
+		"tags": ["d"],
+		"points": [{"b": 5}],
+		"labels": {"team": "infra"},
+		"extra": {"nested": {"k2": "v2"}, "list": ["b"]}
+	}`)
+
+	tests := []struct {
+		scenario string
+		flags    ParseFlags
+		expect   *config
+	}{
+		{
+			scenario: "default",
+			flags:    0,
+			expect: &config{
+				Name:   "base",
+				Inner:  inner{A: 1, B: 3},
+				Items:  map[string]inner{"x": {B: 3}, "y": {A: 4}},
+				Tags:   []string{"d"},
+				Points: []inner{{A: 1, B: 5}},
+				Labels: map[string]string{"env": "prod", "team": "infra"},
+				Extra:  map[string]any{"nested": map[string]any{"k2": "v2"}, "list": []any{"b"}},
+			},
+		},
+		{
+			scenario: "merge objects",
+			flags:    MergeObjects,
+			expect: &config{
+				Name:   "base",
+				Inner:  inner{A: 1, B: 3},
+				Items:  map[string]inner{"x": {A: 1, B: 3}, "y": {A: 4}},
+				Tags:   []string{"d"},
+				Points: []inner{{A: 1, B: 5}},
+				Labels: map[string]string{"env": "prod", "team": "infra"},
+				Extra:  map[string]any{"nested": map[string]any{"k1": "v1", "k2": "v2"}, "list": []any{"b"}},
+			},
+		},
+		{
+			scenario: "append slices",
+			flags:    AppendSlices,
+			expect: &config{
+				Name:   "base",
+				Inner:  inner{A: 1, B: 3},
+				Items:  map[string]inner{"x": {B: 3}, "y": {A: 4}},
+				Tags:   []string{"a", "b", "c", "d"},
+				Points: []inner{{A: 1, B: 1}, {A: 2, B: 2}, {B: 5}},
+				Labels: map[string]string{"env": "prod", "team": "infra"},
+				Extra:  map[string]any{"nested": map[string]any{"k2": "v2"}, "list": []any{"b"}},
+			},
+		},
+		{
+			scenario: "merge slices",
+			flags:    MergeSlices | MergeObjects,
+			expect: &config{
+				Name:   "base",
+				Inner:  inner{A: 1, B: 3},
+				Items:  map[string]inner{"x": {A: 1, B: 3}, "y": {A: 4}},
+				Tags:   []string{"d", "b", "c"},
+				Points: []inner{{A: 1, B: 5}, {A: 2, B: 2}},
+				Labels: map[string]string{"env": "prod", "team": "infra"},
+				Extra:  map[string]any{"nested": map[string]any{"k1": "v1", "k2": "v2"}, "list": []any{"b"}},
+			},
+		},
+		{
+			scenario: "reuse containers",
+			flags:    ReuseContainers,
+			expect: &config{
+				Name:   "base",
+				Inner:  inner{A: 1, B: 3},
+				Items:  map[string]inner{"x": {B: 3}, "y": {A: 4}},
+				Tags:   []string{"d"},
+				Points: []inner{{A: 1, B: 5}},
+				Labels: map[string]string{"team": "infra"},
+				Extra:  map[string]any{"nested": map[string]any{"k2": "v2"}, "list": []any{"b"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			v := base()
+			if _, err := Parse(input, v, test.flags); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, test.expect) {
+				t.Errorf("value mismatch\nwant: %+v\ngot:  %+v", test.expect, v)
+			}
+		})
+	}
+}
+
+func TestDecodeMergeMapStringStringSlice(t *testing.T) {
+	m := map[string][]string{"a": {"1"}, "b": {"2"}}
+
+	if _, err := Parse([]byte(`{"a":["3"],"c":["4"]}`), &m, MergeObjects|AppendSlices); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string][]string{"a": {"1", "3"}, "b": {"2"}, "c": {"4"}}
+	if !reflect.DeepEqual(m, expect) {
+		t.Errorf("value mismatch\nwant: %v\ngot:  %v", expect, m)
+	}
+}
+
+func TestDecodeReuseContainers(t *testing.T) {
+	type message struct {
+		Data   []byte         `json:"data"`
+		Values []int          `json:"values"`
+		Attrs  map[string]int `json:"attrs"`
+		Any    any            `json:"any"`
+	}
+
+	input := []byte(`{"data":"aGVsbG8=","values":[1,2,3],"attrs":{"a":1,"b":2},"any":{"list":[1,2]}}`)
+
+	var m message
+	if _, err := Parse(input, &m, ReuseContainers); err != nil {
+		t.Fatal(err)
+	}
+
+	data, values, attrs := &m.Data[0], &m.Values[0], m.Attrs
+	m.Attrs["stale"] = 0
+
+	if _, err := Parse(input, &m, ReuseContainers); err != nil {
+		t.Fatal(err)
+	}
+
+	if &m.Data[0] != data || &m.Values[0] != values || reflect.ValueOf(m.Attrs).Pointer() != reflect.ValueOf(attrs).Pointer() {
+		t.Error("containers were not reused")
+	}
+	if _, ok := m.Attrs["stale"]; ok {
+		t.Error("map was not cleared")
+	}
+	if string(m.Data) != "hello" {
+		t.Errorf("wrong data: %q", m.Data)
+	}
+
+	type hot struct {
+		Data   []byte            `json:"data"`
+		Values []int             `json:"values"`
+		Labels map[string]string `json:"labels"`
+	}
+
+	input = []byte(`{"data":"aGVsbG8=","values":[1,2,3],"labels":{"first":"hello","second":"world"}}`)
+
+	var h hot
+	if _, err := Parse(input, &h, ReuseContainers|ZeroCopy); err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := Parse(input, &h, ReuseContainers|ZeroCopy); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("decoding allocated %v times", allocs)
+	}
+
+	// Byte slices decoded with a format option reuse their capacity as well.
+	type formatted struct {
+		Hex []byte `json:"hex,format:hex"`
+		URL []byte `json:"url,format:base64url"`
+	}
+
+	input = []byte(`{"hex":"68656c6c6f","url":"aGVsbG8"}`)
+
+	var f formatted
+	if _, err := Parse(input, &f, ReuseContainers); err != nil {
+		t.Fatal(err)
+	}
+
+	hexData, urlData := &f.Hex[0], &f.URL[0]
+
+	if _, err := Parse(input, &f, ReuseContainers); err != nil {
+		t.Fatal(err)
+	}
+	if &f.Hex[0] != hexData || &f.URL[0] != urlData {
+		t.Error("byte slices with a format were not reused")
+	}
+	if string(f.Hex) != "hello" || string(f.URL) != "hello" {
+		t.Errorf("wrong data: %q %q", f.Hex, f.URL)
+	}
+}
+
+type testRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+var errInvalidRange = errors.New("min is greater than max")
+
+func (r testRange) Validate() error {
+	if r.Min > r.Max {
+		return errInvalidRange
+	}
+	return nil
+}
+
+type testLabel struct {
+	Name string `json:"name"`
+}
+
+func (n *testLabel) Validate() error {
+	if n.Name == "" {
+		return errors.New("missing name")
+	}
+	return nil
+}
+
+func TestDecodeValidator(t *testing.T) {
+	type container struct {
+		Name   testLabel            `json:"name"`
+		Range  *testRange           `json:"range"`
+		Ranges []testRange          `json:"ranges"`
+		Named  map[string]testRange `json:"named"`
+		Fixed  [2]testRange         `json:"fixed"`
+	}
+
+	tests := []struct {
+		input string
+		field string
+		typ   reflect.Type
+	}{
+		{input: `{"min":2,"max":1}`, field: "", typ: reflect.TypeOf(testRange{})},
+		{input: `{"name":{"name":""}}`, field: "name", typ: reflect.TypeOf(testLabel{})},
+		{input: `{"name":{"name":"A"},"range":{"min":2,"max":1}}`, field: "range", typ: reflect.TypeOf(testRange{})},
+		{input: `{"name":{"name":"A"},"ranges":[{"min":0,"max":1},{"min":2,"max":1}]}`, field: "ranges.1", typ: reflect.TypeOf(testRange{})},
+		{input: `{"name":{"name":"A"},"named":{"a":{"min":2,"max":1}}}`, field: "named.a", typ: reflect.TypeOf(testRange{})},
+		{input: `{"name":{"name":"A"},"fixed":[{"min":2,"max":1}]}`, field: "fixed.0", typ: reflect.TypeOf(testRange{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var err error
+			if test.field == "" {
+				var r testRange
+				err = Unmarshal([]byte(test.input), &r)
+			} else {
+				var c container
+				err = Unmarshal([]byte(test.input), &c)
+			}
+
+			var e *ValidationError
+			if !errors.As(err, &e) {
+				t.Fatalf("expected a validation error but got %v", err)
+			}
+			if e.Field != test.field {
+				t.Errorf("field mismatch: want %q, got %q", test.field, e.Field)
+			}
+			if e.Type != test.typ {
+				t.Errorf("type mismatch: want %s, got %s", test.typ, e.Type)
+			}
+		})
+	}
+
+	var c container
+	if err := Unmarshal([]byte(`{"name":{"name":"A"},"range":null,"ranges":[{"min":0,"max":1}]}`), &c); err != nil {
+		t.Error(err)
+	}
+
+	var r testRange
+	if err := Unmarshal([]byte(`{"min":2,"max":1}`), &r); !errors.Is(err, errInvalidRange) {
+		t.Errorf("expected the error returned by Validate to be wrapped but got %v", err)
+	}
+}
+
+func TestDecodeStructAliases(t *testing.T) {
+	type account struct {
+		Name  string `json:"name,alias:username,alias:'user_name'"`
+		Email string `json:"email,required,alias:mail"`
+		Phone string `json:"phone,alias:email"`
+		Home  string `json:"home,alias:address"`
+		Work  string `json:"work,alias:address"`
+	}
+
+	tests := []struct {
+		input  string
+		expect account
+	}{
+		{input: `{"name":"A","email":"a@b"}`, expect: account{Name: "A", Email: "a@b"}},
+		{input: `{"username":"A","mail":"a@b"}`, expect: account{Name: "A", Email: "a@b"}},
+		{input: `{"user_name":"A","MAIL":"a@b"}`, expect: account{Name: "A", Email: "a@b"}},
+		// Aliases never take precedence over field names.
+		{input: `{"email":"a@b","phone":"123"}`, expect: account{Email: "a@b", Phone: "123"}},
+		// Aliases declared by multiple fields are ignored.
+		{input: `{"email":"a@b","address":"here"}`, expect: account{Email: "a@b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var a account
+			if err := Unmarshal([]byte(test.input), &a); err != nil {
+				t.Fatal(err)
+			}
+			if a != test.expect {
+				t.Errorf("value mismatch\nwant: %+v\ngot:  %+v", test.expect, a)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte(`{"mail":"a@b","address":"here"}`), new(account), DisallowUnknownFields); err == nil || err.Error() != `json: unknown field "address"` {
+		t.Errorf("expected an unknown field error but got %v", err)
+	}
+
+	b, err := Marshal(account{Name: "A", Email: "a@b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"name":"A","email":"a@b","phone":"","home":"","work":""}`; string(b) != expect {
+		t.Errorf("output mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+}
+
+func TestEncodeEmbeddedStructPointerOmitEmpty(t *testing.T) {
+	type inner struct {
+		X int    `json:"x,omitempty"`
+		Y string `json:"y,omitempty"`
+	}
+	type outer struct {
+		*inner
+		Z int `json:"z"`
+	}
+
+	tests := []struct {
+		value  outer
+		expect string
+	}{
+		{value: outer{}, expect: `{"z":0}`},
+		{value: outer{inner: &inner{}}, expect: `{"z":0}`},
+		{value: outer{inner: &inner{X: 1}}, expect: `{"x":1,"z":0}`},
+		{value: outer{inner: &inner{Y: "A"}, Z: 2}, expect: `{"y":"A","z":2}`},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("output mismatch\nwant: %s\ngot:  %s", test.expect, b)
+		}
+	}
+}
+
+func TestEncodeNilAsEmpty(t *testing.T) {
+	type value struct {
+		Slice   []int             `json:"slice"`
+		Bytes   []byte            `json:"bytes"`
+		Hex     []byte            `json:"hex,format:hex"`
+		Map     map[string]int    `json:"map"`
+		Strings map[string]string `json:"strings"`
+		Any     map[string]any    `json:"any"`
+		Object  OrderedObject     `json:"object"`
+		Ptr     *[]int            `json:"ptr"`
+		Empty   []int             `json:"empty,omitempty"`
+	}
+
+	tests := []struct {
+		flags  AppendFlags
+		expect string
+	}{
+		{
+			expect: `{"slice":null,"bytes":null,"hex":null,"map":null,"strings":null,"any":null,"object":null,"ptr":null}`,
+		},
+		{
+			flags:  NilSlicesAsEmpty,
+			expect: `{"slice":[],"bytes":"","hex":"","map":null,"strings":null,"any":null,"object":null,"ptr":null}`,
+		},
+		{
+			flags:  NilMapsAsEmpty,
+			expect: `{"slice":null,"bytes":null,"hex":null,"map":{},"strings":{},"any":{},"object":{},"ptr":null}`,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := Append(nil, value{}, test.flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("output mismatch with flags %v\nwant: %s\ngot:  %s", test.flags, test.expect, b)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetNilSlicesAsEmpty(true)
+	enc.SetNilMapsAsEmpty(true)
+	if err := enc.Encode([]any{[]string(nil), map[int]bool(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "[[],{}]\n" {
+		t.Errorf("wrong output: %q", s)
+	}
+}
+
+func TestDecodeRejectDuplicateNames(t *testing.T) {
+	tests := []struct {
+		input string
+		value any
+		dup   bool
+	}{
+		{input: `{"a":1,"b":2}`, value: new(map[string]int)},
+		{input: `{"a":1,"a":2}`, value: new(map[string]int), dup: true},
+		{input: `{"a":1,"b":2,"c":3,"b":4}`, value: new(map[string]int), dup: true},
+		{input: `{"a":1,"\u0061":2}`, value: new(struct{ A int }), dup: true},
+		{input: `{"a":1,"A":2}`, value: new(any)},
+		{input: `[{"a":1},{"a":2}]`, value: new([]map[string]int)},
+		{input: `{"a":{"b":1},"c":{"b":2}}`, value: new(RawMessage)},
+		{input: `{"x":[{"a":1,"a":2}]}`, value: new(RawMessage), dup: true},
+		{input: `{"x":{"a":1,"a":2}}`, value: new(struct{}), dup: true},
+		{input: `{"":1,"":2}`, value: new(OrderedObject), dup: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if _, err := Parse([]byte(test.input), test.value, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Parse([]byte(test.input), test.value, RejectDuplicateNames)
+			if !test.dup {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Errorf("expected a *SyntaxError, got %T: %v", err, err)
+			} else if !strings.Contains(err.Error(), "duplicate object member name") {
+				t.Errorf("wrong error: %v", err)
+			}
+		})
+	}
+
+	// Syntax errors found before duplicate names are reported by the decoder.
+	var v map[string]int
+	_, err1 := Parse([]byte(`{"a":,"a":1}`), &v, 0)
+	_, err2 := Parse([]byte(`{"a":,"a":1}`), &v, RejectDuplicateNames)
+	if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+		t.Errorf("errors mismatch\nwant: %v\ngot:  %v", err1, err2)
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"a":1} {"a":1,"a":2}`))
+	dec.RejectDuplicateNames()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&v); err == nil {
+		t.Error("expected an error decoding duplicate names")
+	}
+}
+
+func TestCodecBigFloatRat(t *testing.T) {
+	type amounts struct {
+		Float *big.Float `json:"float"`
+		Rat   *big.Rat   `json:"rat"`
+		Value big.Float  `json:"value"`
+	}
+
+	tests := []string{
+		`{"float":0.1,"rat":0.1,"value":0}`,
+		`{"float":1234567890123456789.12345678901234567890123456789,"rat":-123456789012345678901234567890.123456789,"value":-0.5}`,
+		`{"float":1e-7,"rat":1.25,"value":1e+21}`,
+		`{"float":null,"rat":null,"value":100}`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			var v amounts
+			if err := Unmarshal([]byte(test), &v); err != nil {
+				t.Fatal(err)
+			}
+			b, err := Append(nil, &v, BigValuesAsNumbers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test {
+				t.Errorf("output mismatch\nwant: %s\ngot:  %s", test, b)
+			}
+
+			// By default, values are encoded as strings like encoding/json.
+			want, err := json.Marshal(&v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Marshal(&v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("output mismatch with encoding/json\nwant: %s\ngot:  %s", want, got)
+			}
+		})
+	}
+
+	// Strings are accepted for compatibility with the output of MarshalText.
+	var v amounts
+	if err := Unmarshal([]byte(`{"float":"2.5","rat":"3/8","value":"-Inf"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Float.String() != "2.5" || v.Rat.RatString() != "3/8" || !v.Value.IsInf() {
+		t.Errorf("wrong values decoded from strings: %v %v %v", v.Float, v.Rat, &v.Value)
+	}
+
+	b, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"float":"2.5","rat":"3/8","value":"-Inf"}`; string(b) != expect {
+		t.Errorf("output mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+
+	if _, err := Append(nil, &v, BigValuesAsNumbers); err == nil {
+		t.Error("expected an error encoding an infinite value")
+	}
+	b, err = Append(nil, &v, BigValuesAsNumbers|NonFiniteFloatsAsString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"float":2.5,"rat":0.375,"value":"-Infinity"}`; string(b) != expect {
+		t.Errorf("output mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+	if _, err := Parse(b, &v, AllowNonFiniteFloats); err != nil || !v.Value.IsInf() || v.Value.Sign() >= 0 {
+		t.Errorf("wrong value decoded from non-finite float: %v (%v)", &v.Value, err)
+	}
+
+	// Rationals without a terminating decimal representation are encoded as
+	// strings even when numbers are requested.
+	b, err = Append(nil, &amounts{Rat: big.NewRat(1, 3)}, BigValuesAsNumbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"float":null,"rat":"1/3","value":0}`; string(b) != expect {
+		t.Errorf("output mismatch\nwant: %s\ngot:  %s", expect, b)
+	}
+	if err := Unmarshal(b, &v); err != nil || v.Rat.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("wrong value decoded from rational string: %v (%v)", v.Rat, err)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetBigValuesAsNumbers(true)
+	if err := enc.Encode(big.NewRat(5, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "1.25\n"; buf.String() != expect {
+		t.Errorf("output mismatch\nwant: %q\ngot:  %q", expect, buf.String())
+	}
+
+	if err := Unmarshal([]byte(`{"rat":true}`), &v); err == nil {
+		t.Error("expected an error decoding a boolean")
+	}
+}
+
+func TestDecoderTextSequence(t *testing.T) {
+	long := strings.Repeat("x", 2*minBufferSize)
+	input := "\x1e{\"a\":1}\n" +
+		"\x1e{\"a\":\n" + // corrupt
+		"\x1e\n" + // empty
+		"\x1e\"" + long + "\"\n" +
+		"\x1etrue" + // truncated
+		"\x1e[2] 3\n" + // trailing value
+		"\x1e 4\n"
+
+	type result struct {
+		value any
+		fail  bool
+	}
+	expect := []result{
+		{value: map[string]any{"a": 1.0}},
+		{fail: true},
+		{value: long},
+		{fail: true},
+		{fail: true},
+		{value: 4.0},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.SetFraming(TextSequence)
+
+	for i, want := range expect {
+		var v any
+		err := dec.Decode(&v)
+		if want.fail {
+			if err == nil {
+				t.Errorf("record %d: expected an error but decoded %v", i, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("record %d: %v", i, err)
+		} else if !reflect.DeepEqual(v, want.value) {
+			t.Errorf("record %d: value mismatch\nwant: %.32v\ngot:  %.32v", i, want.value, v)
+		}
+	}
+
+	if err := dec.Decode(new(any)); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the input but got %v", err)
+	}
+	if offset := dec.InputOffset(); offset != int64(len(input)) {
+		t.Errorf("input offset mismatch: want %d, got %d", len(input), offset)
+	}
+}
+
+func TestDecoderLines(t *testing.T) {
+	tests := []struct {
+		input  string
+		values int
+		fail   bool
+	}{
+		{input: "1\n{\"a\":[2]}\r\n\n\"3\"", values: 3},
+		{input: "1\n2 3\n", values: 2, fail: true},
+		{input: "1\n[2,\n3]\n", values: 1, fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(test.input))
+			dec.SetFraming(Lines)
+
+			var err error
+			n := 0
+			for ; err == nil; n++ {
+				err = dec.Decode(new(any))
+			}
+			n--
+
+			if n != test.values {
+				t.Errorf("decoded %d values, expected %d", n, test.values)
+			}
+			if fail := err != io.EOF; fail != test.fail {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncoderFraming(t *testing.T) {
+	type value struct {
+		A int        `json:"a"`
+		B RawMessage `json:"b"`
+	}
+	v := value{A: 1, B: RawMessage("[\n  2\n]")}
+
+	tests := []struct {
+		framing Framing
+		indent  string
+		expect  string
+	}{
+		{framing: Concatenated, expect: "{\"a\":1,\"b\":[\n  2\n]}"},
+		{framing: Lines, expect: "{\"a\":1,\"b\":[  2]}\n"},
+		{framing: Lines, indent: "  ", expect: "{\"a\":1,\"b\":[  2]}\n"},
+		{framing: TextSequence, expect: "\x1e{\"a\":1,\"b\":[\n  2\n]}\n"},
+		{framing: TextSequence, indent: "\t", expect: "\x1e{\n\t\"a\": 1,\n\t\"b\": [\n\t\t2\n\t]\n}\n"},
+	}
+
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.SetAppendNewline(false)
+		enc.SetIndent("", test.indent)
+		enc.SetFraming(test.framing)
+
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expect {
+			t.Errorf("output mismatch with framing %d\nwant: %q\ngot:  %q", test.framing, test.expect, buf.String())
+		}
+	}
+
+	// The separators and newlines are also written when the output is
+	// flushed while encoding the value.
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetFlushThreshold(1)
+	enc.SetFraming(TextSequence)
+
+	for range 2 {
+		if err := enc.Encode([]value{v, v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(buf)
+	dec.SetFraming(TextSequence)
+
+	for range 2 {
+		var values []value
+		if err := dec.Decode(&values); err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 2 || values[1].A != 1 {
+			t.Errorf("wrong values decoded: %+v", values)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`null`, ` null `, true},
+		{`{"a":1,"b":2}`, `{ "b" : 2, "a" : 1 }`, true},
+		{`{"a":1,"a":2}`, `{"a":2}`, true},
+		{`{"a":1}`, `{"a":1,"b":null}`, false},
+		{`[1,2]`, `[2,1]`, false},
+		{`[1,2]`, `[1,2,3]`, false},
+		{`1`, `1.0`, true},
+		{`100`, `1e2`, true},
+		{`0.5`, `5E-1`, true},
+		{`-0`, `0.0`, true},
+		{`12345678901234567890`, `12345678901234567891`, false},
+		{`1.00000000000000000001`, `1`, false},
+		{`-1`, `1`, false},
+		{`1`, `"1"`, false},
+		{`"é\/"`, `"é/"`, true},
+		{`{"a":[{"b":true}]}`, `{"a":[{"b":true}]}`, true},
+		{`{"a":[{"b":true}]}`, `{"a":[{"b":false}]}`, false},
+		{`{"k":[{"a":1},5]}`, `{"k":[{"a":1},6]}`, false},
+		{`{"k":[{},5,"s"]}`, `{"k":[{},5,"t"]}`, false},
+		{`[[{}],5]`, `[[{}],6]`, false},
+		{`[{"a":1},"x",{"b":2}]`, `[{"a":1},"x",{"b":2}]`, true},
+		{`{}`, `[]`, false},
+		{`{"a":1}`, `{"a":1`, false},
+		{`{"a":1}`, `{"a":1}x`, false},
+	}
+
+	for _, test := range tests {
+		if equal := Equal([]byte(test.a), []byte(test.b)); equal != test.equal {
+			t.Errorf("Equal(%s, %s): want %t, got %t", test.a, test.b, test.equal, equal)
+		}
+		if equal := Equal([]byte(test.b), []byte(test.a)); equal != test.equal {
+			t.Errorf("Equal(%s, %s): want %t, got %t", test.b, test.a, test.equal, equal)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := `{
+		"id": 42,
+		"name": "gopher",
+		"tags": ["a", "b", "c"],
+		"owner": {"id": 1, "email": "a@example.com"},
+		"a/b~c": true,
+		"score": 1.50
+	}`
+	b := `{
+		"score": 1.5,
+		"id": "42",
+		"tags": ["a", "x"],
+		"owner": {"id": 1, "email": "b@example.com", "admin": true},
+		"a/b~c": true,
+		"extra": null
+	}`
+
+	diffs, err := Diff([]byte(a), []byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`changed /id: 42 => "42"`,
+		`removed /name: "gopher"`,
+		`changed /tags/1: "b" => "x"`,
+		`removed /tags/2: "c"`,
+		`changed /owner/email: "a@example.com" => "b@example.com"`,
+		`added /owner/admin: true`,
+		`added /extra: null`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("differences mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+
+	diffs, err = Diff([]byte(`{"a":/"b"`), []byte(`{"a":"b/"}`))
+	if err == nil {
+		t.Errorf("expected an error but got %q", diffs)
+	}
+
+	diffs, err = Diff([]byte(`{"x~/":[]}`), []byte(`{"x~/":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "/x~0~1" || diffs[0].Op != Changed {
+		t.Errorf("wrong differences: %q", diffs)
+	}
+
+	// Scalars following objects in arrays must not be mistaken for keys.
+	diffs, err = Diff([]byte(`[1,{}]`), []byte(`[1,{},3]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].String() != `added /2: 3` {
+		t.Errorf("wrong differences: %q", diffs)
+	}
+
+	diffs, err = Diff([]byte(`{"k":[{"a":1},5,{"b":2}]}`), []byte(`{"k":[{"a":1},6,{"b":3}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].String() != `changed /k/1: 5 => 6` || diffs[1].String() != `changed /k/2/b: 2 => 3` {
+		t.Errorf("wrong differences: %q", diffs)
+	}
+}
+
+type testRawMarshaler struct{}
+
+func (testRawMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "ключ" : "😀" }`), nil
+}
+
+func TestEscapeNonASCII(t *testing.T) {
+	type value struct {
+		Name   string            `json:"näme"`
+		Map    map[string]string `json:"map"`
+		Raw    RawMessage        `json:"raw"`
+		Marsh  testRawMarshaler  `json:"marsh"`
+		Escape string            `json:"escape"`
+	}
+
+	v := value{
+		Name:   "héllo wörld",
+		Map:    map[string]string{"日本": "語"},
+		Raw:    RawMessage(`[ "ü", "\u00fc" ]`),
+		Escape: "<&>\u2028\U0001F600\xff",
+	}
+
+	b, err := Append(nil, v, SortMapKeys|EscapeNonASCII)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"n\u00e4me":"h\u00e9llo w\u00f6rld","map":{"\u65e5\u672c":"\u8a9e"},"raw":["\u00fc","\u00fc"],` +
+		`"marsh":{"\u043a\u043b\u044e\u0447":"\ud83d\ude00"},"escape":"<&>\u2028\ud83d\ude00\ufffd"}`
+	if string(b) != want {
+		t.Errorf("output mismatch\nwant: %s\ngot:  %s", want, b)
+	}
+
+	var got value
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != v.Name || got.Map["日本"] != "語" || got.Escape != "<&>\u2028\U0001F600\ufffd" {
+		t.Errorf("wrong value decoded: %+v", got)
+	}
+
+	if s := string(AppendEscape(nil, "<ü>", EscapeHTML|EscapeNonASCII)); s != `"\u003c\u00fc\u003e"` {
+		t.Errorf("wrong output of AppendEscape: %s", s)
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetEscapeNonASCII(true)
+	if err := enc.Encode("ü"); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "\"\\u00fc\"\n" {
+		t.Errorf("wrong output of Encoder: %q", s)
+	}
+
+	type keys struct {
+		A int `json:"<ü>"`
+		B int `json:"<u>"`
+	}
+	for flags, want := range map[AppendFlags]string{
+		0:                           `{"<ü>":1,"<u>":2}`,
+		EscapeHTML:                  `{"\u003cü\u003e":1,"\u003cu\u003e":2}`,
+		EscapeNonASCII:              `{"<\u00fc>":1,"<u>":2}`,
+		EscapeHTML | EscapeNonASCII: `{"\u003c\u00fc\u003e":1,"\u003cu\u003e":2}`,
+	} {
+		if b, _ := Append(nil, &keys{A: 1, B: 2}, flags); string(b) != want {
+			t.Errorf("output mismatch with flags %v\nwant: %s\ngot:  %s", flags, want, b)
+		}
+	}
+
+	// The escaped keys are computed once, when the codec is constructed.
+	b = make([]byte, 0, 64)
+	k := &keys{}
+	if n := testing.AllocsPerRun(10, func() { b, _ = Append(b[:0], k, EscapeNonASCII) }); n != 0 {
+		t.Errorf("encoding escaped keys allocated %v times", n)
+	}
+}
+
+func TestAppendCompactIndent(t *testing.T) {
+	src := []byte(` { "a" : [ 1, "ü <b>" ], "b\u00e9" : {} } `)
+
+	tests := []struct {
+		flags   AppendFlags
+		compact string
+		indent  string
+	}{
+		{0, `{"a":[1,"ü <b>"],"b\u00e9":{}}`, "{\n\t\"a\": [\n\t\t1,\n\t\t\"ü <b>\"\n\t],\n\t\"b\\u00e9\": {}\n}"},
+		{EscapeHTML, `{"a":[1,"ü \u003cb\u003e"],"b\u00e9":{}}`, "{\n\t\"a\": [\n\t\t1,\n\t\t\"ü \\u003cb\\u003e\"\n\t],\n\t\"b\\u00e9\": {}\n}"},
+		{EscapeNonASCII, `{"a":[1,"\u00fc <b>"],"b\u00e9":{}}`, "{\n\t\"a\": [\n\t\t1,\n\t\t\"\\u00fc <b>\"\n\t],\n\t\"b\\u00e9\": {}\n}"},
+	}
+
+	for _, test := range tests {
+		b, err := AppendCompact([]byte("x"), src, test.flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "x"+test.compact {
+			t.Errorf("compact output mismatch with flags %d\nwant: %s\ngot:  %s", test.flags, test.compact, b[1:])
+		}
+
+		b, err = AppendIndent([]byte("x"), src, "", "\t", test.flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "x"+test.indent {
+			t.Errorf("indent output mismatch with flags %d\nwant: %s\ngot:  %s", test.flags, test.indent, b[1:])
+		}
+	}
+
+	if b, err := AppendCompact([]byte("x"), []byte(`{"a":}`), 0); err == nil || string(b) != "x" {
+		t.Errorf("expected an error and an unchanged buffer but got %q, %v", b, err)
+	}
+}
+
+func TestInternStrings(t *testing.T) {
+	// The inputs have a single string short enough to be interned, so that
+	// it cannot be evicted by a collision in the tables.
+	const short = `{"key":["key","key"]}`
+	const long = `["0123456789012345678901234567890123456789012345678901234567890123456789"]`
+
+	decode := func(t *testing.T, dec *Decoder, v any) {
+		t.Helper()
+		if err := dec.Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sameData := func(a, b string) bool {
+		return unsafe.StringData(a) == unsafe.StringData(b)
+	}
+
+	firstKey := func(m map[string]any) string {
+		for k := range m {
+			return k
+		}
+		return ""
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader(short + short + long + long))
+		dec.InternStrings()
+
+		var v1, v2 map[string]any
+		decode(t, dec, &v1)
+		decode(t, dec, &v2)
+
+		if !sameData(firstKey(v1), firstKey(v2)) {
+			t.Error("map keys were not interned")
+		}
+		if !sameData(firstKey(v1), v1["key"].([]any)[0].(string)) {
+			t.Error("string values were not interned")
+		}
+		if !sameData(v1["key"].([]any)[0].(string), v2["key"].([]any)[1].(string)) {
+			t.Error("repeated string values were not interned")
+		}
+
+		var l1, l2 []string
+		decode(t, dec, &l1)
+		decode(t, dec, &l2)
+
+		if sameData(l1[0], l2[0]) {
+			t.Error("strings longer than the maximum length were interned")
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		table := NewStringTable(16, 128)
+		dec1 := NewDecoder(strings.NewReader(long))
+		dec1.SetStringTable(table)
+		dec2 := NewDecoder(strings.NewReader(long))
+		dec2.SetStringTable(table)
+
+		var l1, l2 []string
+		decode(t, dec1, &l1)
+		decode(t, dec2, &l2)
+
+		if !sameData(l1[0], l2[0]) {
+			t.Error("strings were not interned in the table of the decoders")
+		}
+		if s := table.Intern([]byte(l1[0])); !sameData(s, l1[0]) {
+			t.Error("strings decoded were not added to the table")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader(short + short))
+		dec.SetStringTable(NewStringTable(16, 128))
+		dec.SetStringTable(nil)
+
+		var v1, v2 map[string]any
+		decode(t, dec, &v1)
+		decode(t, dec, &v2)
+
+		if sameData(firstKey(v1), firstKey(v2)) {
+			t.Error("strings were interned after disabling the table")
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		table := NewStringTable(3, 8)
+		if n := len(table.slots); n != 4 {
+			t.Errorf("table size was not rounded up to a power of two: %d", n)
+		}
+		for i := range 1000 {
+			table.Intern([]byte(strconv.Itoa(i)))
+		}
+		n := 0
+		for i := range table.slots {
+			if table.slots[i].Load() != nil {
+				n++
+			}
+		}
+		if n > 4 {
+			t.Errorf("table holds more strings than its size: %d", n)
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		var v1, v2 struct {
+			Name string `json:"name"`
+		}
+		const input = `{"name":"alice"}`
+		if _, err := Parse([]byte(input), &v1, InternStrings); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse([]byte(input), &v2, InternStrings|DontCopyString); err != nil {
+			t.Fatal(err)
+		}
+		if !sameData(v1.Name, v2.Name) {
+			t.Error("struct fields were not interned")
+		}
+	})
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		opts      FlattenOptions
+		nested    string
+		flattened string
+	}{
+		{
+			nested:    `{}`,
+			flattened: `{}`,
+		},
+		{
+			nested:    `{"a":{"b":1,"c":[true,{"d":null}]},"e":"f"}`,
+			flattened: `{"a.b":1,"a.c.0":true,"a.c.1.d":null,"e":"f"}`,
+		},
+		{
+			nested:    `{"a":{},"b":[],"c":[[]]}`,
+			flattened: `{"a":{},"b":[],"c.0":[]}`,
+		},
+		{
+			opts:      FlattenOptions{Separator: "/"},
+			nested:    `{"a.b":{"c":[1,2]}}`,
+			flattened: `{"a.b/c/0":1,"a.b/c/1":2}`,
+		},
+		{
+			opts:      FlattenOptions{Arrays: IndexWithBrackets},
+			nested:    `{"a":[[1,2],{"b":[3]}],"":[4],"c":{"0":5}}`,
+			flattened: `{"a[0][0]":1,"a[0][1]":2,"a[1].b[0]":3,"[0]":4,"c.0":5}`,
+		},
+		{
+			opts:      FlattenOptions{Arrays: ArraysAsValues},
+			nested:    `{"a":{"b":[1,{"c":2}]},"d":{"0":3}}`,
+			flattened: `{"a.b":[1,{"c":2}],"d.0":3}`,
+		},
+		{
+			opts:      FlattenOptions{MaxDepth: 2},
+			nested:    `{"a":{"b":{"c":1},"d":[2]},"e":{"f":3}}`,
+			flattened: `{"a.b":{"c":1},"a.d":[2],"e.f":3}`,
+		},
+		{
+			opts:      FlattenOptions{MaxDepth: 2, Arrays: IndexWithBrackets},
+			nested:    `{"a":[[1],[2]]}`,
+			flattened: `{"a[0]":[1],"a[1]":[2]}`,
+		},
+		{
+			nested:    `{"a":[{},1]}`,
+			flattened: `{"a.0":{},"a.1":1}`,
+		},
+		{
+			nested:    `{"a":[{"x":1},2,"s",[{}],3]}`,
+			flattened: `{"a.0.x":1,"a.1":2,"a.2":"s","a.3.0":{},"a.4":3}`,
+		},
+		{
+			opts:      FlattenOptions{MaxDepth: 2},
+			nested:    `{"a":[{"x":1},2]}`,
+			flattened: `{"a.0":{"x":1},"a.1":2}`,
+		},
+		{
+			opts:      FlattenOptions{Arrays: ArraysAsValues},
+			nested:    `{"a":{"b":[{"x":1},2]},"c":3}`,
+			flattened: `{"a.b":[{"x":1},2],"c":3}`,
+		},
+		{
+			opts:      FlattenOptions{Flags: EscapeHTML},
+			nested:    `{"<a>":{"é":"&"}}`,
+			flattened: `{"\u003ca\u003e.é":"\u0026"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.nested, func(t *testing.T) {
+			b, err := Flatten([]byte(test.nested), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.flattened {
+				t.Errorf("flattened value mismatch\nwant: %s\ngot:  %s", test.flattened, b)
+			}
+
+			b, err = Unflatten(b, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !Equal(b, []byte(test.nested)) {
+				t.Errorf("unflattened value mismatch\nwant: %s\ngot:  %s", test.nested, b)
+			}
+		})
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	tests := []struct {
+		opts      FlattenOptions
+		flattened string
+		nested    string
+	}{
+		{
+			flattened: ` { "a.b" : 1 , "c" : [ 2 ] , "a.d.e" : { "f" : 3 } } `,
+			nested:    `{"a":{"b":1,"d":{"e":{"f":3}}},"c":[2]}`,
+		},
+		{
+			flattened: `{"a.1":1,"a.0":0,"b.0":0,"b.2":2,"c.01":1,"a.0":-1}`,
+			nested:    `{"a":[-1,1],"b":{"0":0,"2":2},"c":{"01":1}}`,
+		},
+		{
+			opts:      FlattenOptions{Arrays: IndexWithBrackets},
+			flattened: `{"a[1]":1,"a[0]":0,"b.0":0,"c[x]":1,"d.e[0][1]":2,"d.e[0][0]":3}`,
+			nested:    `{"a":[0,1],"b":{"0":0},"c[x]":1,"d":{"e":[[3,2]]}}`,
+		},
+		{
+			opts:      FlattenOptions{Arrays: ArraysAsValues},
+			flattened: `{"a.0":0,"a.1":1}`,
+			nested:    `{"a":{"0":0,"1":1}}`,
+		},
+		{
+			opts:      FlattenOptions{MaxDepth: 2},
+			flattened: `{"a.b.c":1,"d":2}`,
+			nested:    `{"a":{"b.c":1},"d":2}`,
+		},
+		{
+			opts:      FlattenOptions{MaxDepth: 2, Arrays: IndexWithBrackets},
+			flattened: `{"a[0][1].b":1}`,
+			nested:    `{"a":{"[0][1].b":1}}`,
+		},
+		{
+			opts:      FlattenOptions{Separator: "::"},
+			flattened: `{"a::b":1,"a:c":2}`,
+			nested:    `{"a":{"b":1},"a:c":2}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.flattened, func(t *testing.T) {
+			b, err := Unflatten([]byte(test.flattened), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.nested {
+				t.Errorf("unflattened value mismatch\nwant: %s\ngot:  %s", test.nested, b)
+			}
+		})
+	}
+
+	for _, test := range []struct {
+		opts  FlattenOptions
+		input string
+	}{
+		{input: `[1]`},
+		{input: `{"a":1`},
+		{input: `{"a":1,"a.b":2}`},
+		{input: `{"a.b":1,"a":2}`},
+		{input: `{"a":{},"a.b":2}`},
+		{opts: FlattenOptions{Arrays: IndexWithBrackets}, input: `{"a[0]":1,"a.b":2}`},
+		{opts: FlattenOptions{Arrays: IndexWithBrackets}, input: `{"a[1]":1}`},
+		{opts: FlattenOptions{Arrays: IndexWithBrackets}, input: `{"x":1,"a[0]":1,"a[2]":2}`},
+	} {
+		// The buffer is returned unchanged on errors, including the ones
+		// detected after part of the output was written.
+		b, err := AppendUnflatten([]byte("PREFIX"), []byte(test.input), test.opts)
+		if err == nil {
+			t.Errorf("unflattening %s did not fail: %s", test.input, b)
+		} else if string(b) != "PREFIX" {
+			t.Errorf("unflattening %s modified the output buffer: %s", test.input, b)
+		}
+	}
+
+	for _, input := range []string{`[1]`, `"a"`, `{"a":}`} {
+		b, err := AppendFlatten([]byte("PREFIX"), []byte(input), FlattenOptions{})
+		if err == nil {
+			t.Errorf("flattening %s did not fail: %s", input, b)
+		} else if string(b) != "PREFIX" {
+			t.Errorf("flattening %s modified the output buffer: %s", input, b)
+		}
+	}
+}
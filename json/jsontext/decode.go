@@ -0,0 +1,184 @@
+package jsontext
+
+import (
+	"errors"
+	"io"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/segmentio/encoding/json/internal/jsonopts"
+)
+
+// Decoder reads a stream of JSON values, one token or value at a time.
+//
+// Each top-level value is read and validated entirely before its first token
+// is returned, which means that the memory used by the decoder is bounded by
+// the size of the largest top-level value in the stream.
+type Decoder struct {
+	decoder *json.Decoder
+	opts    jsonopts.Struct
+	tok     *json.Tokenizer
+	value   []byte // current top-level value
+	offset  int64  // offset of value in the input stream
+	peeked  bool   // tok is positioned on a token which was not read yet
+	depth   int
+}
+
+// NewDecoder constructs a decoder reading from r, configured with opts.
+func NewDecoder(r io.Reader, opts ...Options) *Decoder {
+	d := new(Decoder)
+	d.Reset(r, opts...)
+	return d
+}
+
+// Reset resets the state of d to read from r, configured with opts.
+func (d *Decoder) Reset(r io.Reader, opts ...Options) {
+	if d.tok != nil {
+		d.tok.Reset(nil)
+	}
+	*d = Decoder{decoder: json.NewDecoder(r), tok: d.tok}
+	d.decoder.DontCopyRawMessage()
+	d.opts.Join(opts...)
+	if !d.opts.IsSet(jsonopts.AllowDuplicateNames) {
+		d.decoder.RejectDuplicateNames()
+	}
+}
+
+// Options returns the options that d was configured with.
+func (d *Decoder) Options() Options {
+	opts := d.opts
+	return &opts
+}
+
+// StackDepth returns the number of objects and arrays that d is nested in.
+func (d *Decoder) StackDepth() int {
+	return d.depth
+}
+
+// InputOffset returns the offset in the input stream of the next token.
+func (d *Decoder) InputOffset() int64 {
+	if d.tok == nil || d.value == nil {
+		return d.decoder.InputOffset()
+	}
+	end := len(d.value) - d.tok.Remaining()
+	if d.peeked {
+		end -= len(d.tok.Value)
+	}
+	return d.offset + int64(end)
+}
+
+// PeekKind returns the kind of the next token without consuming it, or zero
+// if there are no more tokens or an error occurred.
+func (d *Decoder) PeekKind() Kind {
+	if d.peek() != nil {
+		return 0
+	}
+	return d.kind()
+}
+
+// ReadToken reads the next token, it returns io.EOF at the end of the input.
+func (d *Decoder) ReadToken() (Token, error) {
+	if err := d.peek(); err != nil {
+		return Token{}, err
+	}
+	d.peeked = false
+
+	switch k := d.kind(); k {
+	case '{', '[':
+		d.depth++
+		return Token{raw: string(k)}, nil
+	case '}', ']':
+		d.depth--
+		return Token{raw: string(k)}, nil
+	default:
+		return Token{raw: string(d.tok.Value)}, nil
+	}
+}
+
+// ReadValue reads the next value, it returns io.EOF at the end of the input.
+//
+// The returned value is only valid until the next call to a method of d.
+func (d *Decoder) ReadValue() (Value, error) {
+	if err := d.peek(); err != nil {
+		return nil, err
+	}
+
+	switch d.kind() {
+	case '}', ']':
+		return nil, errors.New("jsontext: cannot read value at the end of " + d.kind().String())
+	case '{', '[':
+	default:
+		d.peeked = false
+		return Value(d.tok.Value), nil
+	}
+
+	start := len(d.value) - d.tok.Remaining() - len(d.tok.Value)
+	depth := 0
+
+	for {
+		switch d.tok.Delim {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+		if !d.tok.Next() {
+			return nil, d.tok.Err
+		}
+	}
+
+	d.peeked = false
+	return Value(d.value[start : len(d.value)-d.tok.Remaining()]), nil
+}
+
+// SkipValue reads and discards the next value.
+func (d *Decoder) SkipValue() error {
+	_, err := d.ReadValue()
+	return err
+}
+
+func (d *Decoder) kind() Kind {
+	if d.tok.Delim != 0 {
+		return Kind(d.tok.Delim)
+	}
+	return kindOf(d.tok.Value[0])
+}
+
+// peek positions the tokenizer on the next token, reading the next top-level
+// value from the input when the current one was entirely consumed.
+func (d *Decoder) peek() error {
+	if d.peeked {
+		return nil
+	}
+
+	for {
+		if d.tok != nil && d.value != nil {
+			for d.tok.Next() {
+				if d.tok.Delim != ':' && d.tok.Delim != ',' {
+					d.peeked = true
+					return nil
+				}
+			}
+			if err := d.tok.Err; err != nil {
+				return err
+			}
+		}
+
+		offset := d.decoder.InputOffset()
+
+		var v json.RawMessage
+		if err := d.decoder.Decode(&v); err != nil {
+			return err
+		}
+
+		if d.tok == nil {
+			d.tok = json.NewTokenizer(v)
+		} else {
+			d.tok.Reset(v)
+		}
+		d.value = v
+		d.offset = offset
+	}
+}
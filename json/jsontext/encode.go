@@ -0,0 +1,169 @@
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/segmentio/encoding/json/internal/jsonopts"
+)
+
+// Encoder writes a stream of JSON values, one token or value at a time.
+//
+// Separators between values and members of objects are inserted by the
+// encoder, and each top-level value is followed by a newline. Top-level values
+// are buffered in memory and written to the underlying writer once complete.
+type Encoder struct {
+	writer io.Writer
+	opts   jsonopts.Struct
+	buffer []byte
+	stack  []encoderScope
+	offset int64
+	err    error
+}
+
+type encoderScope struct {
+	delim byte // '{' or '['
+	count int  // number of tokens written in the scope, excluding delimiters
+}
+
+// NewEncoder constructs an encoder writing to w, configured with opts.
+func NewEncoder(w io.Writer, opts ...Options) *Encoder {
+	e := new(Encoder)
+	e.Reset(w, opts...)
+	return e
+}
+
+// Reset resets the state of e to write to w, configured with opts.
+func (e *Encoder) Reset(w io.Writer, opts ...Options) {
+	*e = Encoder{writer: w, buffer: e.buffer[:0], stack: e.stack[:0]}
+	e.opts.Join(opts...)
+}
+
+// Options returns the options that e was configured with.
+func (e *Encoder) Options() Options {
+	opts := e.opts
+	return &opts
+}
+
+// StackDepth returns the number of objects and arrays that e is nested in.
+func (e *Encoder) StackDepth() int {
+	return len(e.stack)
+}
+
+// OutputOffset returns the number of bytes written by e, including those
+// buffered but not written to the underlying writer yet.
+func (e *Encoder) OutputOffset() int64 {
+	return e.offset + int64(len(e.buffer))
+}
+
+// WriteToken writes the next token to the output.
+func (e *Encoder) WriteToken(t Token) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	switch k := t.Kind(); k {
+	case 0:
+		return errors.New("jsontext: cannot write invalid token")
+
+	case '}', ']':
+		// In ASCII, closing delimiters are two bytes after the opening ones.
+		n := len(e.stack) - 1
+		if n < 0 || e.stack[n].delim != byte(k)-2 {
+			return fmt.Errorf("jsontext: cannot write unmatched %s token", k)
+		}
+		if k == '}' && e.stack[n].count%2 != 0 {
+			return errors.New("jsontext: cannot end object after a name without a value")
+		}
+		e.stack = e.stack[:n]
+		e.buffer = append(e.buffer, t.raw...)
+
+	default:
+		if err := e.writeSeparator(k); err != nil {
+			return err
+		}
+		e.buffer = append(e.buffer, t.raw...)
+		if k == '{' || k == '[' {
+			e.stack = append(e.stack, encoderScope{delim: byte(k)})
+		}
+	}
+
+	return e.flushValue()
+}
+
+// WriteValue writes the next value to the output. The value is validated and
+// compacted.
+func (e *Encoder) WriteValue(v Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	if !v.IsValid() {
+		return errors.New("jsontext: cannot write invalid value")
+	}
+	if err := e.writeSeparator(v.Kind()); err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(e.buffer)
+	json.Compact(buf, v)
+	e.buffer = buf.Bytes()
+	return e.flushValue()
+}
+
+func (e *Encoder) writeSeparator(k Kind) error {
+	n := len(e.stack) - 1
+	if n < 0 {
+		return nil
+	}
+
+	s := &e.stack[n]
+	switch {
+	case s.delim == '{' && s.count%2 == 0:
+		if k != '"' {
+			return fmt.Errorf("jsontext: object member name must be a string, found %s", k)
+		}
+		if s.count != 0 {
+			e.buffer = append(e.buffer, ',')
+		}
+	case s.delim == '{':
+		e.buffer = append(e.buffer, ':')
+	case s.count != 0:
+		e.buffer = append(e.buffer, ',')
+	}
+
+	s.count++
+	return nil
+}
+
+// flushValue writes the buffered value to the underlying writer if it is a
+// complete top-level value.
+func (e *Encoder) flushValue() error {
+	if len(e.stack) != 0 {
+		return nil
+	}
+
+	b := e.buffer
+	if e.opts.Multiline() {
+		var buf bytes.Buffer
+		prefix, indent := e.opts.IndentStrings()
+		json.Indent(&buf, b, prefix, indent)
+		b = buf.Bytes()
+	}
+	if e.opts.IsSet(jsonopts.EscapeForHTML) {
+		var buf bytes.Buffer
+		json.HTMLEscape(&buf, b)
+		b = buf.Bytes()
+	}
+	b = append(b, '\n')
+
+	n, err := e.writer.Write(b)
+	e.offset += int64(n)
+	e.buffer = e.buffer[:0]
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+// Package jsontext implements the syntactic layer of the JSON encoding, with
+// an API compatible with the encoding/json/jsontext package of the standard
+// library.
+//
+// The package is backed by the parser of github.com/segmentio/encoding/json,
+// and is intended to be used with the json/v2 package of this module.
+package jsontext
+
+import (
+	"github.com/segmentio/encoding/json/internal/jsonopts"
+)
+
+// Options configures the encoding and decoding operations, see the
+// constructors of options in this package and in the json/v2 package.
+//
+// Options which do not apply to an operation are ignored.
+type Options = jsonopts.Options
+
+// EscapeForHTML specifies that the characters <, >, and & are escaped in JSON
+// strings. Defaults to false.
+func EscapeForHTML(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.EscapeForHTML, Value: v}
+}
+
+// Multiline specifies that the output is expanded over multiple lines, with
+// nested values indented (see WithIndent). Defaults to false.
+func Multiline(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.Multiline, Value: v}
+}
+
+// WithIndent specifies the indentation of nested values when the output is
+// multiline, and implies Multiline(true). Defaults to a tab.
+func WithIndent(indent string) Options {
+	return jsonopts.String{Flag: jsonopts.Indent, Value: indent}
+}
+
+// WithIndentPrefix specifies the prefix of lines after the first one when the
+// output is multiline, and implies Multiline(true). Defaults to an empty
+// string.
+func WithIndentPrefix(prefix string) Options {
+	return jsonopts.String{Flag: jsonopts.IndentPrefix, Value: prefix}
+}
+
+// AllowDuplicateNames specifies that objects may have members with the same
+// name when decoding. Defaults to false, the Decoder and the decoding functions
+// of the json/v2 package return an error on duplicate names. Names written to
+// an Encoder are not checked.
+func AllowDuplicateNames(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.AllowDuplicateNames, Value: v}
+}
+
+// Kind represents the kind of JSON tokens and values, as the first byte of
+// their grammar: 'n' for null, 'f' for false, 't' for true, '"' for strings,
+// '0' for numbers, '{' and '}' for the delimiters of objects, and '[' and ']'
+// for the delimiters of arrays. The zero value represents an invalid kind.
+type Kind byte
+
+func kindOf(c byte) Kind {
+	switch c {
+	case 'n', 'f', 't', '"', '{', '}', '[', ']':
+		return Kind(c)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return '0'
+	default:
+		return 0
+	}
+}
+
+// String returns a human readable representation of k.
+func (k Kind) String() string {
+	switch k {
+	case 'n':
+		return "null"
+	case 'f':
+		return "false"
+	case 't':
+		return "true"
+	case '"':
+		return "string"
+	case '0':
+		return "number"
+	case '{', '}', '[', ']':
+		return string(k)
+	default:
+		return "invalid"
+	}
+}
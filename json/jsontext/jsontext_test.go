@@ -0,0 +1,161 @@
+package jsontext
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		tok  Token
+		kind Kind
+		str  string
+	}{
+		{Null, 'n', "null"},
+		{True, 't', "true"},
+		{Bool(false), 'f', "false"},
+		{String("hello\n"), '"', "hello\n"},
+		{Int(-42), '0', "-42"},
+		{Uint(42), '0', "42"},
+		{Float(0.5), '0', "0.5"},
+		{BeginObject, '{', "{"},
+		{EndArray, ']', "]"},
+		{Token{}, 0, "<invalid jsontext.Token>"},
+	}
+
+	for _, test := range tests {
+		if k := test.tok.Kind(); k != test.kind {
+			t.Errorf("%v: wrong kind: %v", test.tok, k)
+		}
+		if s := test.tok.String(); s != test.str {
+			t.Errorf("%v: wrong string: %q", test.tok, s)
+		}
+	}
+
+	if n := Int(-42).Int(); n != -42 {
+		t.Errorf("wrong integer value: %d", n)
+	}
+	if n := Float(1e100).Uint(); n != math.MaxUint64 {
+		t.Errorf("wrong clamped value: %d", n)
+	}
+	if f := Float(math.Inf(-1)).Float(); !math.IsInf(f, -1) {
+		t.Errorf("wrong infinite value: %g", f)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	tokens := []Token{
+		BeginObject,
+		String("a"), Int(1),
+		String("b"), BeginArray, True, Null, EndArray,
+		String("c"),
+	}
+	for _, tok := range tokens {
+		if err := enc.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteValue(Value(`{ "d" : [ 1, 2 ] }`)); err != nil {
+		t.Fatal(err)
+	}
+	if d := enc.StackDepth(); d != 1 {
+		t.Errorf("wrong stack depth: %d", d)
+	}
+	if err := enc.WriteToken(EndObject); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteToken(String("next")); err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `{"a":1,"b":[true,null],"c":{"d":[1,2]}}` + "\n" + `"next"` + "\n"
+	if s := buf.String(); s != expect {
+		t.Errorf("wrong output\nwant: %s\ngot:  %s", expect, s)
+	}
+
+	if err := enc.WriteToken(EndArray); err == nil {
+		t.Error("expected an error writing an unmatched delimiter")
+	}
+	enc.WriteToken(BeginObject)
+	if err := enc.WriteToken(Int(1)); err == nil {
+		t.Error("expected an error writing a number as object member name")
+	}
+
+	buf.Reset()
+	enc.Reset(buf, WithIndent("  "), EscapeForHTML(true))
+	enc.WriteValue(Value(`{"a":["<b>"]}`))
+
+	const indented = "{\n  \"a\": [\n    \"\\u003cb\\u003e\"\n  ]\n}\n"
+	if s := buf.String(); s != indented {
+		t.Errorf("wrong indented output\nwant: %q\ngot:  %q", indented, s)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	const input = ` {"a":1,"b":[true,{"c":null}],"d":"e"} "next" `
+
+	dec := NewDecoder(strings.NewReader(input))
+
+	var kinds []Kind
+	for {
+		tok, err := dec.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, tok.Kind())
+	}
+
+	if s := string(kinds); s != `{"0"[t{"n}]""}"` {
+		t.Errorf("wrong sequence of tokens: %s", s)
+	}
+
+	dec.Reset(strings.NewReader(input))
+	if tok, _ := dec.ReadToken(); tok.Kind() != '{' {
+		t.Fatalf("wrong token: %v", tok)
+	}
+	if tok, _ := dec.ReadToken(); tok.String() != "a" {
+		t.Fatalf("wrong token: %v", tok)
+	}
+	if err := dec.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+	dec.ReadToken()
+
+	if k := dec.PeekKind(); k != '[' {
+		t.Errorf("wrong peeked kind: %v", k)
+	}
+	v, err := dec.ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != `[true,{"c":null}]` {
+		t.Errorf("wrong value: %s", v)
+	}
+	if d := dec.StackDepth(); d != 1 {
+		t.Errorf("wrong stack depth: %d", d)
+	}
+
+	dec.ReadToken()
+	dec.ReadToken()
+	dec.ReadToken()
+
+	v, err = dec.ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != `"next"` {
+		t.Errorf("wrong value: %s", v)
+	}
+	if _, err := dec.ReadValue(); err != io.EOF {
+		t.Errorf("expected io.EOF but got %v", err)
+	}
+}
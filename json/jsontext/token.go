@@ -0,0 +1,146 @@
+package jsontext
+
+import (
+	"strconv"
+
+	"github.com/segmentio/encoding/json"
+)
+
+// Token represents a lexical JSON token: a null, boolean, string, or number
+// literal, or a delimiter of an object or an array.
+//
+// The zero value of Token is invalid.
+type Token struct {
+	raw string // json encoding of the token
+}
+
+var (
+	Null  = Token{raw: "null"}
+	False = Token{raw: "false"}
+	True  = Token{raw: "true"}
+
+	BeginObject = Token{raw: "{"}
+	EndObject   = Token{raw: "}"}
+	BeginArray  = Token{raw: "["}
+	EndArray    = Token{raw: "]"}
+)
+
+// Bool constructs a token representing a boolean.
+func Bool(b bool) Token {
+	if b {
+		return True
+	}
+	return False
+}
+
+// String constructs a token representing a string.
+func String(s string) Token {
+	return Token{raw: string(json.AppendEscape(nil, s, 0))}
+}
+
+// Float constructs a token representing a floating point number. NaN and
+// infinite values are represented by the strings "NaN", "Infinity", and
+// "-Infinity".
+func Float(f float64) Token {
+	b, _ := json.Append(nil, f, json.NonFiniteFloatsAsString)
+	return Token{raw: string(b)}
+}
+
+// Int constructs a token representing a signed integer.
+func Int(n int64) Token {
+	return Token{raw: strconv.FormatInt(n, 10)}
+}
+
+// Uint constructs a token representing an unsigned integer.
+func Uint(n uint64) Token {
+	return Token{raw: strconv.FormatUint(n, 10)}
+}
+
+// Clone returns a copy of t, tokens are immutable so this is t itself.
+func (t Token) Clone() Token { return t }
+
+// Kind returns the kind of the token.
+func (t Token) Kind() Kind {
+	if t.raw == "" {
+		return 0
+	}
+	return kindOf(t.raw[0])
+}
+
+// Bool returns the value of a boolean token. It panics if t is not a boolean.
+func (t Token) Bool() bool {
+	switch t.raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	panic("jsontext: invalid use of Token.Bool on a " + t.Kind().String() + " token")
+}
+
+// String returns the unquoted value of a string token, or the JSON
+// representation of other tokens.
+func (t Token) String() string {
+	switch t.Kind() {
+	case '"':
+		var s string
+		json.Unmarshal([]byte(t.raw), &s)
+		return s
+	case 0:
+		return "<invalid jsontext.Token>"
+	default:
+		return t.raw
+	}
+}
+
+// Float returns the value of a number token as a float64, or of one of the
+// "NaN", "Infinity", and "-Infinity" strings. It panics for other tokens.
+func (t Token) Float() float64 {
+	var f float64
+	if _, err := json.Parse([]byte(t.raw), &f, json.AllowNonFiniteFloats); err == nil {
+		return f
+	}
+	panic("jsontext: invalid use of Token.Float on a " + t.Kind().String() + " token")
+}
+
+// Int returns the value of a number token as an int64, the value is truncated
+// and clamped if it is not an integer or out of range. It panics if t is not a
+// number.
+func (t Token) Int() int64 {
+	if t.Kind() != '0' {
+		panic("jsontext: invalid use of Token.Int on a " + t.Kind().String() + " token")
+	}
+	if n, err := strconv.ParseInt(t.raw, 10, 64); err == nil {
+		return n
+	}
+	f := t.Float()
+	switch {
+	case f >= 1<<63:
+		return 1<<63 - 1
+	case f < -1<<63:
+		return -1 << 63
+	default:
+		return int64(f)
+	}
+}
+
+// Uint returns the value of a number token as a uint64, the value is truncated
+// and clamped if it is not an integer or out of range. It panics if t is not a
+// number.
+func (t Token) Uint() uint64 {
+	if t.Kind() != '0' {
+		panic("jsontext: invalid use of Token.Uint on a " + t.Kind().String() + " token")
+	}
+	if n, err := strconv.ParseUint(t.raw, 10, 64); err == nil {
+		return n
+	}
+	f := t.Float()
+	switch {
+	case f >= 1<<64:
+		return 1<<64 - 1
+	case f < 0:
+		return 0
+	default:
+		return uint64(f)
+	}
+}
@@ -0,0 +1,79 @@
+package jsontext
+
+import (
+	"bytes"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/segmentio/encoding/json/internal/jsonopts"
+)
+
+// Value represents a single raw JSON value.
+type Value []byte
+
+// Clone returns a copy of v.
+func (v Value) Clone() Value {
+	if v == nil {
+		return nil
+	}
+	return append(Value{}, v...)
+}
+
+// String returns the content of v as a string.
+func (v Value) String() string {
+	if len(v) == 0 {
+		return ""
+	}
+	return string(v)
+}
+
+// IsValid returns true if v contains exactly one valid JSON value.
+func (v Value) IsValid(opts ...Options) bool {
+	return json.Valid(v)
+}
+
+// Compact removes the insignificant white spaces of v.
+func (v *Value) Compact(opts ...Options) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, *v); err != nil {
+		return err
+	}
+	*v = append((*v)[:0], buf.Bytes()...)
+	return nil
+}
+
+// Indent reformats v over multiple lines, nested values are indented with the
+// indentation and prefix configured by WithIndent and WithIndentPrefix.
+func (v *Value) Indent(opts ...Options) error {
+	var o jsonopts.Struct
+	o.Join(opts...)
+	prefix, indent := o.IndentStrings()
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, *v, prefix, indent); err != nil {
+		return err
+	}
+	*v = append((*v)[:0], buf.Bytes()...)
+	return nil
+}
+
+// Kind returns the kind of the value, or zero if v is not valid.
+func (v Value) Kind() Kind {
+	if v = bytes.TrimLeft(v, " \t\r\n"); len(v) == 0 {
+		return 0
+	}
+	return kindOf(v[0])
+}
+
+// MarshalJSON returns v, or null if it is empty.
+func (v Value) MarshalJSON() ([]byte, error) {
+	if len(v) == 0 {
+		return []byte("null"), nil
+	}
+	return v, nil
+}
+
+// UnmarshalJSON sets v to a copy of b.
+func (v *Value) UnmarshalJSON(b []byte) error {
+	*v = append((*v)[:0], b...)
+	return nil
+}
@@ -0,0 +1,64 @@
+package json
+
+// KeyValue is a member of an OrderedObject, made of a key and its associated
+// value.
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// OrderedObject is a representation of a JSON object which retains the order
+// in which keys appeared in the input, and encodes them in the same order.
+//
+// When the UseOrderedObject parsing flag is set, the decoder produces values of
+// type OrderedObject instead of map[string]any when decoding JSON objects into
+// interface values. Values of type OrderedObject can also be used as decoding
+// destinations directly, regardless of the flags, in which case the objects
+// nested in their values are also decoded as OrderedObject.
+//
+// JSON objects may contain duplicate keys, which are all retained in the
+// OrderedObject when decoding.
+type OrderedObject []KeyValue
+
+// Index returns the index of the first member of obj with the given key, or -1
+// if there are none.
+func (obj OrderedObject) Index(key string) int {
+	for i := range obj {
+		if obj[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the value of the first member of obj with the given key, and a
+// boolean indicating whether the key was found.
+func (obj OrderedObject) Get(key string) (any, bool) {
+	if i := obj.Index(key); i >= 0 {
+		return obj[i].Value, true
+	}
+	return nil, false
+}
+
+// Set sets the value of the first member of obj with the given key, or appends
+// a new member at the end of the object if the key did not exist.
+func (obj *OrderedObject) Set(key string, value any) {
+	if i := obj.Index(key); i >= 0 {
+		(*obj)[i].Value = value
+	} else {
+		*obj = append(*obj, KeyValue{Key: key, Value: value})
+	}
+}
+
+// Delete removes the first member of obj with the given key, preserving the
+// order of the other members. It returns false if the key was not found.
+func (obj *OrderedObject) Delete(key string) bool {
+	i := obj.Index(key)
+	if i < 0 {
+		return false
+	}
+	n := copy((*obj)[i:], (*obj)[i+1:])
+	(*obj)[i+n] = KeyValue{}
+	*obj = (*obj)[:i+n]
+	return true
+}
@@ -11,6 +11,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/segmentio/encoding/ascii"
+	"github.com/segmentio/encoding/json/internal/structural"
 )
 
 // All spaces characters defined in the json specification.
@@ -21,6 +22,9 @@ const (
 	cr = '\r'
 )
 
+// The record separator which prefixes values in JSON text sequences.
+const rs = 0x1E
+
 func internalParseFlags(b []byte) (flags ParseFlags) {
 	// Don't consider surrounding whitespace
 	b = skipSpaces(b)
@@ -722,6 +726,278 @@ func (d decoder) parseValue(b []byte) ([]byte, []byte, Kind, error) {
 	return v, b, k, err
 }
 
+// checkDuplicateNames returns an error if an object of the json value at the
+// beginning of b has two members with the same name, after unescaping. Syntax
+// errors interrupt the check and are left to be reported by the decoder.
+func (d decoder) checkDuplicateNames(b []byte) error {
+	_, err := d.skipDuplicateNames(b)
+	return err
+}
+
+// skipDuplicateNames returns the input remaining after the json value at the
+// beginning of b, or nil if the value is invalid.
+func (d decoder) skipDuplicateNames(b []byte) ([]byte, error) {
+	b = skipSpaces(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	switch b[0] {
+	case '[':
+		for b = b[1:]; ; {
+			if b = skipSpaces(b); len(b) == 0 {
+				return nil, nil
+			}
+			switch b[0] {
+			case ']':
+				return b[1:], nil
+			case ',':
+				b = b[1:]
+				continue
+			}
+			r, err := d.skipDuplicateNames(b)
+			if err != nil || r == nil {
+				return nil, err
+			}
+			b = r
+		}
+
+	case '{':
+		var names map[string]struct{}
+		var first []byte
+
+		for b = b[1:]; ; {
+			if b = skipSpaces(b); len(b) == 0 {
+				return nil, nil
+			}
+			switch b[0] {
+			case '}':
+				return b[1:], nil
+			case ',':
+				b = b[1:]
+				continue
+			}
+
+			name, r, _, err := d.parseStringUnquote(b, nil)
+			if err != nil {
+				return nil, nil
+			}
+
+			switch {
+			case first == nil:
+				first = name
+			case names == nil:
+				if string(name) == string(first) {
+					return nil, syntaxError(b, "duplicate object member name %q", name)
+				}
+				names = map[string]struct{}{string(first): {}, string(name): {}}
+			default:
+				if _, dup := names[string(name)]; dup {
+					return nil, syntaxError(b, "duplicate object member name %q", name)
+				}
+				names[string(name)] = struct{}{}
+			}
+
+			if r = skipSpaces(r); len(r) == 0 || r[0] != ':' {
+				return nil, nil
+			}
+			if r, err = d.skipDuplicateNames(r[1:]); err != nil || r == nil {
+				return nil, err
+			}
+			b = r
+		}
+
+	default:
+		_, r, err := d.skipValue(b)
+		if err != nil {
+			return nil, nil
+		}
+		return r, nil
+	}
+}
+
+// parseDocument validates the json document in b, returning it without the
+// surrounding whitespace.
+func parseDocument(b []byte) ([]byte, error) {
+	b = skipSpaces(b)
+	d := decoder{flags: internalParseFlags(b)}
+	v, r, err := d.skipValue(b)
+	if err != nil {
+		return nil, err
+	}
+	if r = skipSpaces(r); len(r) != 0 {
+		return nil, syntaxError(r, "invalid character '%c' after top-level value", r[0])
+	}
+	return v, nil
+}
+
+// skipValue returns the json value at the beginning of b and the remaining
+// input, it is equivalent to parseValue for callers which do not need the kind
+// of the value.
+//
+// Objects and arrays are validated using the structural index of the input
+// (see internal/structural), which avoids inspecting the content of strings
+// byte by byte. The index is only used when the input contains non-ASCII or
+// escaped characters, since parseValue is faster on plain ASCII strings which
+// it only needs to search for the closing quote (BenchmarkSkipValue measures
+// both on code.json with the flags computed by the callers). The method falls back to
+// parseValue when the value is a scalar or when the fast path cannot validate
+// it, so errors are reported the same way in both cases.
+func (d decoder) skipValue(b []byte) ([]byte, []byte, error) {
+	if len(b) != 0 && (b[0] == '{' || b[0] == '[') && (d.flags&(validAsciiPrint|noBackslash)) != validAsciiPrint|noBackslash {
+		if n := d.skipContainer(b); n != 0 {
+			return b[:n], b[n:], nil
+		}
+	}
+	v, r, _, err := d.parseValue(b)
+	return v, r, err
+}
+
+// The maximum nesting depth of values validated by skipContainer.
+const maxSkipDepth = 256
+
+// skipContainer returns the length of the object or array at the beginning of
+// b, or zero if it is invalid or exceeds the maximum nesting depth.
+func (d decoder) skipContainer(b []byte) int {
+	const (
+		expectValue = iota
+		expectFirstValue
+		expectKey
+		expectFirstKey
+		expectColon
+		expectComma
+	)
+
+	var x structural.Indexer
+	x.Reset(b)
+
+	// Bit set of the nesting levels which are objects rather than arrays.
+	var objects [maxSkipDepth / 64]uint64
+	depth := 0
+	state := expectValue
+
+	for i := -1; ; {
+		j := x.Next()
+		if j < 0 {
+			return 0
+		}
+
+		// The bytes between two structural characters are either whitespace
+		// or a scalar value, since strings are delimited by structural quotes.
+		if v := skipSpaces(b[i+1 : j]); len(v) != 0 {
+			if state != expectValue && state != expectFirstValue {
+				return 0
+			}
+			if _, r, _, err := d.parseValue(v); err != nil || len(skipSpaces(r)) != 0 {
+				return 0
+			}
+			state = expectComma
+		}
+
+		switch b[j] {
+		case '{', '[':
+			if (state != expectValue && state != expectFirstValue) || depth == maxSkipDepth {
+				return 0
+			}
+			if b[j] == '{' {
+				objects[depth/64] |= 1 << (depth % 64)
+				state = expectFirstKey
+			} else {
+				objects[depth/64] &^= 1 << (depth % 64)
+				state = expectFirstValue
+			}
+			depth++
+
+		case '}', ']':
+			if depth == 0 {
+				return 0
+			}
+			depth--
+			inObject := objects[depth/64]&(1<<(depth%64)) != 0
+			switch {
+			case state == expectComma:
+			case state == expectFirstKey && inObject:
+			case state == expectFirstValue && !inObject:
+			default:
+				return 0
+			}
+			if inObject != (b[j] == '}') {
+				return 0
+			}
+			if depth == 0 {
+				return j + 1
+			}
+			state = expectComma
+
+		case ':':
+			if state != expectColon {
+				return 0
+			}
+			state = expectValue
+
+		case ',':
+			if state != expectComma || depth == 0 {
+				return 0
+			}
+			if objects[(depth-1)/64]&(1<<((depth-1)%64)) != 0 {
+				state = expectKey
+			} else {
+				state = expectValue
+			}
+
+		case '"':
+			k := x.Next()
+			if k < 0 {
+				return 0
+			}
+			if !d.flags.has(noBackslash) && !validEscapes(b[j+1:k]) {
+				return 0
+			}
+			switch state {
+			case expectKey, expectFirstKey:
+				state = expectColon
+			case expectValue, expectFirstValue:
+				state = expectComma
+			default:
+				return 0
+			}
+			j = k
+		}
+
+		i = j
+	}
+}
+
+// validEscapes returns true if the escape sequences of the string content s are
+// valid. Control characters are not checked since the structural index already
+// rejects them.
+func validEscapes(s []byte) bool {
+	for {
+		i := bytes.IndexByte(s, '\\')
+		if i < 0 {
+			return true
+		}
+		if s = s[i+1:]; len(s) == 0 {
+			return false
+		}
+		switch s[0] {
+		case '"', '\\', '/', 'n', 'r', 't', 'f', 'b':
+			s = s[1:]
+		case 'u':
+			if len(s) < 5 || !isHex(s[1]) || !isHex(s[2]) || !isHex(s[3]) || !isHex(s[4]) {
+				return false
+			}
+			s = s[5:]
+		default:
+			return false
+		}
+	}
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
 func hasNullPrefix(b []byte) bool {
 	return len(b) >= 4 && string(b[:4]) == "null"
 }
@@ -2,6 +2,9 @@ package json
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -225,3 +228,148 @@ func BenchmarkParseStringEscapeAll(b *testing.B) {
 		s = ""
 	}
 }
+
+func TestSkipValue(t *testing.T) {
+	long := strings.Repeat(`{"key":["value",1.5e3,true,null,{"\\\"k\u00e9y\/":false}]},`, 10)
+
+	tests := []string{
+		`{}`,
+		`[]`,
+		` { } `,
+		`[1,2,3]`,
+		`{"a":1,"b":[true,false,null],"c":{"d":"e"}}`,
+		`{"a":1}{"b":2}`,
+		`[` + long + `{}]`,
+		`[` + strings.Repeat(`[`, 300) + strings.Repeat(`]`, 300) + `]`,
+		`["` + strings.Repeat(`\\`, 100) + `"]`,
+		`["\u12"]`,
+		`["\u12G4"]`,
+		`["\x"]`,
+		`["` + "\x01" + `"]`,
+		`["` + strings.Repeat("x", 100) + "\t" + `"]`,
+		`[1,]`,
+		`[,1]`,
+		`[1 2]`,
+		`[1}`,
+		`{"a":1]`,
+		`{"a":1,}`,
+		`{"a" 1}`,
+		`{"a"::1}`,
+		`{1:1}`,
+		`{"a":1 "b":2}`,
+		`["a" "b"]`,
+		`[01]`,
+		`[-]`,
+		`[nul]`,
+		`[truex]`,
+		`[1 x]`,
+		`[\"a"]`,
+		`[{"a":[1,{"b":2}]}]]`,
+		`[` + long,
+		`{"a":"b`,
+		`{`,
+		`[`,
+	}
+
+	// Mutate a long value to exercise errors at random positions, including
+	// across block boundaries of the structural index.
+	prng := rand.New(rand.NewSource(0))
+	alphabet := []byte("{}[]:,\"\\ \n01-.eE+tfnu\x00\x80")
+	for range 2000 {
+		b := []byte(`[` + long + `{}]`)
+		for range 1 + prng.Intn(3) {
+			i := prng.Intn(len(b))
+			switch prng.Intn(3) {
+			case 0:
+				b[i] = alphabet[prng.Intn(len(alphabet))]
+			case 1:
+				b = append(b[:i], b[i+1:]...)
+			case 2:
+				b = append(b[:i], append([]byte{alphabet[prng.Intn(len(alphabet))]}, b[i:]...)...)
+			}
+		}
+		tests = append(tests, string(b))
+	}
+
+	for _, test := range tests {
+		b := skipSpaces([]byte(test))
+		d := decoder{flags: internalParseFlags(b)}
+		v1, r1, _, err1 := d.parseValue(b)
+
+		// The structural index is only used on inputs which are not plain
+		// ASCII, clear the flag to exercise it on all test inputs.
+		for _, flags := range []ParseFlags{0, d.flags &^ validAsciiPrint} {
+			v2, r2, err2 := decoder{flags: flags}.skipValue(b)
+
+			if !bytes.Equal(v1, v2) || !bytes.Equal(r1, r2) || fmt.Sprint(err1) != fmt.Sprint(err2) {
+				t.Errorf("%q: skipValue does not match parseValue\nwant: %q %q %v\ngot:  %q %q %v", test, v1, r1, err1, v2, r2, err2)
+			}
+		}
+		if Valid(b) != (err1 == nil && len(skipSpaces(r1)) == 0) {
+			t.Errorf("%q: wrong result returned by Valid", test)
+		}
+
+		var want, got bytes.Buffer
+		errWant := json.Compact(&want, b)
+		errGot := Compact(&got, b)
+		if (errWant != nil) != (errGot != nil) || want.String() != got.String() {
+			t.Errorf("%q: Compact does not match encoding/json\nwant: %q %v\ngot:  %q %v", test, want.String(), errWant, got.String(), errGot)
+		}
+	}
+}
+
+func BenchmarkSkipValue(b *testing.B) {
+	if codeJSON == nil {
+		b.StopTimer()
+		codeInit()
+		b.StartTimer()
+	}
+
+	item := `{"id":12345,"tags":["alpha","beta"],"text":"` + strings.Repeat("lörem ipsüm dolor ", 40) + `"},`
+	textJSON := []byte("[" + strings.Repeat(item, 2000) + "{}]")
+
+	tests := []struct {
+		name  string
+		input []byte
+		flags ParseFlags
+	}{
+		{"code", codeJSON, internalParseFlags(codeJSON)},
+		{"text", textJSON, internalParseFlags(textJSON)},
+	}
+
+	// The inputs are benchmarked with the flags computed by the callers of
+	// skipValue: code.json is plain ASCII, so skipValue uses parseValue, and
+	// the index sub-benchmark measures the structural index on its own to
+	// show why.
+
+	for _, test := range tests {
+		d := decoder{flags: test.flags}
+
+		b.Run(test.name+"/parseValue", func(b *testing.B) {
+			b.SetBytes(int64(len(test.input)))
+			for range b.N {
+				if _, _, _, err := d.parseValue(test.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(test.name+"/skipValue", func(b *testing.B) {
+			b.SetBytes(int64(len(test.input)))
+			for range b.N {
+				if _, _, err := d.skipValue(test.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(test.name+"/index", func(b *testing.B) {
+			b.SetBytes(int64(len(test.input)))
+			for range b.N {
+				if d.skipContainer(test.input) == 0 {
+					b.Fatal("invalid input")
+				}
+			}
+		})
+	}
+}
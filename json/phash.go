@@ -0,0 +1,181 @@
+package json
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// fieldTable is a perfect hash table mapping object keys to struct fields.
+//
+// The table is built once when the codec of a struct type is constructed, using
+// the "hash and displace" technique: keys are first distributed into buckets by
+// their hash, then each bucket is assigned a displacement value chosen so that
+// all keys of the struct land in different slots of the table.
+//
+// Looking up a key which does not exist in the table lands on an arbitrary
+// slot, which is why lookups always compare the key stored in the slot with
+// the one being searched for. Compared to a Go map, a lookup hashes the key
+// once with a simple function and never probes more than one slot, which
+// matters in the hot loop of decoding struct fields.
+type fieldTable struct {
+	seed  uint64
+	mask  uint64
+	disp  []uint16
+	slots []fieldSlot
+}
+
+type fieldSlot struct {
+	key   string
+	field *structField
+}
+
+const (
+	// Number of displacement values tried for a bucket before giving up on
+	// the current seed.
+	fieldTableMaxDisplacement = 1 << 12
+	// Number of seeds tried before doubling the size of the table.
+	fieldTableMaxSeeds = 8
+)
+
+func makeFieldTable(fields map[string]*structField) fieldTable {
+	if len(fields) == 0 {
+		return fieldTable{}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // deterministic construction
+
+	// The table is kept at most half full, which makes finding displacements
+	// fast and only costs a few bytes per struct field.
+	size := 1 << bits.Len(uint(2*len(keys)-1))
+
+	for {
+		for seed := range uint64(fieldTableMaxSeeds) {
+			if t, ok := buildFieldTable(fields, keys, size, seed); ok {
+				return t
+			}
+		}
+		size *= 2
+	}
+}
+
+func buildFieldTable(fields map[string]*structField, keys []string, size int, seed uint64) (fieldTable, bool) {
+	nbuckets := size / 2
+	buckets := make([][]uint64, nbuckets)
+	hashes := make(map[uint64]string, len(keys))
+
+	for _, k := range keys {
+		h := hashKey(stringToBytes(k), seed)
+		if _, collision := hashes[h]; collision {
+			return fieldTable{}, false
+		}
+		hashes[h] = k
+		b := h & uint64(nbuckets-1)
+		buckets[b] = append(buckets[b], h)
+	}
+
+	order := make([]int, nbuckets)
+	for i := range order {
+		order[i] = i
+	}
+	// Place the largest buckets first while the table is mostly empty.
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(buckets[order[i]]) > len(buckets[order[j]])
+	})
+
+	t := fieldTable{
+		seed:  seed,
+		mask:  uint64(size - 1),
+		disp:  make([]uint16, nbuckets),
+		slots: make([]fieldSlot, size),
+	}
+	used := make([]bool, size)
+	slots := make([]uint64, 0, 8)
+
+	for _, b := range order {
+		if len(buckets[b]) == 0 {
+			break
+		}
+		d, ok := findDisplacement(buckets[b], used, t.mask, slots)
+		if !ok {
+			return fieldTable{}, false
+		}
+		for _, h := range buckets[b] {
+			i := displace(h, d) & t.mask
+			k := hashes[h]
+			used[i] = true
+			t.slots[i] = fieldSlot{key: k, field: fields[k]}
+		}
+		t.disp[b] = uint16(d)
+	}
+
+	return t, true
+}
+
+// findDisplacement searches for a displacement value which moves all hashes of
+// a bucket to distinct slots that are not used yet.
+func findDisplacement(bucket []uint64, used []bool, mask uint64, slots []uint64) (uint64, bool) {
+search:
+	for d := range uint64(fieldTableMaxDisplacement) {
+		slots = slots[:0]
+
+		for _, h := range bucket {
+			i := displace(h, d) & mask
+			if used[i] {
+				continue search
+			}
+			for _, j := range slots {
+				if i == j {
+					continue search
+				}
+			}
+			slots = append(slots, i)
+		}
+
+		return d, true
+	}
+	return 0, false
+}
+
+// lookup returns the struct field associated with k, or nil if there are none.
+func (t *fieldTable) lookup(k []byte) *structField {
+	if len(t.slots) == 0 {
+		return nil
+	}
+	h := hashKey(k, t.seed)
+	d := t.disp[h&(uint64(len(t.disp))-1)]
+	s := &t.slots[displace(h, uint64(d))&t.mask]
+	if s.key != string(k) {
+		return nil
+	}
+	return s.field
+}
+
+// hashKey is a seeded variant of the 64 bits FNV-1a hash function.
+func hashKey(k []byte, seed uint64) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset) ^ (seed * prime)
+	for _, c := range k {
+		h ^= uint64(c)
+		h *= prime
+	}
+	return h
+}
+
+// displace mixes the displacement value of a bucket into the hash of a key to
+// compute its slot in the table (the finalizer of splitmix64).
+func displace(h, d uint64) uint64 {
+	h ^= d * 0x9e3779b97f4a7c15
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,43 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestFieldTable(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 10, 33, 100, 1000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			fields := make([]structField, n)
+			index := make(map[string]*structField, n)
+
+			for i := range fields {
+				fields[i].name = fmt.Sprintf("field_%d", i)
+				index[fields[i].name] = &fields[i]
+			}
+
+			table := makeFieldTable(index)
+
+			for i := range fields {
+				f := &fields[i]
+				if found := table.lookup([]byte(f.name)); found != f {
+					t.Errorf("lookup(%q): wrong field returned: %v", f.name, found)
+				}
+			}
+
+			for _, k := range []string{"", "field_", "field_-1", "Field_0", "unknown"} {
+				if found := table.lookup([]byte(k)); found != nil {
+					t.Errorf("lookup(%q): expected no field but found %q", k, found.name)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldTableEmpty(t *testing.T) {
+	table := makeFieldTable(nil)
+	if f := table.lookup([]byte("key")); f != nil {
+		t.Errorf("lookup on an empty table returned %q", f.name)
+	}
+}
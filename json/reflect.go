@@ -1,5 +1,5 @@
-//go:build go1.20
-// +build go1.20
+//go:build go1.20 || purego
+// +build go1.20 purego
 
 package json
 
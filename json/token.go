@@ -170,6 +170,9 @@ skipLoop:
 
 	if t.Delim == 0 {
 		t.IsKey = t.isKey
+		if t.IsKey && t.stack != nil {
+			t.stack.setKey(t.Value)
+		}
 	} else {
 		t.IsKey = false
 
@@ -180,6 +183,9 @@ skipLoop:
 		case '[':
 			t.push(inArray)
 		case '}':
+			// The next value, if any, is an element of an array or follows
+			// a comma which tells whether it is a key.
+			t.isKey = false
 			t.Err = t.pop(inObject)
 			t.Depth--
 			t.Index = t.index()
@@ -196,6 +202,7 @@ skipLoop:
 			}
 			if t.stack.is(inObject) {
 				t.isKey = true
+				t.stack.setKey(nil)
 			}
 			t.stack.state[len(t.stack.state)-1].len++
 		}
@@ -232,6 +239,120 @@ func (t *Tokenizer) pop(expect scope) error {
 	return nil
 }
 
+// Skip advances the tokenizer past the object or array that it is positioned at
+// the beginning of, leaving it positioned on the closing delimiter as if it had
+// been reached by calling Next. Nested values are not tokenized, the input is
+// validated with the structural index also used to skip unknown fields when
+// decoding json values.
+//
+// The method does nothing when the tokenizer is not positioned on '{' or '['.
+// It returns false if the tokenizer encountered malformed json, in which case
+// t.Err is set to an error describing the issue.
+func (t *Tokenizer) Skip() bool {
+	_, ok := t.skip()
+	return ok
+}
+
+// Capture returns the raw json value that the tokenizer is positioned on. When
+// positioned at the beginning of an object or array, the returned value holds
+// the entire object or array, and the tokenizer is advanced past it as if Skip
+// had been called.
+//
+// The returned slice references the input of the tokenizer. It is nil when the
+// tokenizer is positioned on a delimiter other than '{' or '[', or if it
+// encountered malformed json.
+func (t *Tokenizer) Capture() RawMessage {
+	v, ok := t.skip()
+	if !ok {
+		return nil
+	}
+	if v == nil && t.Delim == 0 {
+		v = t.Value
+	}
+	return RawMessage(v)
+}
+
+func (t *Tokenizer) skip() ([]byte, bool) {
+	if t.Err != nil {
+		return nil, false
+	}
+
+	var end Delim
+	var typ scope
+	switch t.Delim {
+	case '{':
+		end, typ = '}', inObject
+	case '[':
+		end, typ = ']', inArray
+	default:
+		return nil, true
+	}
+
+	// The delimiter was sliced from the input of the tokenizer, extending it
+	// yields the entire object or array.
+	b := t.Value[:len(t.Value)+len(t.json)]
+	v, r, err := t.skipValue(b)
+	if err != nil {
+		t.Err = err
+		return nil, false
+	}
+
+	t.Delim, t.Value, t.json = end, v[len(v)-1:], r
+	t.Err = t.pop(typ)
+	t.Depth = t.depth()
+	t.Index = t.index()
+	t.IsKey = false
+	t.isKey = false
+	t.flags = t.flags.withKind(0)
+	return v, t.Err == nil
+}
+
+// PathElement is an element of the path to a json value returned by the Path
+// method of Tokenizer.
+type PathElement struct {
+	// The raw json key of the value, as a quoted string, when the value is in
+	// an object. The field is nil when the value is in an array.
+	Key RawValue
+
+	// The position of the value in its enclosing array or object.
+	Index int
+}
+
+// Path returns the path from the root of the json input to the value that the
+// tokenizer is positioned on, with one element per enclosing array or object.
+// When positioned on the key of an object, the path is the one of the value
+// associated with the key. When positioned on the delimiters of an object or
+// array, the path is the one of the object or array itself.
+//
+// The keys of the returned path reference the input of the tokenizer.
+func (t *Tokenizer) Path() []PathElement {
+	return t.AppendPath(nil)
+}
+
+// AppendPath appends the elements of the path returned by Path to p, and
+// returns the extended slice.
+func (t *Tokenizer) AppendPath(p []PathElement) []PathElement {
+	if t.stack == nil {
+		return p
+	}
+
+	state := t.stack.state
+	switch t.Delim {
+	case '{', '[':
+		// The object or array was already pushed on the stack.
+		state = state[:len(state)-1]
+	}
+
+	for _, s := range state {
+		e := PathElement{Index: s.len - 1}
+		if s.typ == inObject {
+			e.Key = s.key
+		}
+		p = append(p, e)
+	}
+	return p
+}
+
 // Kind returns the kind of the value that the tokenizer is currently positioned
 // on.
 func (t *Tokenizer) Kind() Kind { return t.flags.kind() }
@@ -369,6 +490,7 @@ const (
 type state struct {
 	typ scope
 	len int
+	key []byte
 }
 
 type stack struct {
@@ -390,10 +512,17 @@ func (s *stack) pop(expect scope) bool {
 		return false
 	}
 
+	s.state[i] = state{}
 	s.state = s.state[:i]
 	return true
 }
 
+func (s *stack) setKey(key []byte) {
+	if len(s.state) != 0 {
+		s.state[len(s.state)-1].key = key
+	}
+}
+
 func (s *stack) is(typ scope) bool {
 	return len(s.state) != 0 && s.state[len(s.state)-1].typ == typ
 }
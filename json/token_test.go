@@ -3,6 +3,8 @@ package json
 import (
 	"bytes"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -180,6 +182,20 @@ func TestTokenizer(t *testing.T) {
 				delim(`]`, 0, 0),
 			},
 		},
+
+		{
+			input: []byte(`[{}, 1, "s"]`),
+			tokens: []token{
+				delim(`[`, 0, 0),
+				delim(`{`, 1, 0),
+				delim(`}`, 1, 0),
+				delim(`,`, 1, 0),
+				value(`1`, 1, 1),
+				delim(`,`, 1, 1),
+				value(`"s"`, 1, 2),
+				delim(`]`, 0, 0),
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -230,6 +246,195 @@ func TestTokenizer_invalidInput(t *testing.T) {
 	}
 }
 
+func TestTokenizerSkip(t *testing.T) {
+	input := []byte(`{"a":{"b":[1,2,{"c":3}]},"d":[[],{}],"e":4}`)
+
+	var tokens []token
+	for tok := NewTokenizer(input); tok.Next(); {
+		if tok.Depth != 0 && (tok.Delim == '{' || tok.Delim == '[') {
+			if !tok.Skip() {
+				t.Fatal(tok.Err)
+			}
+		}
+		tokens = append(tokens, token{
+			delim: tok.Delim,
+			value: tok.Value,
+			depth: tok.Depth,
+			index: tok.Index,
+			isKey: tok.IsKey,
+		})
+	}
+
+	expected := []token{
+		delim(`{`, 0, 0),
+		key(`"a"`, 1, 0),
+		delim(`:`, 1, 0),
+		delim(`}`, 1, 0),
+		delim(`,`, 1, 0),
+		key(`"d"`, 1, 1),
+		delim(`:`, 1, 1),
+		delim(`]`, 1, 1),
+		delim(`,`, 1, 1),
+		key(`"e"`, 1, 2),
+		delim(`:`, 1, 2),
+		value(`4`, 1, 2),
+		delim(`}`, 0, 0),
+	}
+
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Error("tokens mismatch")
+		t.Logf("expected: %+v", expected)
+		t.Logf("found:    %+v", tokens)
+	}
+}
+
+func TestTokenizerSkipInArray(t *testing.T) {
+	tests := []struct {
+		input string
+		end   string
+	}{
+		{input: `[1,{"a":1},3,"s"]`, end: `}`},
+		{input: `[1,{},3,"s"]`, end: `}`},
+		{input: `[1,[{}],3,"s"]`, end: `]`},
+	}
+
+	for _, test := range tests {
+		var tokens []token
+		for tok := NewTokenizer([]byte(test.input)); tok.Next(); {
+			if tok.Depth != 0 && (tok.Delim == '{' || tok.Delim == '[') {
+				if !tok.Skip() {
+					t.Fatal(tok.Err)
+				}
+			}
+			tokens = append(tokens, token{
+				delim: tok.Delim,
+				value: tok.Value,
+				depth: tok.Depth,
+				index: tok.Index,
+				isKey: tok.IsKey,
+			})
+		}
+
+		expected := []token{
+			delim(`[`, 0, 0),
+			value(`1`, 1, 0),
+			delim(`,`, 1, 0),
+			delim(test.end, 1, 1),
+			delim(`,`, 1, 1),
+			value(`3`, 1, 2),
+			delim(`,`, 1, 2),
+			value(`"s"`, 1, 3),
+			delim(`]`, 0, 0),
+		}
+
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("%s: tokens mismatch", test.input)
+			t.Logf("expected: %+v", expected)
+			t.Logf("found:    %+v", tokens)
+		}
+	}
+}
+
+func TestTokenizerSkipInvalidInput(t *testing.T) {
+	for _, input := range []string{`[{"a":}]`, `[1,2`, `{"a":[}`} {
+		tok := NewTokenizer([]byte(input))
+		tok.Next()
+
+		if tok.Skip() {
+			t.Errorf("%s: expected Skip to fail", input)
+		}
+		if tok.Err == nil {
+			t.Errorf("%s: expected Err to be set, got nil", input)
+		}
+		if tok.Next() {
+			t.Errorf("%s: expected Next to fail after Skip", input)
+		}
+	}
+}
+
+func TestTokenizerCapture(t *testing.T) {
+	input := []byte(`{"a": {"b": [1, 2]}, "c": "hello", "d": [], "e": null}`)
+
+	captures := map[string]string{}
+	for tok := NewTokenizer(input); tok.Next(); {
+		if tok.Depth == 1 && tok.IsKey {
+			k := string(tok.String())
+			tok.Next() // ':'
+			tok.Next()
+			captures[k] = string(tok.Capture())
+		}
+	}
+
+	expected := map[string]string{
+		"a": `{"b": [1, 2]}`,
+		"c": `"hello"`,
+		"d": `[]`,
+		"e": `null`,
+	}
+
+	if !reflect.DeepEqual(captures, expected) {
+		t.Error("captures mismatch")
+		t.Logf("expected: %q", expected)
+		t.Logf("found:    %q", captures)
+	}
+
+	tok := NewTokenizer([]byte(`[1]`))
+	tok.Next()
+	tok.Next()
+	tok.Next()
+	if v := tok.Capture(); v != nil {
+		t.Errorf("expected no value when positioned on ']', got %q", v)
+	}
+}
+
+func TestTokenizerPath(t *testing.T) {
+	input := []byte(`{"a":{"b":[1,{"c":true}]},"d":[[null]]}`)
+
+	var paths []string
+	for tok := NewTokenizer(input); tok.Next(); {
+		if tok.Delim == ':' || tok.Delim == ',' {
+			continue
+		}
+		var path []string
+		for _, e := range tok.Path() {
+			if e.Key != nil {
+				path = append(path, string(e.Key.Unquote()))
+			} else {
+				path = append(path, strconv.Itoa(e.Index))
+			}
+		}
+		paths = append(paths, string(tok.Value)+" "+strings.Join(path, "."))
+	}
+
+	expected := []string{
+		`{ `,
+		`"a" a`,
+		`{ a`,
+		`"b" a.b`,
+		`[ a.b`,
+		`1 a.b.0`,
+		`{ a.b.1`,
+		`"c" a.b.1.c`,
+		`true a.b.1.c`,
+		`} a.b.1`,
+		`] a.b`,
+		`} a`,
+		`"d" d`,
+		`[ d`,
+		`[ d.0`,
+		`null d.0.0`,
+		`] d.0`,
+		`] d`,
+		`} `,
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Error("paths mismatch")
+		t.Logf("expected: %q", expected)
+		t.Logf("found:    %q", paths)
+	}
+}
+
 func BenchmarkTokenizer(b *testing.B) {
 	values := []struct {
 		scenario string
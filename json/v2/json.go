@@ -0,0 +1,230 @@
+// Package json implements the encoding of Go values to JSON, with an API
+// compatible with the encoding/json/v2 package of the standard library.
+//
+// The package is backed by the codecs of github.com/segmentio/encoding/json,
+// configured with the defaults of encoding/json/v2: nil slices and maps are
+// encoded as [] and {}, duplicate object member names are rejected, and names
+// are matched case-sensitively. The remaining differences are:
+//
+//   - The Go types supported, the struct tags, and the errors returned follow
+//     the behavior of the github.com/segmentio/encoding/json package.
+//   - MarshalWrite writes the output incrementally, but buffers it entirely
+//     when it is indented.
+//   - UnmarshalRead reads the whole JSON value in memory before decoding it,
+//     so out is left unchanged when the input is truncated.
+//   - Duplicate names are only rejected when decoding, the jsontext.Encoder
+//     does not check the names written to it.
+//
+// Options which are not listed in this package or in the jsontext package
+// are not supported.
+package json
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/segmentio/encoding/json/internal/jsonopts"
+	"github.com/segmentio/encoding/json/jsontext"
+)
+
+// Options configures the Marshal and Unmarshal functions, it accepts the
+// options of this package and of the jsontext package.
+type Options = jsonopts.Options
+
+// DefaultOptionsV2 returns the set of all options explicitly set to their
+// default values.
+func DefaultOptionsV2() Options {
+	return &jsonopts.Struct{
+		Flags: jsonopts.EscapeForHTML |
+			jsonopts.Multiline |
+			jsonopts.AllowDuplicateNames |
+			jsonopts.Deterministic |
+			jsonopts.FormatNilMapAsNull |
+			jsonopts.FormatNilSliceAsNull |
+			jsonopts.MatchCaseInsensitiveNames |
+			jsonopts.RejectUnknownMembers,
+	}
+}
+
+// JoinOptions merges the options in srcs, later options take precedence over
+// earlier ones.
+func JoinOptions(srcs ...Options) Options {
+	opts := new(jsonopts.Struct)
+	opts.Join(srcs...)
+	return opts
+}
+
+// GetOption returns the value of the option constructed by setter in opts,
+// and whether the option was set. For example:
+//
+//	v, ok := json.GetOption(opts, json.Deterministic)
+func GetOption[T any](opts Options, setter func(T) Options) (T, bool) {
+	var zero T
+	var o jsonopts.Struct
+	o.Join(opts)
+
+	switch opt := setter(zero).(type) {
+	case jsonopts.Bool:
+		v, ok := o.Get(opt.Flag)
+		return any(v).(T), ok
+	case jsonopts.String:
+		switch {
+		case (o.Flags & opt.Flag) == 0:
+			return zero, false
+		case opt.Flag == jsonopts.Indent:
+			return any(o.Indent).(T), true
+		default:
+			return any(o.IndentPrefix).(T), true
+		}
+	default:
+		return zero, false
+	}
+}
+
+// Deterministic specifies that the output of encoding the same value is
+// always the same, which sorts the keys of maps. Defaults to false.
+func Deterministic(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.Deterministic, Value: v}
+}
+
+// FormatNilMapAsNull specifies that nil maps are encoded as JSON null instead
+// of empty JSON objects. Defaults to false.
+func FormatNilMapAsNull(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.FormatNilMapAsNull, Value: v}
+}
+
+// FormatNilSliceAsNull specifies that nil slices are encoded as JSON null
+// instead of empty JSON arrays, or empty JSON strings for byte slices.
+// Defaults to false.
+func FormatNilSliceAsNull(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.FormatNilSliceAsNull, Value: v}
+}
+
+// MatchCaseInsensitiveNames specifies that object member names are matched
+// against struct fields without regard to case when decoding. Defaults to
+// false.
+func MatchCaseInsensitiveNames(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.MatchCaseInsensitiveNames, Value: v}
+}
+
+// RejectUnknownMembers specifies that decoding fails when an object has a
+// member which does not match a struct field. Defaults to false.
+func RejectUnknownMembers(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.RejectUnknownMembers, Value: v}
+}
+
+// Marshal returns the JSON encoding of in.
+func Marshal(in any, opts ...Options) ([]byte, error) {
+	var o jsonopts.Struct
+	o.Join(opts...)
+	return marshal(nil, in, &o)
+}
+
+// MarshalWrite writes the JSON encoding of in to out.
+//
+// The output is written incrementally while encoding arrays, maps, and
+// structs, unless it is indented. If an error occurs, out may have received
+// part of the output.
+func MarshalWrite(out io.Writer, in any, opts ...Options) error {
+	var o jsonopts.Struct
+	o.Join(opts...)
+
+	if o.Multiline() {
+		b, err := marshal(nil, in, &o)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(b)
+		return err
+	}
+
+	enc := o.Encoder(out)
+	enc.SetFlushThreshold(flushThreshold)
+	return enc.Encode(in)
+}
+
+// flushThreshold is the size of the output buffered by MarshalWrite before it
+// is written to the io.Writer.
+const flushThreshold = 4096
+
+// MarshalEncode writes the JSON encoding of in as the next value of out. The
+// options of out apply, opts take precedence over them.
+func MarshalEncode(out *jsontext.Encoder, in any, opts ...Options) error {
+	var o jsonopts.Struct
+	o.Join(out.Options())
+	o.Join(opts...)
+
+	b, err := json.Append(nil, in, o.AppendFlags())
+	if err != nil {
+		return err
+	}
+	return out.WriteValue(b)
+}
+
+func marshal(b []byte, in any, o *jsonopts.Struct) ([]byte, error) {
+	b, err := json.Append(b, in, o.AppendFlags())
+	if err != nil || !o.Multiline() {
+		return b, err
+	}
+
+	var buf bytes.Buffer
+	prefix, indent := o.IndentStrings()
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the JSON value in into out, which must be a pointer. The
+// input must contain exactly one JSON value.
+func Unmarshal(in []byte, out any, opts ...Options) error {
+	var o jsonopts.Struct
+	o.Join(opts...)
+
+	r, err := json.Parse(in, out, o.ParseFlags())
+	if err == nil && len(r) != 0 {
+		err = fmt.Errorf("json: invalid character '%c' after top-level value", r[0])
+	}
+	return err
+}
+
+// UnmarshalRead reads the JSON value from in and decodes it into out, which
+// must be a pointer. The input must contain exactly one JSON value.
+//
+// The JSON value is read entirely before being decoded, out is left unchanged
+// when reading fails.
+func UnmarshalRead(in io.Reader, out any, opts ...Options) error {
+	dec := jsontext.NewDecoder(in, opts...)
+
+	v, err := dec.ReadValue()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if err := Unmarshal(v, out, opts...); err != nil {
+		return err
+	}
+
+	if _, err := dec.ReadToken(); err != io.EOF {
+		if err == nil {
+			err = errors.New("json: invalid data after top-level value")
+		}
+		return err
+	}
+	return nil
+}
+
+// UnmarshalDecode reads the next value from in and decodes it into out, which
+// must be a pointer. The options of in apply, opts take precedence over them.
+func UnmarshalDecode(in *jsontext.Decoder, out any, opts ...Options) error {
+	v, err := in.ReadValue()
+	if err != nil {
+		return err
+	}
+	return Unmarshal(v, out, append([]Options{in.Options()}, opts...)...)
+}
@@ -0,0 +1,243 @@
+package json
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/segmentio/encoding/json/jsontext"
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func TestMarshal(t *testing.T) {
+	m := map[string]any{"b": "<b>", "a": point{1, 2}}
+
+	b, err := Marshal(m, Deterministic(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"a":{"x":1,"y":2},"b":"<b>"}` {
+		t.Errorf("wrong output: %s", s)
+	}
+
+	b, err = Marshal(m, Deterministic(true), jsontext.EscapeForHTML(true), jsontext.WithIndent(" "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != "{\n \"a\": {\n  \"x\": 1,\n  \"y\": 2\n },\n \"b\": \"\\u003cb\\u003e\"\n}" {
+		t.Errorf("wrong output: %q", s)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := MarshalWrite(buf, point{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	enc := jsontext.NewEncoder(buf)
+	if err := MarshalEncode(enc, point{5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != `{"x":3,"y":4}{"x":5,"y":6}`+"\n" {
+		t.Errorf("wrong output: %s", s)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var p point
+	if err := Unmarshal([]byte(`{"X":1,"y":2}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p != (point{0, 2}) {
+		t.Errorf("names must be case-sensitive by default: %+v", p)
+	}
+
+	p = point{}
+	if err := Unmarshal([]byte(`{"X":1,"y":2}`), &p, MatchCaseInsensitiveNames(true)); err != nil {
+		t.Fatal(err)
+	}
+	if p != (point{1, 2}) {
+		t.Errorf("wrong value decoded: %+v", p)
+	}
+
+	if err := Unmarshal([]byte(`{"z":1}`), &p, RejectUnknownMembers(true)); err == nil {
+		t.Error("expected an error decoding an unknown member")
+	}
+	if err := Unmarshal([]byte(`{} {}`), &p); err == nil {
+		t.Error("expected an error decoding trailing values")
+	}
+
+	if err := UnmarshalRead(strings.NewReader(` {"x":7} `), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 7 {
+		t.Errorf("wrong value decoded: %+v", p)
+	}
+
+	dec := jsontext.NewDecoder(strings.NewReader(`{"x":8} {"x":9}`))
+	for _, x := range []int{8, 9} {
+		if err := UnmarshalDecode(dec, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.X != x {
+			t.Errorf("wrong value decoded: %+v", p)
+		}
+	}
+}
+
+func TestGetOption(t *testing.T) {
+	opts := JoinOptions(Deterministic(true), jsontext.WithIndent("  "), Deterministic(false))
+
+	if v, ok := GetOption(opts, Deterministic); v || !ok {
+		t.Errorf("wrong Deterministic option: %t %t", v, ok)
+	}
+	if v, ok := GetOption(opts, jsontext.WithIndent); v != "  " || !ok {
+		t.Errorf("wrong WithIndent option: %q %t", v, ok)
+	}
+	if _, ok := GetOption(opts, RejectUnknownMembers); ok {
+		t.Error("RejectUnknownMembers must not be set")
+	}
+	if v, ok := GetOption(DefaultOptionsV2(), MatchCaseInsensitiveNames); v || !ok {
+		t.Errorf("wrong default MatchCaseInsensitiveNames option: %t %t", v, ok)
+	}
+}
+
+func TestDefaultsV2(t *testing.T) {
+	type value struct {
+		Slice []int          `json:"slice"`
+		Bytes []byte         `json:"bytes"`
+		Map   map[string]int `json:"map"`
+	}
+
+	b, err := Marshal(value{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"slice":[],"bytes":"","map":{}}` {
+		t.Errorf("wrong output: %s", s)
+	}
+
+	b, err = Marshal(value{}, FormatNilSliceAsNull(true), FormatNilMapAsNull(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"slice":null,"bytes":null,"map":null}` {
+		t.Errorf("wrong output: %s", s)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := MarshalWrite(buf, value{}, FormatNilSliceAsNull(true)); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != `{"slice":null,"bytes":null,"map":{}}` {
+		t.Errorf("wrong output: %s", s)
+	}
+
+	const input = `{"x":1,"y":2,"x":3}`
+	var p point
+
+	if err := Unmarshal([]byte(input), &p); err == nil {
+		t.Error("expected an error decoding duplicate names")
+	}
+	if err := UnmarshalRead(strings.NewReader(input), &p); err == nil {
+		t.Error("expected an error reading duplicate names")
+	}
+	if err := UnmarshalDecode(jsontext.NewDecoder(strings.NewReader(input)), &p); err == nil {
+		t.Error("expected an error decoding duplicate names from a jsontext.Decoder")
+	}
+
+	p = point{}
+	if err := Unmarshal([]byte(input), &p, jsontext.AllowDuplicateNames(true)); err != nil {
+		t.Fatal(err)
+	}
+	if p != (point{3, 2}) {
+		t.Errorf("wrong value decoded: %+v", p)
+	}
+	dec := jsontext.NewDecoder(strings.NewReader(input), jsontext.AllowDuplicateNames(true))
+	if err := UnmarshalDecode(dec, &p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(b []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(b)
+}
+
+// The tests below cover the differences with encoding/json/v2 listed in the
+// documentation of the package.
+
+func TestErrorTypes(t *testing.T) {
+	var p point
+	err := Unmarshal([]byte(`{"x":"1"}`), &p)
+	if _, ok := err.(*json.UnmarshalTypeError); !ok {
+		t.Errorf("expected a *json.UnmarshalTypeError, got %T: %v", err, err)
+	}
+}
+
+func TestMarshalWriteIncremental(t *testing.T) {
+	points := make([]point, 10000)
+
+	w := new(writeCounter)
+	if err := MarshalWrite(w, points); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes < 2 {
+		t.Errorf("expected the output to be written incrementally, got %d writes", w.writes)
+	}
+	b, _ := Marshal(points)
+	if w.String() != string(b) {
+		t.Error("output mismatch between Marshal and MarshalWrite")
+	}
+
+	w = new(writeCounter)
+	if err := MarshalWrite(w, points, jsontext.WithIndent(" ")); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected the indented output to be written at once, got %d writes", w.writes)
+	}
+}
+
+func TestUnmarshalReadTruncated(t *testing.T) {
+	p := point{1, 2}
+	if err := UnmarshalRead(strings.NewReader(`{"x":3,"y":`), &p); err == nil {
+		t.Error("expected an error reading a truncated value")
+	}
+	if p != (point{1, 2}) {
+		t.Errorf("value modified when reading a truncated value: %+v", p)
+	}
+
+	for _, input := range []string{``, ` `, `{} {}`, `{} }`} {
+		if err := UnmarshalRead(strings.NewReader(input), &p); err == nil {
+			t.Errorf("expected an error reading %q", input)
+		}
+	}
+}
+
+func TestEncoderDuplicateNames(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := jsontext.NewEncoder(buf)
+
+	for _, tok := range []jsontext.Token{
+		jsontext.BeginObject,
+		jsontext.String("a"), jsontext.Int(1),
+		jsontext.String("a"), jsontext.Int(2),
+		jsontext.EndObject,
+	} {
+		if err := enc.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := buf.String(); s != `{"a":1,"a":2}`+"\n" {
+		t.Errorf("wrong output: %q", s)
+	}
+}
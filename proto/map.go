@@ -49,7 +49,7 @@ func mapSizeFuncOf(t reflect.Type, f *mapField) sizeFunc {
 		m := MapIter{}
 		defer m.Done()
 
-		for m.Init(pointer(t), p); m.HasNext(); m.Next() {
+		for m.Init(t, p); m.HasNext(); m.Next() {
 			keySize := f.keyCodec.size(m.Key(), wantzero)
 			valSize := f.valCodec.size(m.Value(), wantzero)
 
@@ -104,7 +104,7 @@ func mapEncodeFuncOf(t reflect.Type, f *mapField) encodeFunc {
 		m := MapIter{}
 		defer m.Done()
 
-		for m.Init(pointer(t), p); m.HasNext(); m.Next() {
+		for m.Init(t, p); m.HasNext(); m.Next() {
 			key := m.Key()
 			val := m.Value()
 
@@ -210,17 +210,12 @@ func mapDecodeFuncOf(t reflect.Type, f *mapField, seen map[reflect.Type]*codec)
 	structPool := new(sync.Pool)
 	structZero := pointer(reflect.Zero(structType).Interface())
 
-	valueType := t.Elem()
 	valueOffset := structType.Field(1).Offset
 
-	mtype := pointer(t)
-	stype := pointer(structType)
-	vtype := pointer(valueType)
-
 	return func(b []byte, p unsafe.Pointer, _ flags) (int, error) {
 		m := (*unsafe.Pointer)(p)
 		if *m == nil {
-			*m = MakeMap(mtype, 10)
+			*m = MakeMap(t, 10)
 		}
 		if len(b) == 0 {
 			return 0, nil
@@ -233,11 +228,10 @@ func mapDecodeFuncOf(t reflect.Type, f *mapField, seen map[reflect.Type]*codec)
 
 		n, err := structCodec.decode(b, s, noflags)
 		if err == nil {
-			v := MapAssign(mtype, *m, s)
-			Assign(vtype, v, unsafe.Pointer(uintptr(s)+valueOffset))
+			MapAssign(t, *m, s, unsafe.Pointer(uintptr(s)+valueOffset))
 		}
 
-		Assign(stype, s, structZero)
+		Assign(structType, s, structZero)
 		structPool.Put(s)
 		return n, err
 	}
@@ -134,8 +134,7 @@ func growSlice(t reflect.Type, s *Slice) Slice {
 	if cap == 0 {
 		cap = 10
 	}
-	p := pointer(t)
-	d := MakeSlice(p, s.Len(), cap)
-	CopySlice(p, d, *s)
+	d := MakeSlice(t, s.Len(), cap)
+	CopySlice(t, d, *s)
 	return d
 }